@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/trunov/virena/internal/app/accounting"
+	"github.com/trunov/virena/internal/app/config"
+	"github.com/trunov/virena/internal/app/repo"
+	"github.com/trunov/virena/internal/app/util"
+	"github.com/trunov/virena/logger"
+)
+
+func main() {
+	l := logger.Get()
+	ctx := context.Background()
+
+	fromStr := flag.String("from", "", "first day of the export, YYYY-MM-DD")
+	toStr := flag.String("to", "", "last day of the export, YYYY-MM-DD")
+	format := flag.String("format", accounting.FormatCSV, "export format: csv or xml")
+	columns := flag.String("columns", "", "comma separated CSV columns, overrides ACCOUNTING_CSV_COLUMNS")
+	output := flag.String("o", "", "output file, stdout when empty")
+	includeExported := flag.Bool("include-exported", false, "include orders that were exported before")
+	markExported := flag.Bool("mark", true, "mark the exported orders so they are not exported again")
+
+	cfg, err := config.ReadConfig()
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msgf("Failed to read the config.")
+	}
+
+	from, err := time.Parse("2006-01-02", *fromStr)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Invalid -from date, expected YYYY-MM-DD")
+	}
+
+	to, err := time.Parse("2006-01-02", *toStr)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Invalid -to date, expected YYYY-MM-DD")
+	}
+
+	dbStorage, dbpool, err := repo.CreateRepo(ctx, cfg)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msgf("Error occurred while repository was initiating.")
+	}
+	defer dbpool.Close()
+
+	req := accounting.ExportRequest{
+		From:            from,
+		To:              to,
+		Format:          *format,
+		Columns:         cfg.AccountingCSVColumns,
+		IncludeExported: *includeExported,
+		MarkExported:    *markExported,
+	}
+	if *columns != "" {
+		req.Columns = strings.Split(*columns, ",")
+	}
+	if cfg.AccountingCSVDelimiter != "" {
+		req.Delimiter = rune(cfg.AccountingCSVDelimiter[0])
+	}
+
+	settings := accounting.Settings{
+		Currency: cfg.Currency,
+		VATCodes: accounting.VATCodes(cfg.AccountingVATCodes),
+		VATRate:  util.VATRate,
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			l.Fatal().Err(err).Msg("Could not create the output file")
+		}
+		defer f.Close()
+		w = f
+	}
+
+	result, err := accounting.Export(ctx, dbStorage, settings, req, w)
+	if err != nil {
+		l.Fatal().Err(err).Msg("Export failed")
+	}
+
+	if len(result.Unpriced) > 0 {
+		l.Warn().Ints("orders", result.Unpriced).Msg("Orders without line prices were left out")
+	}
+	l.Info().Msgf("Exported %d orders", result.Exported)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// runDealers compares the prices of two dealers, like
+// /api/handle-dealer-csv.
+func runDealers(t *tool, args []string) error {
+	fs := flag.NewFlagSet("dealers", flag.ExitOnError)
+	dealerOneFlags := newFileFlags(fs, "dealerOne", "dealerOneDelimiter", "price list of dealer one, or an earlier comparison")
+	dealerTwoFlags := newFileFlags(fs, "dealerTwo", "dealerTwoDelimiter", "price list of dealer two")
+	dealerOneOrder := fs.String("dealerOnePriceAndCodeOrder", "", "price and code columns of dealer one, e.g. 2,1 or Price,Code")
+	dealerTwoOrder := fs.String("dealerTwoPriceAndCodeOrder", "", "price and code columns of dealer two")
+	dealerColumnRef := fs.String("dealerColumn", "", "dealer number column of dealer one")
+	secondDealerNumber := fs.Int("secondDealerNumber", 0, "dealer number of dealer two, used with -dealerColumn")
+	offsetPercentage := fs.Int("offsetPercentage", 0, "percent added to the prices of dealer two")
+	firstDealerNumber := fs.String("firstDealerNumber", "", "dealer number of dealer one")
+	output := newOutputFlags(fs)
+	fs.Parse(args)
+
+	dealerOnePriceRef, dealerOneCodeRef, ok := columns.SplitPair(*dealerOneOrder)
+	if !ok {
+		return errors.New("invalid order values of dealer one")
+	}
+	dealerTwoPriceRef, dealerTwoCodeRef, ok := columns.SplitPair(*dealerTwoOrder)
+	if !ok {
+		return errors.New("invalid order values of dealer two")
+	}
+
+	dealerOne, err := t.open(dealerOneFlags)
+	if err != nil {
+		return err
+	}
+	defer dealerOne.Close()
+
+	dealerTwo, err := t.open(dealerTwoFlags)
+	if err != nil {
+		return err
+	}
+	defer dealerTwo.Close()
+
+	dealerOneRefs := []string{dealerOnePriceRef, dealerOneCodeRef}
+	if *dealerColumnRef != "" {
+		dealerOneRefs = append(dealerOneRefs, *dealerColumnRef)
+	}
+	dealerOneColumns, err := t.columnIndexes(dealerOne, dealerOneRefs...)
+	if err != nil {
+		return err
+	}
+	dealerColumn := -1
+	if *dealerColumnRef != "" {
+		dealerColumn = dealerOneColumns[2]
+	}
+
+	dealerTwoColumns, err := t.columnIndexes(dealerTwo, dealerTwoPriceRef, dealerTwoCodeRef)
+	if err != nil {
+		return err
+	}
+
+	dealerOneReader, err := dealerOne.Reader()
+	if err != nil {
+		return err
+	}
+	dealerTwoReader, err := dealerTwo.Reader()
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(*output.report)
+
+	// dealer two is the lookup side, dealer one is streamed to the output
+	dealerOneRep := dealerOne.report(rep)
+	d2, err := t.service.ReadFileToMap(t.ctx, dealerTwoReader, dealerTwoColumns[0], dealerTwoColumns[1], dealerTwo.report(rep))
+	if err != nil {
+		return err
+	}
+
+	comparison := services.DealerComparison{
+		PriceIndex:         dealerOneColumns[0],
+		CodeIndex:          dealerOneColumns[1],
+		DealerColumn:       dealerColumn,
+		FirstDealerNumber:  *firstDealerNumber,
+		SecondDealerNumber: *secondDealerNumber,
+		OffsetPercentage:   *offsetPercentage,
+	}
+
+	opts := table.WriterOptions{NumericColumns: []string{"Best Price", "Second Price", "Price Ratio"}}
+	return output.write(opts, rep, func(writer table.Writer) error {
+		return t.service.CompareAndProcessFiles(t.ctx, dealerOneReader, d2, comparison, writer, dealerOneRep, rep)
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/trunov/virena/internal/app/repo"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/util"
+)
+
+// importBatch is how many products are saved in one transaction.
+const importBatch = 5000
+
+// runImport saves the products of a catalog file in the products table,
+// updating the codes stored already.
+func runImport(t *tool, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	catalogFlags := newFileFlags(fs, "catalog", "delimiter", "catalog file")
+	codeColumn := fs.String("codeColumn", "1", "part code column")
+	priceColumn := fs.String("priceColumn", "2", "price column")
+	descriptionColumn := fs.String("descriptionColumn", "", "description column")
+	noteColumn := fs.String("noteColumn", "", "note column")
+	weightColumn := fs.String("weightColumn", "", "weight column")
+	brandColumn := fs.String("brandColumn", "", "brand column, up to 3 letters")
+	dryRun := fs.Bool("dry-run", false, "read and check the catalog without saving it")
+	reportPath := fs.String("report", "", "write the validation report as JSON to this file")
+	fs.Parse(args)
+
+	if t.cfg.DatabaseURI == "" && !*dryRun {
+		return repo.ErrMissingDBURI
+	}
+
+	catalog, err := t.open(catalogFlags)
+	if err != nil {
+		return err
+	}
+	defer catalog.Close()
+
+	refs := []string{*codeColumn, *priceColumn}
+	for _, ref := range []string{*descriptionColumn, *noteColumn, *weightColumn, *brandColumn} {
+		if ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	indexes, err := t.columnIndexes(catalog, refs...)
+	if err != nil {
+		return err
+	}
+
+	c := services.CatalogColumns{Code: indexes[0], Price: indexes[1], Description: -1, Note: -1, Weight: -1, Brand: -1}
+	next := indexes[2:]
+	for _, column := range []struct {
+		ref   string
+		index *int
+	}{
+		{*descriptionColumn, &c.Description},
+		{*noteColumn, &c.Note},
+		{*weightColumn, &c.Weight},
+		{*brandColumn, &c.Brand},
+	} {
+		if column.ref != "" {
+			*column.index, next = next[0], next[1:]
+		}
+	}
+
+	reader, err := catalog.Reader()
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(*reportPath)
+
+	save := func([]util.GetProductResponse) error { return nil }
+	if !*dryRun {
+		dbStorage, dbpool, err := repo.CreateRepo(t.ctx, t.cfg)
+		if err != nil {
+			return err
+		}
+		defer dbpool.Close()
+
+		save = func(products []util.GetProductResponse) error {
+			return dbStorage.SaveProducts(t.ctx, products)
+		}
+	}
+
+	var saved int
+	batch := make([]util.GetProductResponse, 0, importBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := save(batch); err != nil {
+			return err
+		}
+		saved += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err = t.service.ReadCatalog(t.ctx, reader, c, catalog.report(rep), func(p util.GetProductResponse) error {
+		batch = append(batch, p)
+		if len(batch) == cap(batch) {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("%w, %d products were saved before", err, saved)
+	}
+
+	if *dryRun {
+		t.logger.Info().Msgf("Read %d products", saved)
+	} else {
+		t.logger.Info().Msgf("Saved %d products", saved)
+	}
+
+	return saveReport(*reportPath, rep)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// fileFlags are the flags of one input file: its path under the name of the
+// form field, the delimiter and the <field>Encoding and <field>Sheet values.
+type fileFlags struct {
+	field     string
+	path      *string
+	delimiter *string
+	encoding  *string
+	sheet     *string
+}
+
+func newFileFlags(fs *flag.FlagSet, field, delimiterFlag, usage string) *fileFlags {
+	f := &fileFlags{field: field}
+	f.path = fs.String(field, "", usage)
+	f.delimiter = fs.String(delimiterFlag, "", "delimiter of the "+field+" CSV file, a character or tab; sniffed when empty")
+	f.encoding = fs.String(field+"Encoding", "", "encoding of the "+field+" CSV file, detected when empty")
+	f.sheet = fs.String(field+"Sheet", "", "sheet of the "+field+" workbook, by name or number")
+	return f
+}
+
+// input is a local CSV file or workbook, read like an upload of the server.
+type input struct {
+	field string
+	file  *os.File
+	opts  table.Options
+	info  table.Info
+}
+
+func (t *tool) open(f *fileFlags) (*input, error) {
+	if *f.path == "" {
+		return nil, fmt.Errorf("-%s is required", f.field)
+	}
+
+	delimiter, err := table.ParseDelimiter(*f.delimiter)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.field, err)
+	}
+	if !table.ValidEncoding(*f.encoding) {
+		return nil, fmt.Errorf("%s: unsupported encoding %q", f.field, *f.encoding)
+	}
+
+	file, err := os.Open(*f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	in := &input{
+		field: f.field,
+		file:  file,
+		opts: table.Options{
+			Delimiter:      delimiter,
+			Sheet:          *f.sheet,
+			Encoding:       *f.encoding,
+			LegacyEncoding: t.cfg.CSVLegacyEncoding,
+		},
+	}
+
+	in.info, err = table.Detect(file, file.Name(), in.opts)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", f.field, err)
+	}
+	if in.info.Format == table.FormatCSV {
+		t.logger.Info().Msgf("%s: %s", f.field, in.info)
+	}
+
+	return in, nil
+}
+
+func (in *input) Close() error {
+	return in.file.Close()
+}
+
+// Reader reads the file from its header row.
+func (in *input) Reader() (table.Reader, error) {
+	reader, err := table.Open(in.file, in.file.Name(), in.info.Options(in.opts))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", in.field, err)
+	}
+	return reader, nil
+}
+
+func (in *input) report(rep *report.Report) *report.File {
+	return rep.File(in.field, in.info.Delimiter)
+}
+
+// readHeader returns the header row of the file.
+func (in *input) readHeader() ([]string, error) {
+	reader, err := table.Open(in.file, in.file.Name(), in.info.Options(in.opts))
+	if err != nil {
+		return nil, err
+	}
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read header row: %w", err)
+	}
+	return header, nil
+}
+
+// columnIndexes resolves 1-based positions or header names to 0-based
+// indexes, errors name the file.
+func (t *tool) columnIndexes(in *input, refs ...string) ([]int, error) {
+	indexes, err := t.columns.Indexes(in.readHeader, refs...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", in.field, err)
+	}
+	return indexes, nil
+}
+
+// outputFlags are where and how a result is written, and the path of the
+// validation report.
+type outputFlags struct {
+	path   *string
+	format *string
+	bom    *bool
+	report *string
+}
+
+func newOutputFlags(fs *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		path:   fs.String("o", "", "output file, stdout when empty"),
+		format: fs.String("outputFormat", table.FormatCSV, "output format: csv or xlsx"),
+		bom:    fs.Bool("outputBOM", false, "start CSV output with a BOM for Excel"),
+		report: fs.String("report", "", "write the validation report as JSON to this file"),
+	}
+}
+
+// newReport is nil without a report path, a nil report notes nothing.
+func newReport(path string) *report.Report {
+	if path == "" {
+		return nil
+	}
+	return report.New()
+}
+
+// saveReport writes the finished report to path, if there is one.
+func saveReport(path string, rep *report.Report) error {
+	if rep == nil {
+		return nil
+	}
+	rep.Finish()
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(rep)
+}
+
+// write creates the output, lets write fill it and saves the report.
+func (o *outputFlags) write(opts table.WriterOptions, rep *report.Report, write func(table.Writer) error) error {
+	switch format := strings.ToLower(*o.format); format {
+	case table.FormatCSV, table.FormatXLSX:
+		opts.Format = format
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+	opts.BOM = *o.bom
+
+	var w io.Writer = os.Stdout
+	if *o.path != "" {
+		f, err := os.Create(*o.path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	writer, err := table.NewWriter(w, opts)
+	if err != nil {
+		return err
+	}
+	if err := write(writer); err != nil {
+		return err
+	}
+	return saveReport(*o.report, rep)
+}
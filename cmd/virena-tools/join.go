@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// runJoin adds columns of source to the rows of target, like /api/join-csv.
+func runJoin(t *tool, args []string) error {
+	fs := flag.NewFlagSet("join", flag.ExitOnError)
+	sourceFlags := newFileFlags(fs, "source", "sourceDelimiter", "file the columns are taken from")
+	targetFlags := newFileFlags(fs, "target", "targetDelimiter", "file the columns are added to")
+	sourceKey := fs.String("sourceKey", "", "part code column of the source file")
+	targetKey := fs.String("targetKey", "", "part code column of the target file")
+	columnsValue := fs.String("columns", "", `source columns to add, renamed with =, e.g. "Hind=Price,Stock"`)
+	joinType := fs.String("joinType", services.JoinLeft, "left, inner or anti")
+	duplicates := fs.String("duplicates", services.DuplicatesFirst, "for keys on several source rows: first, last, error or all")
+	output := newOutputFlags(fs)
+	fs.Parse(args)
+
+	if err := services.ValidJoin(*joinType, *duplicates); err != nil {
+		return err
+	}
+
+	refs, headers := joinColumns(*columnsValue)
+	if len(refs) == 0 && *joinType != services.JoinAnti {
+		return errors.New("no source columns to join")
+	}
+
+	source, err := t.open(sourceFlags)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := t.open(targetFlags)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+
+	sourceColumns, err := t.columnIndexes(source, append([]string{*sourceKey}, refs...)...)
+	if err != nil {
+		return err
+	}
+	targetColumns, err := t.columnIndexes(target, *targetKey)
+	if err != nil {
+		return err
+	}
+
+	opts := services.JoinOptions{
+		SourceKey:  sourceColumns[0],
+		TargetKey:  targetColumns[0],
+		Columns:    sourceColumns[1:],
+		Headers:    headers,
+		Type:       *joinType,
+		Duplicates: *duplicates,
+	}
+
+	sourceReader, err := source.Reader()
+	if err != nil {
+		return err
+	}
+	targetReader, err := target.Reader()
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(*output.report)
+	// the output keeps the delimiter of the target, given or sniffed
+	writerOpts := table.WriterOptions{Delimiter: target.info.Delimiter}
+	return output.write(writerOpts, rep, func(writer table.Writer) error {
+		return t.service.Join(t.ctx, sourceReader, targetReader, opts, writer, source.report(rep), target.report(rep), rep)
+	})
+}
+
+// joinColumns splits "Hind=Price,Stock" into column references and their
+// output names, empty when not renamed.
+func joinColumns(value string) (refs, headers []string) {
+	for _, column := range strings.Split(value, ",") {
+		ref, name, _ := strings.Cut(column, "=")
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		refs = append(refs, ref)
+		headers = append(headers, strings.TrimSpace(name))
+	}
+	return refs, headers
+}
@@ -0,0 +1,91 @@
+// Command virena-tools runs the CSV tools of the server on local files:
+//
+//	virena-tools price -priceFile prices.csv -productFile products.csv ...
+//	virena-tools dealers -dealerOne one.csv -dealerTwo two.csv ...
+//	virena-tools join -source stock.csv -target products.csv ...
+//	virena-tools import -catalog catalog.csv ...
+//
+// Flags are named after the form fields of the HTTP tools. Results go to
+// stdout unless -o is given. Column synonyms and part code rules are read
+// from the environment like the server does, import also needs
+// DATABASE_URI.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/config"
+	"github.com/trunov/virena/internal/app/partcode"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/logger"
+)
+
+type tool struct {
+	ctx     context.Context
+	cfg     config.Config
+	service services.FileService
+	columns *columns.Resolver
+	logger  zerolog.Logger
+}
+
+var commands = map[string]func(t *tool, args []string) error{
+	"price":   runPrice,
+	"dealers": runDealers,
+	"join":    runJoin,
+	"import":  runImport,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: virena-tools <price|dealers|join|import> [flags]")
+	fmt.Fprintln(os.Stderr, "run a command with -h for its flags")
+}
+
+func main() {
+	l := logger.Get()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	run, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.ReadEnv()
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msgf("Failed to read the config.")
+	}
+
+	partCodeRules, err := partcode.ParseBrandRules(cfg.PartCodeRules)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msg("Failed to read the part code rules.")
+	}
+
+	synonyms := make(map[string][]string)
+	for column, names := range cfg.ColumnSynonyms {
+		synonyms[column] = strings.Split(names, "|")
+	}
+
+	t := &tool{
+		ctx:     context.Background(),
+		cfg:     cfg,
+		service: services.NewFileService(partcode.New(partCodeRules)),
+		columns: columns.NewResolver(synonyms),
+		logger:  l,
+	}
+
+	if err := run(t, os.Args[2:]); err != nil {
+		l.Fatal().Err(err).Msgf("%s failed", os.Args[1])
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/markup"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// runPrice prices the products of productFile by priceFile, like
+// /api/handle-price-csv.
+func runPrice(t *tool, args []string) error {
+	fs := flag.NewFlagSet("price", flag.ExitOnError)
+	priceFlags := newFileFlags(fs, "priceFile", "priceDelimiter", "dealer price list")
+	productFlags := newFileFlags(fs, "productFile", "productDelimiter", "products to price")
+	priceAndCodeOrder := fs.String("priceAndCodeOrder", "", "price and code columns of the price file, e.g. 2,1 or Price,Code")
+	productOrder := fs.String("productOrder", "", "code column of the product file")
+	percentage := fs.String("percentage", "", "markup in percent, overrides the base percentage of -markup")
+	rules := fs.String("markup", "", "markup policy as JSON")
+	brandColumnRef := fs.String("brandColumn", "", "brand column of the product file, picks the brand tiers of the markup")
+	dealerColumnRef := fs.String("dealerColumn", "", "dealer column of the price file, copied to the result")
+	withAdditionalData := fs.Bool("withAdditionalData", false, "copy the worst price columns of an earlier dealer comparison")
+	output := newOutputFlags(fs)
+	fs.Parse(args)
+
+	policy, err := parseMarkup(*rules, *percentage)
+	if err != nil {
+		return err
+	}
+
+	priceRef, codeRef, ok := columns.SplitPair(*priceAndCodeOrder)
+	if !ok || strings.TrimSpace(*productOrder) == "" {
+		return errors.New("invalid order values, -priceAndCodeOrder and -productOrder are required")
+	}
+
+	priceFile, err := t.open(priceFlags)
+	if err != nil {
+		return err
+	}
+	defer priceFile.Close()
+
+	productFile, err := t.open(productFlags)
+	if err != nil {
+		return err
+	}
+	defer productFile.Close()
+
+	priceRefs := []string{priceRef, codeRef}
+	if *dealerColumnRef != "" {
+		priceRefs = append(priceRefs, *dealerColumnRef)
+	}
+	priceColumns, err := t.columnIndexes(priceFile, priceRefs...)
+	if err != nil {
+		return err
+	}
+
+	productRefs := []string{*productOrder}
+	if *brandColumnRef != "" {
+		productRefs = append(productRefs, *brandColumnRef)
+	}
+	productColumns, err := t.columnIndexes(productFile, productRefs...)
+	if err != nil {
+		return err
+	}
+
+	update := services.PriceUpdate{
+		PriceIndex:         priceColumns[0],
+		CodeIndex:          priceColumns[1],
+		DealerColumn:       -1,
+		WithAdditionalData: *withAdditionalData,
+		ProductCodeIndex:   productColumns[0],
+		BrandColumn:        -1,
+		Markup:             policy,
+	}
+	if *dealerColumnRef != "" {
+		update.DealerColumn = priceColumns[2]
+	}
+	if *brandColumnRef != "" {
+		update.BrandColumn = productColumns[1]
+	}
+
+	priceReader, err := priceFile.Reader()
+	if err != nil {
+		return err
+	}
+	productReader, err := productFile.Reader()
+	if err != nil {
+		return err
+	}
+
+	rep := newReport(*output.report)
+	opts := table.WriterOptions{NumericColumns: []string{"new price", "Worst Price", "Price Ratio"}}
+	return output.write(opts, rep, func(writer table.Writer) error {
+		return t.service.UpdatePrices(t.ctx, priceReader, productReader, update, writer, priceFile.report(rep), productFile.report(rep), rep)
+	})
+}
+
+// parseMarkup takes the policy as the form does: markup JSON, percentage
+// or both, the percentage replacing the base percentage of the policy.
+func parseMarkup(rules, percentage string) (markup.Policy, error) {
+	var p markup.Policy
+	switch {
+	case rules != "":
+		var err error
+		p, err = markup.Parse(rules)
+		if err != nil {
+			return p, err
+		}
+	case percentage == "":
+		return p, errors.New("-percentage or -markup is required")
+	}
+
+	if percentage != "" {
+		value, err := strconv.ParseFloat(percentage, 64)
+		if err != nil {
+			return p, errors.New("invalid percentage value")
+		}
+		p.Percentage = value
+	}
+
+	return p, nil
+}
@@ -0,0 +1,305 @@
+package accounting
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCSV = "csv"
+	FormatXML = "xml"
+)
+
+// DefaultCSVColumns is the layout used when no columns are configured.
+var DefaultCSVColumns = []string{"orderId", "date", "customer", "company", "vatNumber", "country", "partCode", "brand", "quantity", "price", "amount", "vatCode", "vatRate", "currency"}
+
+// Line is a single order item as it goes to the accounting system.
+// Unpriced lines come from orders placed before item prices were stored.
+type Line struct {
+	PartCode string
+	Brand    string
+	Quantity int
+	Price    float64
+	Amount   float64
+	Unpriced bool
+}
+
+// Order is an order with everything the accountant needs to book it.
+type Order struct {
+	ID          int
+	CreatedDate time.Time
+	PaidDate    *time.Time
+	Name        string
+	Email       string
+	PhoneNumber string
+	Company     string
+	VATNumber   string
+	Country     string
+	City        string
+	ZipCode     string
+	Address     string
+	Currency    string
+	VATCode     string
+	VATRate     float64
+	Items       []Line
+}
+
+// Priced tells whether every line has its price and amount, orders without
+// them cannot be booked.
+func (o Order) Priced() bool {
+	for _, item := range o.Items {
+		if item.Unpriced {
+			return false
+		}
+	}
+	return true
+}
+
+func (o Order) Subtotal() float64 {
+	var summ float64
+	for _, item := range o.Items {
+		summ += item.Amount
+	}
+	return summ
+}
+
+func (o Order) VATAmount() float64 {
+	return o.Subtotal() * o.VATRate
+}
+
+func (o Order) Total() float64 {
+	return o.Subtotal() + o.VATAmount()
+}
+
+// VATCodes maps a customer country to the VAT code of the accounting system.
+// The "default" key is used for countries without their own entry.
+type VATCodes map[string]string
+
+func (c VATCodes) For(country string) string {
+	if code, ok := c[country]; ok {
+		return code
+	}
+	return c["default"]
+}
+
+type csvColumn func(o Order, l Line) string
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+var csvColumns = map[string]csvColumn{
+	"orderId":     func(o Order, _ Line) string { return strconv.Itoa(o.ID) },
+	"date":        func(o Order, _ Line) string { return o.CreatedDate.Format("2006-01-02") },
+	"paidDate":    func(o Order, _ Line) string { return formatOptionalDate(o.PaidDate) },
+	"customer":    func(o Order, _ Line) string { return o.Name },
+	"email":       func(o Order, _ Line) string { return o.Email },
+	"phoneNumber": func(o Order, _ Line) string { return o.PhoneNumber },
+	"company":     func(o Order, _ Line) string { return o.Company },
+	"vatNumber":   func(o Order, _ Line) string { return o.VATNumber },
+	"country":     func(o Order, _ Line) string { return o.Country },
+	"city":        func(o Order, _ Line) string { return o.City },
+	"zipCode":     func(o Order, _ Line) string { return o.ZipCode },
+	"address":     func(o Order, _ Line) string { return o.Address },
+	"partCode":    func(_ Order, l Line) string { return l.PartCode },
+	"brand":       func(_ Order, l Line) string { return l.Brand },
+	"quantity":    func(_ Order, l Line) string { return strconv.Itoa(l.Quantity) },
+	"price":       func(_ Order, l Line) string { return money(l.Price) },
+	"amount":      func(_ Order, l Line) string { return money(l.Amount) },
+	"lineVat":     func(o Order, l Line) string { return money(l.Amount * o.VATRate) },
+	"lineTotal":   func(o Order, l Line) string { return money(l.Amount * (1 + o.VATRate)) },
+	"vatCode":     func(o Order, _ Line) string { return o.VATCode },
+	"vatRate":     func(o Order, _ Line) string { return strconv.FormatFloat(o.VATRate*100, 'f', -1, 64) },
+	"currency":    func(o Order, _ Line) string { return o.Currency },
+	"subtotal":    func(o Order, _ Line) string { return money(o.Subtotal()) },
+	"vatAmount":   func(o Order, _ Line) string { return money(o.VATAmount()) },
+	"total":       func(o Order, _ Line) string { return money(o.Total()) },
+}
+
+func formatOptionalDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// WriteCSV writes one row per order line with the given columns. Order level
+// columns are repeated on every line of the order.
+func WriteCSV(w io.Writer, orders []Order, columns []string, delimiter rune) error {
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+
+	fields := make([]csvColumn, len(columns))
+	for i, name := range columns {
+		field, ok := csvColumns[strings.TrimSpace(name)]
+		if !ok {
+			return fmt.Errorf("unknown export column %q, available columns: %s", name, strings.Join(AvailableColumns(), ", "))
+		}
+		fields[i] = field
+	}
+
+	writer := csv.NewWriter(w)
+	if delimiter != 0 {
+		writer.Comma = delimiter
+	}
+
+	if err := writer.Write(columns); err != nil {
+		return err
+	}
+
+	for _, o := range orders {
+		for _, l := range o.Items {
+			record := make([]string, len(fields))
+			for i, field := range fields {
+				record[i] = field(o, l)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// AvailableColumns lists the column names accepted by WriteCSV.
+func AvailableColumns() []string {
+	names := make([]string, 0, len(csvColumns))
+	for name := range csvColumns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type xmlInvoices struct {
+	XMLName  xml.Name     `xml:"SalesInvoices"`
+	Invoices []xmlInvoice `xml:"Invoice"`
+}
+
+type xmlInvoice struct {
+	Number    int         `xml:"Number"`
+	Date      string      `xml:"Date"`
+	PaidDate  string      `xml:"PaidDate,omitempty"`
+	Currency  string      `xml:"Currency"`
+	Customer  xmlCustomer `xml:"Customer"`
+	Lines     []xmlLine   `xml:"Lines>Line"`
+	Subtotal  string      `xml:"Subtotal"`
+	VATAmount string      `xml:"VATAmount"`
+	Total     string      `xml:"Total"`
+}
+
+type xmlCustomer struct {
+	Name        string `xml:"Name"`
+	Company     string `xml:"Company,omitempty"`
+	VATNumber   string `xml:"VATNumber,omitempty"`
+	Email       string `xml:"Email"`
+	PhoneNumber string `xml:"PhoneNumber,omitempty"`
+	Country     string `xml:"Country"`
+	City        string `xml:"City"`
+	ZipCode     string `xml:"ZipCode"`
+	Address     string `xml:"Address"`
+}
+
+type xmlLine struct {
+	Code      string `xml:"Code"`
+	Brand     string `xml:"Brand,omitempty"`
+	Quantity  int    `xml:"Quantity"`
+	UnitPrice string `xml:"UnitPrice"`
+	Amount    string `xml:"Amount"`
+	VATCode   string `xml:"VATCode"`
+	VATRate   string `xml:"VATRate"`
+	VATAmount string `xml:"VATAmount"`
+}
+
+// WriteXML writes the orders as a generic sales invoice document.
+func WriteXML(w io.Writer, orders []Order) error {
+	doc := xmlInvoices{Invoices: make([]xmlInvoice, 0, len(orders))}
+
+	for _, o := range orders {
+		invoice := xmlInvoice{
+			Number:   o.ID,
+			Date:     o.CreatedDate.Format("2006-01-02"),
+			PaidDate: formatOptionalDate(o.PaidDate),
+			Currency: o.Currency,
+			Customer: xmlCustomer{
+				Name:        o.Name,
+				Company:     o.Company,
+				VATNumber:   o.VATNumber,
+				Email:       o.Email,
+				PhoneNumber: o.PhoneNumber,
+				Country:     o.Country,
+				City:        o.City,
+				ZipCode:     o.ZipCode,
+				Address:     o.Address,
+			},
+			Subtotal:  money(o.Subtotal()),
+			VATAmount: money(o.VATAmount()),
+			Total:     money(o.Total()),
+		}
+
+		for _, l := range o.Items {
+			invoice.Lines = append(invoice.Lines, xmlLine{
+				Code:      l.PartCode,
+				Brand:     l.Brand,
+				Quantity:  l.Quantity,
+				UnitPrice: money(l.Price),
+				Amount:    money(l.Amount),
+				VATCode:   o.VATCode,
+				VATRate:   strconv.FormatFloat(o.VATRate*100, 'f', -1, 64),
+				VATAmount: money(l.Amount * o.VATRate),
+			})
+		}
+
+		doc.Invoices = append(doc.Invoices, invoice)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Write dispatches to WriteCSV or WriteXML by format.
+func Write(w io.Writer, format string, orders []Order, columns []string, delimiter rune) error {
+	switch format {
+	case FormatCSV, "":
+		return WriteCSV(w, orders, columns, delimiter)
+	case FormatXML:
+		return WriteXML(w, orders)
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
+}
+
+// Settings holds the accounting details that are not stored with the order.
+type Settings struct {
+	Currency string
+	VATCodes VATCodes
+	VATRate  float64
+}
+
+// Apply fills in the currency and VAT details of the orders.
+func (s Settings) Apply(orders []Order) {
+	for i := range orders {
+		orders[i].Currency = s.Currency
+		orders[i].VATCode = s.VATCodes.For(orders[i].Country)
+		orders[i].VATRate = s.VATRate
+	}
+}
@@ -0,0 +1,81 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+)
+
+// Store is the part of the database the export needs.
+type Store interface {
+	GetOrdersForExport(ctx context.Context, from, to time.Time, includeExported bool) ([]Order, error)
+	MarkOrdersExported(ctx context.Context, orderIDs []int) error
+}
+
+// ExportRequest describes a single export run. To is inclusive, so a request
+// for 2024-01-01..2024-01-31 covers the whole of January.
+type ExportRequest struct {
+	From            time.Time
+	To              time.Time
+	Format          string
+	Columns         []string
+	Delimiter       rune
+	IncludeExported bool
+	MarkExported    bool
+}
+
+// ExportResult tells which orders went into an export.
+type ExportResult struct {
+	Exported int
+	// Unpriced are the ids of orders left out because some of their lines
+	// have no price, they are never marked as exported.
+	Unpriced []int
+}
+
+// Export writes the orders of the requested range to w and, when asked,
+// marks them as exported. The document is built first and the orders are
+// only marked when w took all of it without an error, so an export that
+// failed can simply be repeated. IncludeExported exports a range again.
+// Orders without line prices would be booked as zero and are skipped.
+func Export(ctx context.Context, store Store, settings Settings, req ExportRequest, w io.Writer) (ExportResult, error) {
+	all, err := store.GetOrdersForExport(ctx, req.From, req.To.AddDate(0, 0, 1), req.IncludeExported)
+	if err != nil {
+		return ExportResult{}, err
+	}
+
+	var result ExportResult
+	orders := make([]Order, 0, len(all))
+	for _, o := range all {
+		if !o.Priced() {
+			result.Unpriced = append(result.Unpriced, o.ID)
+			continue
+		}
+		orders = append(orders, o)
+	}
+
+	settings.Apply(orders)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, req.Format, orders, req.Columns, req.Delimiter); err != nil {
+		return result, err
+	}
+
+	if _, err := buf.WriteTo(w); err != nil {
+		return result, err
+	}
+
+	if req.MarkExported && len(orders) > 0 {
+		ids := make([]int, len(orders))
+		for i, o := range orders {
+			ids[i] = o.ID
+		}
+
+		if err := store.MarkOrdersExported(ctx, ids); err != nil {
+			return result, err
+		}
+	}
+
+	result.Exported = len(orders)
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStore struct {
+	orders []Order
+	marked []int
+}
+
+func (s *fakeStore) GetOrdersForExport(ctx context.Context, from, to time.Time, includeExported bool) ([]Order, error) {
+	return s.orders, nil
+}
+
+func (s *fakeStore) MarkOrdersExported(ctx context.Context, orderIDs []int) error {
+	s.marked = append(s.marked, orderIDs...)
+	return nil
+}
+
+func TestExportSkipsUnpricedOrders(t *testing.T) {
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeStore{orders: []Order{
+		{ID: 1, CreatedDate: created, Items: []Line{{PartCode: "A1", Quantity: 1, Price: 10, Amount: 10}}},
+		{ID: 2, CreatedDate: created, Items: []Line{{PartCode: "B2", Quantity: 2, Unpriced: true}}},
+		{ID: 3, CreatedDate: created, Items: []Line{{PartCode: "C3", Quantity: 1, Price: 5, Amount: 5}, {PartCode: "D4", Quantity: 1, Unpriced: true}}},
+	}}
+
+	var buf bytes.Buffer
+	req := ExportRequest{From: created, To: created, Format: FormatCSV, Columns: []string{"orderId", "partCode"}, Delimiter: ';', MarkExported: true}
+	result, err := Export(context.Background(), store, Settings{}, req, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Exported != 1 || !reflect.DeepEqual(result.Unpriced, []int{2, 3}) {
+		t.Errorf("Export() = %+v, want 1 exported and orders 2, 3 unpriced", result)
+	}
+	if !reflect.DeepEqual(store.marked, []int{1}) {
+		t.Errorf("marked orders %v, want [1]", store.marked)
+	}
+	if out := buf.String(); strings.Contains(out, "B2") || strings.Contains(out, "C3") {
+		t.Errorf("export contains unpriced orders:\n%s", out)
+	}
+}
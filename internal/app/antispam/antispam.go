@@ -0,0 +1,130 @@
+package antispam
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	ReasonHoneypot    = "honeypot"
+	ReasonTooFast     = "too_fast"
+	ReasonStaleForm   = "stale_form"
+	ReasonNoTimestamp = "no_timestamp"
+	ReasonIPLimit     = "ip_rate_limit"
+	ReasonEmailLimit  = "email_rate_limit"
+	ReasonCaptcha     = "captcha"
+	ReasonCaptchaDown = "captcha_unavailable"
+)
+
+var rejectedSubmissions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "virena_contact_rejected_total",
+	Help: "Contact form submissions rejected by the spam protection, by reason.",
+}, []string{"reason"})
+
+// Rejection explains why a submission was not accepted. Silent rejections
+// are answered as if the submission went through, so bots learn nothing.
+type Rejection struct {
+	Reason string
+	Silent bool
+	Err    error
+}
+
+func (r *Rejection) Error() string {
+	if r.Err != nil {
+		return r.Reason + ": " + r.Err.Error()
+	}
+	return r.Reason
+}
+
+func (r *Rejection) Unwrap() error {
+	return r.Err
+}
+
+// Submission holds the parts of a contact form the guard looks at.
+type Submission struct {
+	RemoteIP     string
+	Email        string
+	Honeypot     string
+	RenderedAt   string
+	CaptchaToken string
+}
+
+type Options struct {
+	MinSubmitTime time.Duration
+	MaxFormAge    time.Duration
+	IPLimit       *RateLimiter
+	EmailLimit    *RateLimiter
+	Captcha       CaptchaVerifier
+}
+
+type Guard struct {
+	opts Options
+	now  func() time.Time
+}
+
+func NewGuard(opts Options) *Guard {
+	return &Guard{opts: opts, now: time.Now}
+}
+
+// Check runs the cheap checks first and the captcha last, so that floods are
+// stopped before they reach the captcha provider. Every rejection is counted.
+func (g *Guard) Check(ctx context.Context, s Submission) error {
+	rejection := g.check(ctx, s)
+	if rejection != nil {
+		rejectedSubmissions.WithLabelValues(rejection.Reason).Inc()
+		return rejection
+	}
+	return nil
+}
+
+func (g *Guard) check(ctx context.Context, s Submission) *Rejection {
+	if s.Honeypot != "" {
+		return &Rejection{Reason: ReasonHoneypot, Silent: true}
+	}
+
+	// a form without its render time would skip the timing checks. It is
+	// refused openly, an outdated form page gets it too and the customer
+	// has to know the message was not sent
+	if g.opts.MinSubmitTime > 0 || g.opts.MaxFormAge > 0 {
+		if s.RenderedAt == "" {
+			return &Rejection{Reason: ReasonNoTimestamp}
+		}
+		renderedAtMs, err := strconv.ParseInt(s.RenderedAt, 10, 64)
+		if err != nil {
+			return &Rejection{Reason: ReasonNoTimestamp, Err: err}
+		}
+
+		elapsed := g.now().Sub(time.UnixMilli(renderedAtMs))
+		if g.opts.MinSubmitTime > 0 && elapsed < g.opts.MinSubmitTime {
+			return &Rejection{Reason: ReasonTooFast, Silent: true}
+		}
+		if g.opts.MaxFormAge > 0 && elapsed > g.opts.MaxFormAge {
+			return &Rejection{Reason: ReasonStaleForm}
+		}
+	}
+
+	if !g.opts.IPLimit.Allow(s.RemoteIP) {
+		return &Rejection{Reason: ReasonIPLimit}
+	}
+
+	if !g.opts.EmailLimit.Allow(strings.ToLower(strings.TrimSpace(s.Email))) {
+		return &Rejection{Reason: ReasonEmailLimit}
+	}
+
+	if g.opts.Captcha != nil {
+		if err := g.opts.Captcha.Verify(ctx, s.CaptchaToken, s.RemoteIP); err != nil {
+			if errors.Is(err, ErrCaptchaFailed) {
+				return &Rejection{Reason: ReasonCaptcha, Err: err}
+			}
+			return &Rejection{Reason: ReasonCaptchaDown, Err: err}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package antispam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrCaptchaFailed = errors.New("captcha verification failed")
+
+// CaptchaVerifier checks the token a captcha widget put into the form.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, token, remoteIP string) error
+}
+
+// siteVerify talks to the siteverify API shared by reCAPTCHA, hCaptcha and
+// Cloudflare Turnstile.
+type siteVerify struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewSiteVerify(verifyURL, secret string) CaptchaVerifier {
+	return &siteVerify{
+		url:    verifyURL,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (v *siteVerify) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" {
+		return ErrCaptchaFailed
+	}
+
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {token},
+	}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("captcha verification request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("captcha verification response: %w", err)
+	}
+
+	if !result.Success {
+		return fmt.Errorf("%w: %s", ErrCaptchaFailed, strings.Join(result.ErrorCodes, ", "))
+	}
+
+	return nil
+}
+
+// FakeCaptcha accepts a single fixed token. It stands in for a real provider
+// in local development.
+type FakeCaptcha struct {
+	Token string
+}
+
+func (f FakeCaptcha) Verify(ctx context.Context, token, remoteIP string) error {
+	if token == "" || token != f.Token {
+		return ErrCaptchaFailed
+	}
+	return nil
+}
+
+// NewCaptcha picks the verifier for a provider setting: "" disables the
+// captcha, "fake" uses FakeCaptcha and "siteverify" a real provider.
+func NewCaptcha(provider, verifyURL, secret, fakeToken string) (CaptchaVerifier, error) {
+	switch provider {
+	case "":
+		return nil, nil
+	case "fake":
+		return FakeCaptcha{Token: fakeToken}, nil
+	case "siteverify":
+		if verifyURL == "" || secret == "" {
+			return nil, errors.New("siteverify captcha needs a verify URL and a secret")
+		}
+		return NewSiteVerify(verifyURL, secret), nil
+	default:
+		return nil, fmt.Errorf("unknown captcha provider %q", provider)
+	}
+}
@@ -0,0 +1,73 @@
+package antispam
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter allows up to limit events per key within a fixed window. It is
+// kept in memory, which is enough for a single replica.
+type RateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	start time.Time
+	count int
+}
+
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+// A limiter with a non-positive limit allows everything.
+func (l *RateLimiter) Allow(key string) bool {
+	if l == nil || l.limit <= 0 || key == "" {
+		return true
+	}
+
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || now.Sub(b.start) >= l.window {
+		b = &bucket{start: now}
+		l.buckets[key] = b
+	}
+
+	if b.count >= l.limit {
+		return false
+	}
+
+	b.count++
+	return true
+}
+
+// sweep drops expired buckets once per window so the map does not grow
+// with every address that ever posted.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.start) >= l.window {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,110 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+var ErrPolicy = errors.New("attachment rejected by policy")
+
+// Policy limits what can be uploaded with the contact form. The content type
+// is sniffed from the file itself, the name and the declared type are ignored.
+type Policy struct {
+	MaxFiles     int
+	MaxFileSize  int64
+	MaxTotalSize int64
+	AllowedTypes []string
+}
+
+// Checked is an uploaded file that passed the policy.
+type Checked struct {
+	Header      *multipart.FileHeader
+	ContentType string
+}
+
+// Check validates the uploaded files against the policy. The returned error
+// wraps ErrPolicy and is safe to show to the customer.
+func (p Policy) Check(headers []*multipart.FileHeader) ([]Checked, error) {
+	if p.MaxFiles > 0 && len(headers) > p.MaxFiles {
+		return nil, fmt.Errorf("%w: at most %d files can be attached", ErrPolicy, p.MaxFiles)
+	}
+
+	var total int64
+	checked := make([]Checked, 0, len(headers))
+
+	for _, header := range headers {
+		if p.MaxFileSize > 0 && header.Size > p.MaxFileSize {
+			return nil, fmt.Errorf("%w: %s is larger than %s", ErrPolicy, header.Filename, formatSize(p.MaxFileSize))
+		}
+
+		total += header.Size
+		if p.MaxTotalSize > 0 && total > p.MaxTotalSize {
+			return nil, fmt.Errorf("%w: attachments are larger than %s in total", ErrPolicy, formatSize(p.MaxTotalSize))
+		}
+
+		contentType, err := sniff(header)
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.allowed(contentType) {
+			return nil, fmt.Errorf("%w: %s has a file type that is not accepted (%s)", ErrPolicy, header.Filename, contentType)
+		}
+
+		checked = append(checked, Checked{Header: header, ContentType: contentType})
+	}
+
+	return checked, nil
+}
+
+func (p Policy) allowed(contentType string) bool {
+	if len(p.AllowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range p.AllowedTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(allowed, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sniff(header *multipart.FileHeader) (string, error) {
+	file, err := header.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	return http.DetectContentType(head[:n]), nil
+}
+
+func formatSize(size int64) string {
+	const mb = 1 << 20
+	if size >= mb {
+		return fmt.Sprintf("%dMB", size/mb)
+	}
+	return fmt.Sprintf("%dKB", size/1024)
+}
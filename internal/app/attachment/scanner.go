@@ -0,0 +1,111 @@
+package attachment
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrInfected = errors.New("attachment is infected")
+
+// Scanner checks a file for malware. An infected file is reported with an
+// error wrapping ErrInfected, any other error means the scan did not happen.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) error
+}
+
+const clamdChunkSize = 64 << 10
+
+// clamd speaks the INSTREAM command of the ClamAV daemon.
+type clamd struct {
+	network string
+	addr    string
+	timeout time.Duration
+}
+
+// NewClamd connects to clamd at addr, either host:port or the path of a
+// unix socket.
+func NewClamd(addr string, timeout time.Duration) Scanner {
+	network := "tcp"
+	if strings.HasPrefix(addr, "/") {
+		network = "unix"
+	}
+	return &clamd{network: network, addr: addr, timeout: timeout}
+}
+
+func (c *clamd) Scan(ctx context.Context, r io.Reader) error {
+	dialer := net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, c.network, c.addr)
+	if err != nil {
+		return fmt.Errorf("connect to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return fmt.Errorf("send INSTREAM: %w", err)
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return fmt.Errorf("send chunk: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return fmt.Errorf("send chunk: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	// a zero length chunk ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return fmt.Errorf("end stream: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("read clamd reply: %w", err)
+	}
+
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply understands "stream: OK", "stream: <signature> FOUND" and
+// "<message> ERROR" replies.
+func parseClamdReply(reply string) error {
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+
+	switch {
+	case result == "OK":
+		return nil
+	case strings.HasSuffix(result, " FOUND"):
+		return fmt.Errorf("%w: %s", ErrInfected, strings.TrimSuffix(result, " FOUND"))
+	case strings.HasSuffix(result, " ERROR"):
+		return fmt.Errorf("clamd error: %s", strings.TrimSuffix(result, " ERROR"))
+	default:
+		return fmt.Errorf("unexpected clamd reply %q", reply)
+	}
+}
@@ -0,0 +1,132 @@
+package bank
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FormatCamt = "camt"
+	FormatCSV  = "csv"
+)
+
+const (
+	MatchedByReference   = "reference"
+	MatchedByDescription = "description"
+	MatchedByAmountPayer = "amount_payer"
+)
+
+var ErrUnknownFormat = errors.New("unknown bank statement format")
+
+// Payment is a single incoming credit taken from a bank statement.
+type Payment struct {
+	EntryRef    string    `json:"entryRef"`
+	BookingDate time.Time `json:"bookingDate"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency"`
+	PayerName   string    `json:"payerName"`
+	Reference   string    `json:"reference"`
+	Description string    `json:"description"`
+	Source      string    `json:"source"`
+	OrderID     int       `json:"orderId,omitempty"`
+	MatchedBy   string    `json:"matchedBy,omitempty"`
+	// Partial is set when the payments of the order do not cover its total
+	// yet, the order stays unpaid and the payment is left for review.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// OpenOrder is an order that has not been paid yet. Paid is what earlier
+// partial payments brought in, Total is 0 for orders saved without one.
+type OpenOrder struct {
+	ID      int
+	Name    string
+	Company string
+	Total   float64
+	Paid    float64
+}
+
+// Due is what is left to pay.
+func (o OpenOrder) Due() float64 {
+	return o.Total - o.Paid
+}
+
+// covered reports whether the payments cover the order. Orders without a
+// total are never covered, a person has to check them.
+func (o OpenOrder) covered() bool {
+	return o.Total > 0 && o.Due() < 0.005
+}
+
+// Parse reads a statement in the given format. An empty format is detected
+// from the content: XML documents are treated as camt.053, anything else as CSV.
+func Parse(r io.Reader, format string) ([]Payment, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = detectFormat(content)
+	}
+
+	switch format {
+	case FormatCamt:
+		return ParseCamt053(bytes.NewReader(content))
+	case FormatCSV:
+		return ParseCSV(bytes.NewReader(content))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+func detectFormat(content []byte) string {
+	trimmed := bytes.TrimLeft(content, "\ufeff \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return FormatCamt
+	}
+	return FormatCSV
+}
+
+// entryRef makes a stable identifier for payments whose statement does not
+// carry a bank reference, so that importing the same file twice is harmless.
+func entryRef(p Payment) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s|%.2f|%s|%s|%s|%s", p.BookingDate.Format("2006-01-02"), p.Amount, p.Currency, p.PayerName, p.Reference, p.Description)
+	return "sha1:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func parseAmount(s string) (float64, error) {
+	s = strings.ReplaceAll(s, "\u00A0", "")
+	s = strings.ReplaceAll(s, " ", "")
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, ",") && strings.Contains(s, ".") {
+		// thousands separator is whichever comes first
+		if strings.Index(s, ",") < strings.Index(s, ".") {
+			s = strings.ReplaceAll(s, ",", "")
+		} else {
+			s = strings.ReplaceAll(s, ".", "")
+		}
+	}
+	s = strings.ReplaceAll(s, ",", ".")
+
+	return strconv.ParseFloat(s, 64)
+}
+
+var dateLayouts = []string{"2006-01-02", "02.01.2006", "02/01/2006", "2.1.2006", "2006-01-02T15:04:05", time.RFC3339}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized date %q", s)
+}
@@ -0,0 +1,190 @@
+package bank
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"100", 100},
+		{"100,50", 100.5},
+		{"100.50", 100.5},
+		{"1 234,56", 1234.56},
+		{"1\u00a0234,56", 1234.56},
+		{"1.234,56", 1234.56},
+		{"1,234.56", 1234.56},
+		{"-12,30", -12.3},
+	}
+	for _, tt := range tests {
+		got, err := parseAmount(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseAmount(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Payment
+	}{
+		{
+			"estonian semicolon export",
+			"\ufeffKuupäev;Saaja/Maksja;Selgitus;Summa;Valuuta;Deebet/Kreedit (D/C);Viitenumber;Arhiveerimistunnus\n" +
+				"18.10.2026;Jaan Tamm;tellimus 12345;100,00;EUR;C;123453;A1\n" +
+				"18.10.2026;Elektrilevi;arve;-25,00;EUR;D;;A2\n" +
+				"18.10.2026;;Lõppsaldo;;EUR;;;\n",
+			[]Payment{{EntryRef: "csv:A1", Amount: 100, Currency: "EUR", PayerName: "Jaan Tamm", Reference: "123453", Description: "tellimus 12345"}},
+		},
+		{
+			"english comma export in a foreign currency",
+			"Date,Payer,Amount,Currency,Reference,Description\n" +
+				"2026-10-18,John Smith,\"1,250.00\",USD,,Invoice 23456\n",
+			[]Payment{{Amount: 1250, Currency: "USD", PayerName: "John Smith", Description: "Invoice 23456"}},
+		},
+		{
+			"no currency column",
+			"date;amount;payer\n18/10/2026;12,5;Mari\n",
+			[]Payment{{Amount: 12.5, Currency: "EUR", PayerName: "Mari"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseCSV(strings.NewReader(tt.in))
+		if err != nil {
+			t.Errorf("%s: ParseCSV() error = %v", tt.name, err)
+			continue
+		}
+		checkPayments(t, tt.name, got, tt.want, FormatCSV)
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	tests := []struct {
+		name, in string
+	}{
+		{"no amount column", "date;payer\n18.10.2026;Mari\n"},
+		{"invalid amount", "date;amount\n18.10.2026;sada\n"},
+		{"invalid date", "date;amount\n2026.18.10;100\n"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseCSV(strings.NewReader(tt.in)); err == nil {
+			t.Errorf("%s: ParseCSV() error = nil, want one", tt.name)
+		}
+	}
+}
+
+const camtFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+<BkToCstmrStmt><Stmt>
+<Ntry>
+	<NtryRef>E1</NtryRef>
+	<Amt Ccy="EUR">100.00</Amt>
+	<CdtDbtInd>CRDT</CdtDbtInd>
+	<BookgDt><Dt>2026-10-18</Dt></BookgDt>
+	<NtryDtls><TxDtls>
+		<RltdPties><Dbtr><Nm>Jaan Tamm</Nm></Dbtr></RltdPties>
+		<RmtInf><Strd><CdtrRefInf><Ref>123453</Ref></CdtrRefInf></Strd></RmtInf>
+	</TxDtls></NtryDtls>
+</Ntry>
+<Ntry>
+	<NtryRef>E2</NtryRef>
+	<Amt Ccy="EUR">25.00</Amt>
+	<CdtDbtInd>DBIT</CdtDbtInd>
+	<BookgDt><Dt>2026-10-18</Dt></BookgDt>
+</Ntry>
+<Ntry>
+	<AcctSvcrRef>B3</AcctSvcrRef>
+	<Amt Ccy="EUR">300.00</Amt>
+	<CdtDbtInd>CRDT</CdtDbtInd>
+	<BookgDt><DtTm>2026-10-18T10:00:00</DtTm></BookgDt>
+	<NtryDtls>
+	<TxDtls>
+		<Refs><EndToEndId>P1</EndToEndId></Refs>
+		<AmtDtls><TxAmt><Amt Ccy="EUR">200.00</Amt></TxAmt></AmtDtls>
+		<RltdPties><Dbtr><Pty><Nm>Maasikas OÜ</Nm></Pty></Dbtr></RltdPties>
+		<RmtInf><Ustrd>order 23456</Ustrd></RmtInf>
+	</TxDtls>
+	<TxDtls>
+		<Refs><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+		<Amt Ccy="EUR">100.00</Amt>
+		<AddtlTxInf>second</AddtlTxInf>
+	</TxDtls>
+	</NtryDtls>
+</Ntry>
+<Ntry>
+	<NtryRef>E4</NtryRef>
+	<Amt Ccy="USD">50.00</Amt>
+	<CdtDbtInd>CRDT</CdtDbtInd>
+	<BookgDt><Dt>2026-10-18</Dt></BookgDt>
+	<AddtlNtryInf>Invoice 34567</AddtlNtryInf>
+</Ntry>
+</Stmt></BkToCstmrStmt>
+</Document>`
+
+func TestParseCamt053(t *testing.T) {
+	got, err := ParseCamt053(strings.NewReader(camtFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Payment{
+		{EntryRef: "camt:E1", Amount: 100, Currency: "EUR", PayerName: "Jaan Tamm", Reference: "123453"},
+		{EntryRef: "camt:B3/P1", Amount: 200, Currency: "EUR", PayerName: "Maasikas OÜ", Description: "order 23456"},
+		{EntryRef: "camt:B3/1", Amount: 100, Currency: "EUR", Description: "second"},
+		{EntryRef: "camt:E4", Amount: 50, Currency: "USD", Description: "Invoice 34567"},
+	}
+	checkPayments(t, "camt.053", got, want, FormatCamt)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name, in, format, source string
+	}{
+		{"detected camt", "\ufeff\n" + camtFixture, "", FormatCamt},
+		{"detected csv", "date;amount\n18.10.2026;100\n", "", FormatCSV},
+		{"given csv", "date;amount\n18.10.2026;100\n", FormatCSV, FormatCSV},
+	}
+	for _, tt := range tests {
+		got, err := Parse(strings.NewReader(tt.in), tt.format)
+		if err != nil || len(got) == 0 || got[0].Source != tt.source {
+			t.Errorf("%s: Parse() = %+v, %v, want %s payments", tt.name, got, err, tt.source)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader(""), "mt940"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Parse(mt940) error = %v, want ErrUnknownFormat", err)
+	}
+}
+
+// checkPayments compares the parsed fields of payments, all booked on
+// 2026-10-18. Payments without an EntryRef in want get a hashed one.
+func checkPayments(t *testing.T, name string, got, want []Payment, source string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: %d payments %+v, want %d", name, len(got), got, len(want))
+		return
+	}
+
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	for i, w := range want {
+		g := got[i]
+		if w.EntryRef == "" && strings.HasPrefix(g.EntryRef, "sha1:") {
+			w.EntryRef = g.EntryRef
+		}
+		w.BookingDate = g.BookingDate
+		w.Source = source
+		if g != w {
+			t.Errorf("%s: payment %d = %+v, want %+v", name, i, g, w)
+		}
+		if y, m, d := g.BookingDate.Date(); time.Date(y, m, d, 0, 0, 0, 0, time.UTC) != day {
+			t.Errorf("%s: payment %d booked on %v, want 2026-10-18", name, i, g.BookingDate)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package bank
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtEntry struct {
+	NtryRef     string       `xml:"NtryRef"`
+	AcctSvcrRef string       `xml:"AcctSvcrRef"`
+	Amount      camtAmount   `xml:"Amt"`
+	CdtDbtInd   string       `xml:"CdtDbtInd"`
+	BookingDate string       `xml:"BookgDt>Dt"`
+	BookingTime string       `xml:"BookgDt>DtTm"`
+	Details     []camtTxDtls `xml:"NtryDtls>TxDtls"`
+	AddtlInf    string       `xml:"AddtlNtryInf"`
+}
+
+type camtTxDtls struct {
+	AcctSvcrRef  string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndID   string     `xml:"Refs>EndToEndId"`
+	Amount       camtAmount `xml:"Amt"`
+	TxAmount     camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+	CdtDbtInd    string     `xml:"CdtDbtInd"`
+	DebtorName   string     `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty    string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Reference    string     `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+	Unstructured []string   `xml:"RmtInf>Ustrd"`
+	AddtlInf     string     `xml:"AddtlTxInf"`
+}
+
+// ParseCamt053 extracts incoming credits from an ISO 20022 camt.053 statement.
+// Batched entries produce one payment per transaction detail.
+func ParseCamt053(r io.Reader) ([]Payment, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var payments []Payment
+	for _, stmt := range doc.Statements {
+		for _, entry := range stmt.Entries {
+			if entry.CdtDbtInd != "CRDT" {
+				continue
+			}
+
+			date := entry.BookingDate
+			if date == "" {
+				date = entry.BookingTime
+			}
+			bookingDate, err := parseDate(date)
+			if err != nil {
+				return nil, err
+			}
+
+			details := entry.Details
+			if len(details) == 0 {
+				details = []camtTxDtls{{}}
+			}
+
+			for i, tx := range details {
+				if tx.CdtDbtInd != "" && tx.CdtDbtInd != "CRDT" {
+					continue
+				}
+
+				amount := entry.Amount
+				if len(entry.Details) > 1 {
+					if tx.TxAmount.Value != "" {
+						amount = tx.TxAmount
+					} else if tx.Amount.Value != "" {
+						amount = tx.Amount
+					}
+				}
+
+				value, err := parseAmount(amount.Value)
+				if err != nil {
+					return nil, err
+				}
+
+				payer := tx.DebtorName
+				if payer == "" {
+					payer = tx.DebtorPty
+				}
+
+				description := strings.TrimSpace(strings.Join(tx.Unstructured, " "))
+				if description == "" {
+					description = strings.TrimSpace(tx.AddtlInf)
+				}
+				if description == "" {
+					description = strings.TrimSpace(entry.AddtlInf)
+				}
+
+				p := Payment{
+					BookingDate: bookingDate,
+					Amount:      value,
+					Currency:    amount.Currency,
+					PayerName:   strings.TrimSpace(payer),
+					Reference:   strings.TrimSpace(tx.Reference),
+					Description: description,
+					Source:      FormatCamt,
+				}
+
+				p.EntryRef = camtEntryRef(entry, tx, i, len(details))
+				if p.EntryRef == "" {
+					p.EntryRef = entryRef(p)
+				}
+
+				payments = append(payments, p)
+			}
+		}
+	}
+
+	return payments, nil
+}
+
+func camtEntryRef(entry camtEntry, tx camtTxDtls, index, count int) string {
+	if tx.AcctSvcrRef != "" {
+		return "camt:" + tx.AcctSvcrRef
+	}
+
+	ref := entry.AcctSvcrRef
+	if ref == "" {
+		ref = entry.NtryRef
+	}
+	if ref == "" {
+		return ""
+	}
+
+	if count > 1 {
+		if tx.EndToEndID != "" && tx.EndToEndID != "NOTPROVIDED" {
+			return "camt:" + ref + "/" + tx.EndToEndID
+		}
+		return "camt:" + ref + "/" + strconv.Itoa(index)
+	}
+	return "camt:" + ref
+}
@@ -0,0 +1,122 @@
+package bank
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/util"
+)
+
+// orderNumberPattern finds an order number written as such in a payment
+// description, e.g. "order 12345", "tellimus nr 12345" or "#12345". Other
+// five digit numbers, such as postal codes, are not order numbers.
+var orderNumberPattern = regexp.MustCompile(`(?i)(?:\border|\btellimus\pL*|\btilaus\pL*|заказ\pL*|#)\s*(?:nr\.?|no\.?|number|№)?\s*[:#]?\s*(\d{5})\b`)
+
+// Match assigns payments to open orders. The rules are tried in order of
+// confidence: the payment reference number, an order number mentioned in
+// the description, and finally the exact amount due paid by someone whose
+// name matches the order. An order takes payments until they cover its
+// total, the ones before are marked Partial. Orders are in currency,
+// payments in another currency are left for a person to match.
+func Match(payments []Payment, orders []OpenOrder, currency string) []Payment {
+	open := make(map[int]OpenOrder, len(orders))
+	byReference := make(map[string]int, len(orders))
+	for _, o := range orders {
+		open[o.ID] = o
+		byReference[util.ReferenceNumber(o.ID)] = o.ID
+	}
+
+	matched := make([]Payment, len(payments))
+	copy(matched, payments)
+
+	assign := func(p *Payment, orderID int, rule string) {
+		p.OrderID = orderID
+		p.MatchedBy = rule
+
+		o := open[orderID]
+		o.Paid += p.Amount
+		if o.covered() {
+			delete(open, orderID)
+			return
+		}
+		p.Partial = true
+		open[orderID] = o
+	}
+
+	// references first, so that a reference always wins over a fuzzier rule
+	for i := range matched {
+		if !inCurrency(matched[i], currency) {
+			continue
+		}
+		ref := strings.TrimLeft(digitsOnly(matched[i].Reference), "0")
+		if orderID, ok := byReference[ref]; ok {
+			if _, stillOpen := open[orderID]; stillOpen {
+				assign(&matched[i], orderID, MatchedByReference)
+			}
+		}
+	}
+
+	for i := range matched {
+		if matched[i].OrderID != 0 || !inCurrency(matched[i], currency) {
+			continue
+		}
+
+		text := matched[i].Description + " " + matched[i].Reference
+		for _, candidate := range orderNumberPattern.FindAllStringSubmatch(text, -1) {
+			orderID, _ := strconv.Atoi(candidate[1])
+			if _, ok := open[orderID]; ok {
+				assign(&matched[i], orderID, MatchedByDescription)
+				break
+			}
+		}
+	}
+
+	for i := range matched {
+		if matched[i].OrderID != 0 || !inCurrency(matched[i], currency) {
+			continue
+		}
+
+		payer := normalizeName(matched[i].PayerName)
+		if payer == "" {
+			continue
+		}
+
+		for _, o := range orders {
+			due, ok := open[o.ID]
+			if !ok || due.Total <= 0 {
+				continue
+			}
+			if math.Abs(due.Due()-matched[i].Amount) >= 0.005 {
+				continue
+			}
+			if payer == normalizeName(o.Name) || (o.Company != "" && payer == normalizeName(o.Company)) {
+				assign(&matched[i], o.ID, MatchedByAmountPayer)
+				break
+			}
+		}
+	}
+
+	return matched
+}
+
+// inCurrency reports whether p is in currency. Statements without a
+// currency are taken to be in it.
+func inCurrency(p Payment, currency string) bool {
+	return p.Currency == "" || strings.EqualFold(p.Currency, currency)
+}
+
+func digitsOnly(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
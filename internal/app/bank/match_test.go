@@ -0,0 +1,122 @@
+package bank
+
+import (
+	"testing"
+
+	"github.com/trunov/virena/internal/app/util"
+)
+
+func TestMatch(t *testing.T) {
+	orders := []OpenOrder{
+		{ID: 12345, Name: "Jaan Tamm", Total: 100},
+		{ID: 23456, Name: "Mari Maasikas", Company: "Maasikas OÜ", Total: 250.50},
+		{ID: 34567, Name: "Peeter Puu", Total: 80, Paid: 30},
+		{ID: 45678, Name: "Kalle Kask"},
+	}
+
+	type want struct {
+		orderID   int
+		matchedBy string
+		partial   bool
+	}
+	tests := []struct {
+		name     string
+		payments []Payment
+		want     []want
+	}{
+		{
+			"reference",
+			[]Payment{{Amount: 100, Currency: "EUR", Reference: util.ReferenceNumber(12345)}},
+			[]want{{12345, MatchedByReference, false}},
+		},
+		{
+			"reference with leading zeros and spaces",
+			[]Payment{{Amount: 100, Reference: "00 1234 53"}},
+			[]want{{12345, MatchedByReference, false}},
+		},
+		{
+			"reference below the total is partial",
+			[]Payment{{Amount: 60, Currency: "EUR", Reference: util.ReferenceNumber(12345)}},
+			[]want{{12345, MatchedByReference, true}},
+		},
+		{
+			"partial payments that add up",
+			[]Payment{
+				{Amount: 60, Currency: "EUR", Reference: util.ReferenceNumber(12345)},
+				{Amount: 40, Currency: "EUR", Reference: util.ReferenceNumber(12345)},
+			},
+			[]want{{12345, MatchedByReference, true}, {12345, MatchedByReference, false}},
+		},
+		{
+			"payment covering an earlier partial one",
+			[]Payment{{Amount: 50, Currency: "EUR", Description: "order 34567"}},
+			[]want{{34567, MatchedByDescription, false}},
+		},
+		{
+			"order without a total stays open",
+			[]Payment{{Amount: 20, Currency: "EUR", Reference: util.ReferenceNumber(45678)}},
+			[]want{{45678, MatchedByReference, true}},
+		},
+		{
+			"reference after the order is paid",
+			[]Payment{
+				{Amount: 100, Currency: "EUR", Reference: util.ReferenceNumber(12345)},
+				{Amount: 100, Currency: "EUR", Reference: util.ReferenceNumber(12345)},
+			},
+			[]want{{12345, MatchedByReference, false}, {0, "", false}},
+		},
+		{
+			"order number in the description",
+			[]Payment{{Amount: 250.50, Currency: "EUR", Description: "Tellimuse nr 23456 eest"}},
+			[]want{{23456, MatchedByDescription, false}},
+		},
+		{
+			"postal code is not an order number",
+			[]Payment{{Amount: 10, Currency: "EUR", Description: "Tallinn 12345"}},
+			[]want{{0, "", false}},
+		},
+		{
+			"amount and company name",
+			[]Payment{{Amount: 250.50, Currency: "EUR", PayerName: "MAASIKAS  OÜ"}},
+			[]want{{23456, MatchedByAmountPayer, false}},
+		},
+		{
+			"amount and name of the sum due",
+			[]Payment{{Amount: 50, Currency: "EUR", PayerName: "peeter puu"}},
+			[]want{{34567, MatchedByAmountPayer, false}},
+		},
+		{
+			"amount of another payer",
+			[]Payment{{Amount: 100, Currency: "EUR", PayerName: "Someone Else"}},
+			[]want{{0, "", false}},
+		},
+		{
+			"foreign currency",
+			[]Payment{
+				{Amount: 100, Currency: "USD", Reference: util.ReferenceNumber(12345)},
+				{Amount: 250.50, Currency: "SEK", Description: "order 23456"},
+			},
+			[]want{{0, "", false}, {0, "", false}},
+		},
+	}
+	for _, tt := range tests {
+		got := Match(tt.payments, orders, "EUR")
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: %d payments, want %d", tt.name, len(got), len(tt.want))
+		}
+		for i, w := range tt.want {
+			if got[i].OrderID != w.orderID || got[i].MatchedBy != w.matchedBy || got[i].Partial != w.partial {
+				t.Errorf("%s: payment %d = order %d by %q partial %v, want order %d by %q partial %v",
+					tt.name, i, got[i].OrderID, got[i].MatchedBy, got[i].Partial, w.orderID, w.matchedBy, w.partial)
+			}
+		}
+	}
+}
+
+func TestMatchKeepsPayments(t *testing.T) {
+	payments := []Payment{{Amount: 100, Reference: util.ReferenceNumber(12345)}}
+	Match(payments, []OpenOrder{{ID: 12345, Total: 100}}, "EUR")
+	if payments[0].OrderID != 0 {
+		t.Errorf("Match changed its input: %+v", payments[0])
+	}
+}
@@ -0,0 +1,163 @@
+package columns
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSynonyms are the header names dealers use for the columns our CSV
+// tools care about. The key is the canonical name of the column.
+var DefaultSynonyms = map[string][]string{
+	"code":        {"code", "part no", "part no.", "part number", "partno", "part code", "kood", "tootekood", "artikkel", "article", "article no", "sku"},
+	"price":       {"price", "hind", "netto", "net price", "neto", "netohind", "hinta", "cost"},
+	"dealer":      {"dealer", "dealer number", "tarnija", "supplier", "vendor"},
+	"description": {"description", "nimetus", "kirjeldus", "name"},
+}
+
+// MissingError reports a column that is not in the header row.
+type MissingError struct {
+	Column  string
+	Headers []string
+}
+
+func (e *MissingError) Error() string {
+	return fmt.Sprintf("column %q not found, headers found: %s", e.Column, strings.Join(e.Headers, ", "))
+}
+
+// Resolver turns column references into 0-based indexes. A reference is
+// either a 1-based position ("3") or a header name, matched case-insensitively
+// together with its synonyms.
+type Resolver struct {
+	synonyms map[string][]string
+}
+
+// NewResolver merges extra synonyms into the defaults, extra["code"] adds
+// names for the code column.
+func NewResolver(extra map[string][]string) *Resolver {
+	synonyms := make(map[string][]string, len(DefaultSynonyms))
+	for column, names := range DefaultSynonyms {
+		synonyms[column] = append([]string(nil), names...)
+	}
+	for column, names := range extra {
+		column = normalize(column)
+		synonyms[column] = append(synonyms[column], names...)
+	}
+
+	for column, names := range synonyms {
+		normalized := make([]string, 0, len(names)+1)
+		normalized = append(normalized, column)
+		for _, name := range names {
+			normalized = append(normalized, normalize(name))
+		}
+		synonyms[column] = normalized
+	}
+
+	return &Resolver{synonyms: synonyms}
+}
+
+// IsPosition reports whether ref is a 1-based column position.
+func IsPosition(ref string) bool {
+	_, err := strconv.Atoi(strings.TrimSpace(ref))
+	return err == nil
+}
+
+// NeedsHeader reports whether any of refs is a header name.
+func NeedsHeader(refs ...string) bool {
+	for _, ref := range refs {
+		if strings.TrimSpace(ref) != "" && !IsPosition(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// Indexes resolves refs to 0-based indexes. The header row is only read
+// when one of the references is a name.
+func (r *Resolver) Indexes(header func() ([]string, error), refs ...string) ([]int, error) {
+	var row []string
+	if NeedsHeader(refs...) {
+		var err error
+		row, err = header()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	indexes := make([]int, len(refs))
+	for i, ref := range refs {
+		index, err := r.Index(ref, row)
+		if err != nil {
+			return nil, err
+		}
+		indexes[i] = index
+	}
+
+	return indexes, nil
+}
+
+// SplitPair splits a "price,code" pair of column references.
+func SplitPair(value string) (string, string, bool) {
+	first, second, ok := strings.Cut(value, ",")
+	if !ok || strings.TrimSpace(first) == "" || strings.TrimSpace(second) == "" {
+		return "", "", false
+	}
+	return strings.TrimSpace(first), strings.TrimSpace(second), true
+}
+
+// Index resolves ref against the header row.
+func (r *Resolver) Index(ref string, header []string) (int, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return -1, fmt.Errorf("empty column reference")
+	}
+
+	if position, err := strconv.Atoi(ref); err == nil {
+		if position <= 0 {
+			return -1, fmt.Errorf("column position %d must be 1 or more", position)
+		}
+		return position - 1, nil
+	}
+
+	names := r.candidates(ref)
+	for _, name := range names {
+		for i, cell := range header {
+			if normalize(cell) == name {
+				return i, nil
+			}
+		}
+	}
+
+	return -1, &MissingError{Column: ref, Headers: header}
+}
+
+// candidates lists the names to look for: the reference itself first, then
+// the synonyms of every column it names.
+func (r *Resolver) candidates(ref string) []string {
+	ref = normalize(ref)
+	names := []string{ref}
+
+	columns := make([]string, 0, len(r.synonyms))
+	for column := range r.synonyms {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	for _, column := range columns {
+		synonyms := r.synonyms[column]
+		for _, synonym := range synonyms {
+			if synonym == ref {
+				names = append(names, synonyms...)
+				break
+			}
+		}
+	}
+
+	return names
+}
+
+func normalize(name string) string {
+	name = strings.TrimPrefix(name, "\ufeff")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
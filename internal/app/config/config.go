@@ -2,6 +2,11 @@ package config
 
 import (
 	"flag"
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
@@ -10,20 +15,225 @@ type Config struct {
 	Port           string `env:"PORT" envDefault:"8080"`
 	DatabaseURI    string `env:"DATABASE_URI"`
 	SendgridAPIKey string `env:"SENDGRID_API_KEY"`
+	AdminToken     string `env:"ADMIN_TOKEN"`
+
+	// SendgridWebhookPublicKey verifies the signed event webhook, the
+	// endpoint is disabled while it is empty.
+	SendgridWebhookPublicKey string `env:"SENDGRID_WEBHOOK_PUBLIC_KEY"`
+
+	// Mailer selects the email backend: sendgrid, smtp or file.
+	Mailer       string `env:"MAILER" envDefault:"sendgrid"`
+	SMTPAddr     string `env:"SMTP_ADDR" envDefault:"localhost:1025"`
+	SMTPUsername string `env:"SMTP_USERNAME"`
+	SMTPPassword string `env:"SMTP_PASSWORD"`
+	MailDir      string `env:"MAIL_DIR" envDefault:"mail"`
+
+	// Addresses are "Name <email>" or a bare email. The order subject is
+	// translated to the customer's language unless ORDER_EMAIL_SUBJECT is set.
+	EmailFromName         string    `env:"EMAIL_FROM_NAME" envDefault:"Virena"`
+	EmailFrom             string    `env:"EMAIL_FROM"`
+	OrderEmailCC          []string  `env:"ORDER_EMAIL_CC" envSeparator:","`
+	OrderEmailCCByCountry StringMap `env:"ORDER_EMAIL_CC_BY_COUNTRY"`
+	OrderEmailSubject     string    `env:"ORDER_EMAIL_SUBJECT"`
+	OrderEmailTemplateID  string    `env:"ORDER_EMAIL_TEMPLATE_ID"`
+	ContactEmailTo        []string  `env:"CONTACT_EMAIL_TO" envSeparator:","`
+	ContactEmailSubject   string    `env:"CONTACT_EMAIL_SUBJECT" envDefault:"Customer Request Message"`
+
+	// TrustedProxies are the addresses or CIDR ranges of proxies in front
+	// of the server, only their X-Forwarded-For is believed.
+	TrustedProxies []string `env:"TRUSTED_PROXIES" envSeparator:","`
+
+	// StorageDir keeps uploaded files such as contact form attachments.
+	StorageDir string `env:"STORAGE_DIR" envDefault:"data"`
+
+	// The contact form timing checks are off unless set: they need its
+	// formRenderedAt field, forms without it are refused.
+	ContactHoneypotField  string        `env:"CONTACT_HONEYPOT_FIELD" envDefault:"website"`
+	ContactMinSubmitTime  time.Duration `env:"CONTACT_MIN_SUBMIT_TIME"`
+	ContactMaxFormAge     time.Duration `env:"CONTACT_MAX_FORM_AGE"`
+	ContactRateWindow     time.Duration `env:"CONTACT_RATE_WINDOW" envDefault:"1h"`
+	ContactRateLimitIP    int           `env:"CONTACT_RATE_LIMIT_IP" envDefault:"10"`
+	ContactRateLimitEmail int           `env:"CONTACT_RATE_LIMIT_EMAIL" envDefault:"5"`
+	CaptchaProvider       string        `env:"CAPTCHA_PROVIDER"`
+	CaptchaVerifyURL      string        `env:"CAPTCHA_VERIFY_URL"`
+	CaptchaSecret         string        `env:"CAPTCHA_SECRET"`
+	CaptchaFakeToken      string        `env:"CAPTCHA_FAKE_TOKEN" envDefault:"test-captcha-token"`
+	CaptchaField          string        `env:"CAPTCHA_FIELD" envDefault:"captchaToken"`
+
+	ContactMaxFiles     int      `env:"CONTACT_MAX_FILES" envDefault:"5"`
+	ContactMaxFileSize  int64    `env:"CONTACT_MAX_FILE_SIZE" envDefault:"10485760"`
+	ContactMaxTotalSize int64    `env:"CONTACT_MAX_TOTAL_SIZE" envDefault:"20971520"`
+	ContactAllowedTypes []string `env:"CONTACT_ALLOWED_TYPES" envSeparator:"," envDefault:"image/jpeg,image/png,image/gif,image/webp,application/pdf,text/plain"`
+	// ClamdAddr is host:port or a unix socket path of clamd, empty disables
+	// scanning of contact attachments.
+	ClamdAddr    string        `env:"CLAMD_ADDR"`
+	ClamdTimeout time.Duration `env:"CLAMD_TIMEOUT" envDefault:"30s"`
+
+	// ColumnSynonyms adds header names for the CSV tools, e.g.
+	// "code:Part No|Kood,price:Hind|Netto".
+	ColumnSynonyms StringMap `env:"COLUMN_SYNONYMS"`
+	// PartCodeRules are how part codes of a brand are matched, e.g.
+	// "MB:prefix=A,VLV:separators=- |keepzeros,default:separators=-".
+	PartCodeRules StringMap `env:"PART_CODE_RULES"`
+	// CSVLegacyEncoding is assumed for uploads that are not UTF-8 when the
+	// codepage cannot be detected.
+	CSVLegacyEncoding string `env:"CSV_LEGACY_ENCODING" envDefault:"windows-1257"`
+
+	Currency               string    `env:"CURRENCY" envDefault:"EUR"`
+	AccountingVATCodes     StringMap `env:"ACCOUNTING_VAT_CODES" envDefault:"default:KM20"`
+	AccountingCSVColumns   []string  `env:"ACCOUNTING_CSV_COLUMNS" envSeparator:","`
+	AccountingCSVDelimiter string    `env:"ACCOUNTING_CSV_DELIMITER" envDefault:";"`
+
+	OutboxPollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"10s"`
+	OutboxMaxAttempts  int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"8"`
+	OutboxBaseBackoff  time.Duration `env:"OUTBOX_BASE_BACKOFF" envDefault:"1m"`
+	OutboxMaxBackoff   time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"6h"`
+
+	JobWorkers       int           `env:"JOB_WORKERS" envDefault:"2"`
+	JobPollInterval  time.Duration `env:"JOB_POLL_INTERVAL" envDefault:"2s"`
+	JobMaxAttempts   int           `env:"JOB_MAX_ATTEMPTS" envDefault:"3"`
+	JobMaxUploadSize int64         `env:"JOB_MAX_UPLOAD_SIZE" envDefault:"536870912"`
+	JobRetention     time.Duration `env:"JOB_RETENTION" envDefault:"168h"`
 }
 
-func ReadConfig() (Config, error) {
-	cfgEnv := Config{}
+// StringMap is read from a "key:value,key:value" list. The env package we
+// use has no parser for plain maps.
+type StringMap map[string]string
+
+func (m *StringMap) UnmarshalText(text []byte) error {
+	parsed := make(StringMap)
+	for _, pair := range strings.Split(string(text), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fmt.Errorf("invalid key:value pair %q", pair)
+		}
+		parsed[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	*m = parsed
+	return nil
+}
+
+// Validate reports every missing or malformed setting the server needs to
+// run, so a bad deployment fails at startup instead of on the first order.
+func (c Config) Validate() error {
+	var problems []string
+	require := func(value, name string) {
+		if strings.TrimSpace(value) == "" {
+			problems = append(problems, name+" is required")
+		}
+	}
+	checkAddress := func(value, name string) {
+		if _, err := mail.ParseAddress(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid address %q", name, value))
+		}
+	}
+
+	require(c.DatabaseURI, "DATABASE_URI")
+
+	require(c.EmailFrom, "EMAIL_FROM")
+	if c.EmailFrom != "" {
+		checkAddress(c.EmailFrom, "EMAIL_FROM")
+	}
+
+	if len(c.ContactEmailTo) == 0 {
+		problems = append(problems, "CONTACT_EMAIL_TO is required")
+	}
+	for _, address := range c.ContactEmailTo {
+		checkAddress(address, "CONTACT_EMAIL_TO")
+	}
+	for _, address := range c.OrderEmailCC {
+		checkAddress(address, "ORDER_EMAIL_CC")
+	}
+	for country, addresses := range c.OrderEmailCCByCountry {
+		for _, address := range SplitAddresses(addresses) {
+			checkAddress(address, "ORDER_EMAIL_CC_BY_COUNTRY["+country+"]")
+		}
+	}
+
+	if _, err := ParseTrustedProxies(c.TrustedProxies); err != nil {
+		problems = append(problems, "TRUSTED_PROXIES: "+err.Error())
+	}
+
+	switch c.Mailer {
+	case "sendgrid", "":
+		require(c.SendgridAPIKey, "SENDGRID_API_KEY")
+	case "smtp":
+		require(c.SMTPAddr, "SMTP_ADDR")
+	case "file":
+		require(c.MailDir, "MAIL_DIR")
+	default:
+		problems = append(problems, fmt.Sprintf("MAILER: unknown backend %q", c.Mailer))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// SplitAddresses splits the "|" separated addresses of a per-country value.
+func SplitAddresses(value string) []string {
+	var addresses []string
+	for _, address := range strings.Split(value, "|") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
 
-	if err := env.Parse(&cfgEnv); err != nil {
+// ParseTrustedProxies reads proxy addresses, single IPs or CIDR ranges.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q", value)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", value)
+		}
+		proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return proxies, nil
+}
+
+// ReadEnv reads the config from the environment only, for commands that
+// parse flags of their own.
+func ReadEnv() (Config, error) {
+	cfg := Config{}
+	err := env.Parse(&cfg)
+	return cfg, err
+}
+
+func ReadConfig() (Config, error) {
+	cfgEnv, err := ReadEnv()
+	if err != nil {
 		return cfgEnv, err
 	}
 
-	cfgFlag := Config{}
+	cfgFlag := cfgEnv
 
 	flag.StringVar(&cfgFlag.Port, "p", cfgEnv.Port, "port")
 	flag.StringVar(&cfgFlag.DatabaseURI, "d", cfgEnv.DatabaseURI, "database URI")
 	flag.StringVar(&cfgFlag.SendgridAPIKey, "s", cfgEnv.SendgridAPIKey, "sendgrid API key")
+	flag.StringVar(&cfgFlag.AdminToken, "a", cfgEnv.AdminToken, "admin API token")
+	flag.StringVar(&cfgFlag.Mailer, "m", cfgEnv.Mailer, "mailer backend: sendgrid, smtp or file")
 
 	flag.Parse()
 
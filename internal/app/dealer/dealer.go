@@ -0,0 +1,44 @@
+package dealer
+
+import (
+	"strconv"
+	"time"
+)
+
+// Dealer is a supplier whose price lists are kept as versioned snapshots.
+type Dealer struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Number is the dealer number shown in comparisons, the ID when empty.
+	Number string `json:"number"`
+	// ProfileID is the dealer profile used for uploads without columns.
+	ProfileID     *int      `json:"profileId,omitempty"`
+	LatestVersion int       `json:"latestVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Snapshot is one uploaded price list of a dealer.
+type Snapshot struct {
+	ID        int       `json:"id"`
+	DealerID  int       `json:"dealerId"`
+	Version   int       `json:"version"`
+	Filename  string    `json:"filename"`
+	Rows      int       `json:"rows"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Price is a row of a snapshot. Dealer is the per-row dealer number of lists
+// that carry one.
+type Price struct {
+	Code   string
+	Price  float64
+	Dealer string
+}
+
+// DisplayNumber is the dealer number used in comparisons.
+func (d Dealer) DisplayNumber() string {
+	if d.Number != "" {
+		return d.Number
+	}
+	return strconv.Itoa(d.ID)
+}
@@ -0,0 +1,196 @@
+package delivery
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+)
+
+// Event types we keep from the SendGrid event webhook. Opens, clicks and
+// the like are dropped.
+const (
+	EventDelivered = "delivered"
+	EventBounce    = "bounce"
+	EventDropped   = "dropped"
+	EventSpam      = "spamreport"
+)
+
+// Custom arguments attached to outgoing emails. SendGrid echoes them back on
+// every event, which is how events are linked to the outbox and the order.
+const (
+	TagOutboxID = "outbox_id"
+	TagOrderID  = "order_id"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+type Event struct {
+	ID          int64     `json:"id"`
+	OutboxID    *int64    `json:"outboxId,omitempty"`
+	OrderID     *int      `json:"orderId,omitempty"`
+	Event       string    `json:"event"`
+	Email       string    `json:"email"`
+	Reason      string    `json:"reason,omitempty"`
+	SGEventID   string    `json:"sgEventId"`
+	SGMessageID string    `json:"sgMessageId,omitempty"`
+	OccurredAt  time.Time `json:"occurredAt"`
+}
+
+// State is the delivery state of an order email as seen by SendGrid. The
+// last event is the customer's, events of the staff copies are listed but
+// do not change it.
+type State struct {
+	OutboxStatus string    `json:"outboxStatus,omitempty"`
+	Recipient    string    `json:"recipient,omitempty"`
+	LastEvent    string    `json:"lastEvent,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt,omitempty"`
+	Events       []Event   `json:"events"`
+}
+
+// NewState summarizes events of the recipient ordered by the time they
+// happened.
+func NewState(outboxStatus, recipient string, events []Event) State {
+	state := State{OutboxStatus: outboxStatus, Recipient: recipient, Events: events}
+	if state.Events == nil {
+		state.Events = []Event{}
+	}
+
+	for _, e := range events {
+		if !strings.EqualFold(strings.TrimSpace(e.Email), strings.TrimSpace(recipient)) {
+			continue
+		}
+		if !e.OccurredAt.Before(state.UpdatedAt) {
+			state.LastEvent = e.Event
+			state.UpdatedAt = e.OccurredAt
+		}
+	}
+
+	return state
+}
+
+// Verifier checks the ECDSA signature of the SendGrid signed event webhook.
+type Verifier struct {
+	key *ecdsa.PublicKey
+}
+
+// NewVerifier takes the base64 public key shown in the SendGrid mail settings.
+func NewVerifier(publicKey string) (*Verifier, error) {
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return nil, fmt.Errorf("decode webhook public key: %w", err)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parse webhook public key: %w", err)
+	}
+
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("webhook public key is not an ECDSA key")
+	}
+	return &Verifier{key: ecdsaKey}, nil
+}
+
+// Verify checks the signature and timestamp headers against the raw body.
+func (v *Verifier) Verify(body []byte, signature, timestamp string) error {
+	if signature == "" || timestamp == "" {
+		return ErrInvalidSignature
+	}
+
+	ok, err := eventwebhook.VerifySignature(v.key, body, signature, timestamp)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !ok {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+type sendGridEvent struct {
+	Email       string          `json:"email"`
+	Timestamp   int64           `json:"timestamp"`
+	Event       string          `json:"event"`
+	Reason      string          `json:"reason"`
+	Type        string          `json:"type"`
+	SGEventID   string          `json:"sg_event_id"`
+	SGMessageID string          `json:"sg_message_id"`
+	OutboxID    json.RawMessage `json:"outbox_id"`
+	OrderID     json.RawMessage `json:"order_id"`
+}
+
+// ParseSendGrid decodes a webhook batch and keeps the events we track.
+func ParseSendGrid(body []byte) ([]Event, error) {
+	var batch []sendGridEvent
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return nil, fmt.Errorf("decode events: %w", err)
+	}
+
+	var events []Event
+	for _, e := range batch {
+		switch e.Event {
+		case EventDelivered, EventBounce, EventDropped, EventSpam:
+		default:
+			continue
+		}
+
+		if e.SGEventID == "" {
+			continue
+		}
+
+		event := Event{
+			Event:       e.Event,
+			Email:       e.Email,
+			Reason:      e.Reason,
+			SGEventID:   e.SGEventID,
+			SGMessageID: e.SGMessageID,
+			OccurredAt:  time.Unix(e.Timestamp, 0).UTC(),
+		}
+
+		// blocked bounces are soft, keep the distinction in the reason
+		if e.Event == EventBounce && e.Type == "blocked" && event.Reason != "" {
+			event.Reason = "blocked: " + event.Reason
+		}
+
+		if id, ok := customArg(e.OutboxID); ok {
+			event.OutboxID = &id
+		}
+		if id, ok := customArg(e.OrderID); ok {
+			orderID := int(id)
+			event.OrderID = &orderID
+		}
+
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// customArg reads a numeric custom argument. SendGrid sends them back as
+// strings, but accept plain numbers too.
+func customArg(raw json.RawMessage) (int64, bool) {
+	if len(raw) == 0 {
+		return 0, false
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
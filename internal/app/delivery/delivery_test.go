@@ -0,0 +1,36 @@
+package delivery
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewStateFollowsRecipient(t *testing.T) {
+	at := func(minute int) time.Time {
+		return time.Date(2026, 10, 18, 12, minute, 0, 0, time.UTC)
+	}
+	customer := Event{Event: EventDelivered, Email: "Customer@example.com", OccurredAt: at(1)}
+	staffBounce := Event{Event: EventBounce, Email: "sales@virena.ee", OccurredAt: at(2)}
+	customerBounce := Event{Event: EventBounce, Email: "customer@example.com", OccurredAt: at(3)}
+
+	tests := []struct {
+		name      string
+		events    []Event
+		wantEvent string
+		wantAt    time.Time
+	}{
+		{"no events", nil, "", time.Time{}},
+		{"staff copy only", []Event{staffBounce}, "", time.Time{}},
+		{"staff bounce after delivery", []Event{customer, staffBounce}, EventDelivered, at(1)},
+		{"customer bounce", []Event{customer, staffBounce, customerBounce}, EventBounce, at(3)},
+	}
+	for _, tt := range tests {
+		state := NewState("sent", "customer@example.com", tt.events)
+		if state.LastEvent != tt.wantEvent || !state.UpdatedAt.Equal(tt.wantAt) {
+			t.Errorf("%s: LastEvent = %q at %v, want %q at %v", tt.name, state.LastEvent, state.UpdatedAt, tt.wantEvent, tt.wantAt)
+		}
+		if len(state.Events) != len(tt.events) {
+			t.Errorf("%s: %d events, want %d", tt.name, len(state.Events), len(tt.events))
+		}
+	}
+}
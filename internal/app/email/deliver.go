@@ -0,0 +1,124 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rs/zerolog"
+	"github.com/trunov/virena/internal/app/delivery"
+	"github.com/trunov/virena/internal/app/mailer"
+	"github.com/trunov/virena/internal/app/outbox"
+	"github.com/trunov/virena/internal/app/postgres"
+	"github.com/trunov/virena/internal/app/storage"
+)
+
+// Deliver returns the outbox delivery function for the emails we send.
+// Order emails are rendered from the stored order, so a retry always
+// reflects what is in the database.
+func Deliver(m mailer.Mailer, settings Settings, dbStorage postgres.DBStorager, files *storage.Local, logger zerolog.Logger) outbox.DeliverFunc {
+	return func(ctx context.Context, msg outbox.Message) error {
+		tagged := taggedMailer{Mailer: m, tags: deliveryTags(msg)}
+
+		switch msg.Kind {
+		case outbox.KindOrderEmail:
+			if msg.OrderID == nil {
+				return errors.New("order email without order id")
+			}
+
+			order, createdDate, err := dbStorage.GetOrder(ctx, *msg.OrderID)
+			if err != nil {
+				return fmt.Errorf("load order %d: %w", *msg.OrderID, err)
+			}
+
+			return SendOrderEmail(ctx, tagged, settings, *msg.OrderID, order, createdDate, logger)
+		case outbox.KindContactEmail:
+			contact, err := loadContactMessage(ctx, msg, dbStorage, files)
+			if err != nil {
+				return err
+			}
+
+			return SendCustomerMessageEmail(ctx, tagged, settings, contact, logger)
+		default:
+			return fmt.Errorf("unknown outbox message kind %q", msg.Kind)
+		}
+	}
+}
+
+// taggedMailer marks every email with the outbox message it was sent for.
+type taggedMailer struct {
+	mailer.Mailer
+	tags map[string]string
+}
+
+func (t taggedMailer) Send(ctx context.Context, message mailer.Message) error {
+	if message.Tags == nil {
+		message.Tags = make(map[string]string, len(t.tags))
+	}
+	for key, value := range t.tags {
+		message.Tags[key] = value
+	}
+	return t.Mailer.Send(ctx, message)
+}
+
+func deliveryTags(msg outbox.Message) map[string]string {
+	tags := map[string]string{delivery.TagOutboxID: strconv.FormatInt(msg.ID, 10)}
+	if msg.OrderID != nil {
+		tags[delivery.TagOrderID] = strconv.Itoa(*msg.OrderID)
+	}
+	return tags
+}
+
+// loadContactMessage builds the notification from the stored ticket. Messages
+// queued before tickets existed carry the whole submission in the payload.
+func loadContactMessage(ctx context.Context, msg outbox.Message, dbStorage postgres.DBStorager, files *storage.Local) (ContactMessage, error) {
+	var payload outbox.ContactPayload
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		return ContactMessage{}, fmt.Errorf("decode contact message: %w", err)
+	}
+
+	if payload.TicketID == 0 {
+		var contact ContactMessage
+		if err := json.Unmarshal(msg.Payload, &contact); err != nil {
+			return ContactMessage{}, fmt.Errorf("decode contact message: %w", err)
+		}
+		return contact, nil
+	}
+
+	t, err := dbStorage.GetTicket(ctx, payload.TicketID)
+	if err != nil {
+		return ContactMessage{}, fmt.Errorf("load ticket %d: %w", payload.TicketID, err)
+	}
+
+	contact := ContactMessage{
+		TicketID: t.ID,
+		Name:     t.Name,
+		Email:    t.Email,
+		Subject:  t.Subject,
+		Message:  t.Message,
+	}
+
+	for _, a := range t.Attachments {
+		content, err := readStoredFile(files, a.StorageKey)
+		if err != nil {
+			return ContactMessage{}, fmt.Errorf("read attachment %d of ticket %d: %w", a.ID, t.ID, err)
+		}
+
+		contact.Attachments = append(contact.Attachments, Attachment{Filename: a.Filename, Content: content})
+	}
+
+	return contact, nil
+}
+
+func readStoredFile(files *storage.Local, key string) ([]byte, error) {
+	f, err := files.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/trunov/virena/internal/app/mailer"
+	"github.com/trunov/virena/internal/app/postgres"
+	"github.com/trunov/virena/internal/app/util"
+)
+
+type orderItemView struct {
+	PartCode    string
+	Description string
+	Quantity    int
+	Price       string
+	Amount      string
+}
+
+type orderView struct {
+	Language        string
+	OrderNumber     int
+	ReferenceNumber string
+	ClientName      string
+	OrderDate       string
+	Items           []orderItemView
+	Subtotal        string
+	VATRate         string
+	VAT             string
+	Total           string
+}
+
+// BuildOrderMessage renders the invoice email of an order. An empty lang
+// picks the customer's language.
+func BuildOrderMessage(settings Settings, orderID int, orderData postgres.Order, createdDate time.Time, lang string) (mailer.Message, error) {
+	to := mailer.Address{Name: orderData.PersonalInformation.Name, Email: orderData.PersonalInformation.Email}
+
+	if lang == "" {
+		lang = Language(orderData.PersonalInformation)
+	}
+
+	summ := orderData.Subtotal()
+
+	data := orderView{
+		Language:        lang,
+		OrderNumber:     orderID,
+		ReferenceNumber: util.ReferenceNumber(orderID),
+		ClientName:      orderData.PersonalInformation.Name,
+		OrderDate:       util.ConvertToGMTPlus3(createdDate),
+		Subtotal:        fmt.Sprintf("%.2f", summ),
+		VATRate:         strconv.FormatFloat(util.VATRate*100, 'f', -1, 64),
+		VAT:             fmt.Sprintf("%.2f", summ*util.VATRate),
+		Total:           fmt.Sprintf("%.2f", orderData.Total()),
+	}
+
+	for _, product := range orderData.Cart {
+		item := orderItemView{
+			PartCode: product.PartCode,
+			Quantity: product.Quantity,
+			Price:    fmt.Sprintf("%.2f", product.Price),
+			Amount:   fmt.Sprintf("%.2f", product.Amount),
+		}
+		if product.Description != nil {
+			item.Description = *product.Description
+		}
+		data.Items = append(data.Items, item)
+	}
+
+	text, html, err := render("order", lang, data)
+	if err != nil {
+		return mailer.Message{}, fmt.Errorf("render order email: %w", err)
+	}
+
+	subject := settings.OrderSubject
+	if subject == "" {
+		subject = translator(lang)("orderSubject")
+	}
+
+	message := mailer.Message{
+		From:    settings.From,
+		To:      []mailer.Address{to},
+		CC:      settings.orderCC(orderData.PersonalInformation.Country),
+		Subject: fmt.Sprintf("%s %d", subject, orderID),
+		Text:    text,
+		HTML:    html,
+	}
+
+	if settings.OrderTemplateID != "" {
+		message.TemplateID = settings.OrderTemplateID
+		message.TemplateData = templateData(data)
+	}
+
+	return message, nil
+}
+
+// templateData keeps the dynamic data names of the order template hosted in
+// SendGrid.
+func templateData(data orderView) map[string]interface{} {
+	var items []map[string]interface{}
+	for _, item := range data.Items {
+		items = append(items, map[string]interface{}{
+			"partCode":    item.PartCode,
+			"price":       item.Price,
+			"quantity":    item.Quantity,
+			"amount":      item.Amount,
+			"description": item.Description,
+		})
+	}
+
+	return map[string]interface{}{
+		"orderNumber":     data.OrderNumber,
+		"referenceNumber": data.ReferenceNumber,
+		"clientName":      data.ClientName,
+		"orderDate":       data.OrderDate,
+		"orderItems":      items,
+		"summ":            data.Subtotal,
+		"kabemaks":        data.VAT,
+		"totalAmount":     data.Total,
+	}
+}
+
+func SendOrderEmail(ctx context.Context, m mailer.Mailer, settings Settings, orderID int, orderData postgres.Order, createdDate time.Time, logger zerolog.Logger) error {
+	message, err := BuildOrderMessage(settings, orderID, orderData, createdDate, "")
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to render order email")
+		return err
+	}
+
+	if err := m.Send(ctx, message); err != nil {
+		logger.Error().Err(err).Msg("Failed to send order email")
+		return err
+	}
+
+	return nil
+}
+
+// Attachment is a file uploaded with the contact form.
+type Attachment struct {
+	Filename string `json:"filename"`
+	Content  []byte `json:"content"`
+}
+
+// ContactMessage is a contact form submission as it goes to the staff.
+type ContactMessage struct {
+	TicketID    int          `json:"ticketId,omitempty"`
+	Name        string       `json:"name"`
+	Email       string       `json:"email"`
+	Subject     string       `json:"subject"`
+	Message     string       `json:"message"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// BuildContactMessage renders the contact form notification for the staff.
+func BuildContactMessage(settings Settings, contact ContactMessage) (mailer.Message, error) {
+	text, html, err := render("contact", defaultLanguage, contact)
+	if err != nil {
+		return mailer.Message{}, fmt.Errorf("render contact email: %w", err)
+	}
+
+	subject := settings.ContactSubject
+	if contact.TicketID != 0 {
+		subject = fmt.Sprintf("%s #%d", subject, contact.TicketID)
+	}
+
+	message := mailer.Message{
+		From:    settings.From,
+		To:      settings.ContactTo,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}
+
+	if contact.Email != "" {
+		message.ReplyTo = &mailer.Address{Name: contact.Name, Email: contact.Email}
+	}
+
+	for _, file := range contact.Attachments {
+		message.Attachments = append(message.Attachments, mailer.Attachment{Filename: file.Filename, Content: file.Content})
+	}
+
+	return message, nil
+}
+
+func SendCustomerMessageEmail(ctx context.Context, m mailer.Mailer, settings Settings, contact ContactMessage, logger zerolog.Logger) error {
+	message, err := BuildContactMessage(settings, contact)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to render customer message email")
+		return err
+	}
+
+	if err := m.Send(ctx, message); err != nil {
+		logger.Error().Err(err).Msg("Failed to send customer message email")
+		return err
+	}
+
+	return nil
+}
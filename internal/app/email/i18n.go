@@ -0,0 +1,138 @@
+package email
+
+import (
+	"strings"
+
+	"github.com/trunov/virena/internal/app/postgres"
+)
+
+const defaultLanguage = "en"
+
+var translations = map[string]map[string]string{
+	"en": {
+		"orderSubject":    "Invoice order",
+		"greeting":        "Hello",
+		"thanks":          "Thank you for your order!",
+		"orderNumber":     "Order number",
+		"orderDate":       "Order date",
+		"referenceNumber": "Reference number",
+		"partCode":        "Part code",
+		"description":     "Description",
+		"quantity":        "Quantity",
+		"price":           "Price",
+		"amount":          "Amount",
+		"subtotal":        "Subtotal",
+		"vat":             "VAT",
+		"total":           "Total",
+		"paymentNote":     "Please use the reference number when paying for the order.",
+		"regards":         "Best regards,",
+	},
+	"et": {
+		"orderSubject":    "Tellimuse arve",
+		"greeting":        "Tere",
+		"thanks":          "Täname tellimuse eest!",
+		"orderNumber":     "Tellimuse number",
+		"orderDate":       "Tellimuse kuupäev",
+		"referenceNumber": "Viitenumber",
+		"partCode":        "Detaili kood",
+		"description":     "Kirjeldus",
+		"quantity":        "Kogus",
+		"price":           "Hind",
+		"amount":          "Summa",
+		"subtotal":        "Vahesumma",
+		"vat":             "Käibemaks",
+		"total":           "Kokku",
+		"paymentNote":     "Palun kasutage tellimuse tasumisel viitenumbrit.",
+		"regards":         "Lugupidamisega,",
+	},
+	"fi": {
+		"orderSubject":    "Tilauksen lasku",
+		"greeting":        "Hei",
+		"thanks":          "Kiitos tilauksestasi!",
+		"orderNumber":     "Tilausnumero",
+		"orderDate":       "Tilauspäivä",
+		"referenceNumber": "Viitenumero",
+		"partCode":        "Osanumero",
+		"description":     "Kuvaus",
+		"quantity":        "Määrä",
+		"price":           "Hinta",
+		"amount":          "Summa",
+		"subtotal":        "Välisumma",
+		"vat":             "ALV",
+		"total":           "Yhteensä",
+		"paymentNote":     "Käytäthän viitenumeroa tilausta maksaessasi.",
+		"regards":         "Ystävällisin terveisin,",
+	},
+	"ru": {
+		"orderSubject":    "Счёт по заказу",
+		"greeting":        "Здравствуйте",
+		"thanks":          "Спасибо за ваш заказ!",
+		"orderNumber":     "Номер заказа",
+		"orderDate":       "Дата заказа",
+		"referenceNumber": "Номер ссылки",
+		"partCode":        "Код детали",
+		"description":     "Описание",
+		"quantity":        "Количество",
+		"price":           "Цена",
+		"amount":          "Сумма",
+		"subtotal":        "Промежуточный итог",
+		"vat":             "НДС",
+		"total":           "Итого",
+		"paymentNote":     "Пожалуйста, укажите номер ссылки при оплате заказа.",
+		"regards":         "С уважением,",
+	},
+}
+
+var countryLanguages = map[string]string{
+	"estonia":            "et",
+	"eesti":              "et",
+	"finland":            "fi",
+	"suomi":              "fi",
+	"russia":             "ru",
+	"russian federation": "ru",
+}
+
+// Language picks the email language for a customer: the language chosen on
+// the order form when it is supported, otherwise the one of their country.
+func Language(info postgres.PersonalInformation) string {
+	if lang := strings.ToLower(info.Language); lang != "" {
+		if _, ok := translations[lang]; ok {
+			return lang
+		}
+	}
+
+	if lang, ok := countryLanguages[strings.ToLower(strings.TrimSpace(info.Country))]; ok {
+		return lang
+	}
+
+	return defaultLanguage
+}
+
+// NormalizeLanguage turns the language sent with an order into one the
+// templates are translated to: "et-EE" is "et". Unsupported languages are
+// "", the country picks the language then.
+func NormalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	if !SupportedLanguage(lang) {
+		return ""
+	}
+	return lang
+}
+
+// SupportedLanguage reports whether templates are translated to lang.
+func SupportedLanguage(lang string) bool {
+	_, ok := translations[lang]
+	return ok
+}
+
+func translator(lang string) func(key string) string {
+	return func(key string) string {
+		if s, ok := translations[lang][key]; ok {
+			return s
+		}
+		return translations[defaultLanguage][key]
+	}
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/trunov/virena/internal/app/postgres"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		lang, want string
+	}{
+		{"", ""},
+		{"et", "et"},
+		{"ET", "et"},
+		{" fi ", "fi"},
+		{"et-EE", "et"},
+		{"ru_RU", "ru"},
+		{"en-GB", "en"},
+		{"de", ""},
+		{"de-DE", ""},
+		{"estonian", ""},
+	}
+	for _, tt := range tests {
+		got := NormalizeLanguage(tt.lang)
+		if got != tt.want {
+			t.Errorf("NormalizeLanguage(%q) = %q, want %q", tt.lang, got, tt.want)
+		}
+		// whatever is stored fits the VARCHAR(2) column
+		if len(got) > 2 {
+			t.Errorf("NormalizeLanguage(%q) = %q, longer than 2", tt.lang, got)
+		}
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		lang, country, want string
+	}{
+		{"fi", "Estonia", "fi"},
+		{"", "Estonia", "et"},
+		{"", "suomi", "fi"},
+		{"de", "Germany", "en"},
+		{"", "", "en"},
+	}
+	for _, tt := range tests {
+		info := postgres.PersonalInformation{Language: tt.lang, Country: tt.country}
+		if got := Language(info); got != tt.want {
+			t.Errorf("Language(%q, %q) = %q, want %q", tt.lang, tt.country, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package email
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/config"
+	"github.com/trunov/virena/internal/app/mailer"
+)
+
+// Settings holds the addresses and subjects of the emails we send.
+type Settings struct {
+	From mailer.Address
+	// OrderCC gets a copy of every order email unless the customer's country
+	// has its own entry in OrderCCByCountry, keyed by lower case country.
+	OrderCC          []mailer.Address
+	OrderCCByCountry map[string][]mailer.Address
+	// OrderSubject replaces the translated order subject when set.
+	OrderSubject string
+	// OrderTemplateID sends order emails through a template hosted by the
+	// provider instead of the in-repo one.
+	OrderTemplateID string
+	ContactTo       []mailer.Address
+	ContactSubject  string
+}
+
+func NewSettings(cfg config.Config) (Settings, error) {
+	from, err := parseAddress(cfg.EmailFrom)
+	if err != nil {
+		return Settings{}, fmt.Errorf("EMAIL_FROM: %w", err)
+	}
+	if from.Name == "" {
+		from.Name = cfg.EmailFromName
+	}
+
+	settings := Settings{
+		From:             from,
+		OrderCCByCountry: make(map[string][]mailer.Address),
+		OrderSubject:     cfg.OrderEmailSubject,
+		OrderTemplateID:  cfg.OrderEmailTemplateID,
+		ContactSubject:   cfg.ContactEmailSubject,
+	}
+
+	if settings.OrderCC, err = parseAddresses(cfg.OrderEmailCC); err != nil {
+		return Settings{}, fmt.Errorf("ORDER_EMAIL_CC: %w", err)
+	}
+
+	for country, value := range cfg.OrderEmailCCByCountry {
+		addresses, err := parseAddresses(config.SplitAddresses(value))
+		if err != nil {
+			return Settings{}, fmt.Errorf("ORDER_EMAIL_CC_BY_COUNTRY[%s]: %w", country, err)
+		}
+		settings.OrderCCByCountry[countryKey(country)] = addresses
+	}
+
+	if settings.ContactTo, err = parseAddresses(cfg.ContactEmailTo); err != nil {
+		return Settings{}, fmt.Errorf("CONTACT_EMAIL_TO: %w", err)
+	}
+
+	return settings, nil
+}
+
+// orderCC picks the copy recipients for a customer's country.
+func (s Settings) orderCC(country string) []mailer.Address {
+	if cc, ok := s.OrderCCByCountry[countryKey(country)]; ok {
+		return cc
+	}
+	return s.OrderCC
+}
+
+func countryKey(country string) string {
+	return strings.ToLower(strings.TrimSpace(country))
+}
+
+func parseAddress(value string) (mailer.Address, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(value))
+	if err != nil {
+		return mailer.Address{}, err
+	}
+	return mailer.Address{Name: address.Name, Email: address.Address}, nil
+}
+
+func parseAddresses(values []string) ([]mailer.Address, error) {
+	var addresses []mailer.Address
+	for _, value := range values {
+		address, err := parseAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,49 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// the real "t" is bound per language on a clone before rendering
+var placeholderFuncs = map[string]interface{}{
+	"t": func(string) string { return "" },
+}
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.New("").Funcs(placeholderFuncs).ParseFS(templateFS, "templates/*.html.tmpl"))
+	textTemplates = texttemplate.Must(texttemplate.New("").Funcs(placeholderFuncs).ParseFS(templateFS, "templates/*.txt.tmpl"))
+)
+
+// render executes the text and HTML variants of a template, e.g. "order"
+// renders order.txt.tmpl and order.html.tmpl, with strings in lang.
+func render(name, lang string, data interface{}) (string, string, error) {
+	funcs := map[string]interface{}{"t": translator(lang)}
+
+	textTmpl, err := textTemplates.Clone()
+	if err != nil {
+		return "", "", err
+	}
+
+	var text bytes.Buffer
+	if err := textTmpl.Funcs(funcs).ExecuteTemplate(&text, name+".txt.tmpl", data); err != nil {
+		return "", "", err
+	}
+
+	htmlTmpl, err := htmlTemplates.Clone()
+	if err != nil {
+		return "", "", err
+	}
+
+	var html bytes.Buffer
+	if err := htmlTmpl.Funcs(funcs).ExecuteTemplate(&html, name+".html.tmpl", data); err != nil {
+		return "", "", err
+	}
+
+	return text.String(), html.String(), nil
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trunov/virena/internal/app/accounting"
+)
+
+func (h *Handler) ExportOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+	query := r.URL.Query()
+
+	from, err := time.Parse("2006-01-02", query.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	to, err := time.Parse("2006-01-02", query.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	req := accounting.ExportRequest{
+		From:            from,
+		To:              to,
+		Format:          query.Get("format"),
+		Columns:         h.accounting.Columns,
+		Delimiter:       h.accounting.Delimiter,
+		IncludeExported: query.Get("includeExported") == "true",
+		MarkExported:    query.Get("markExported") != "false",
+	}
+	if req.Format == "" {
+		req.Format = accounting.FormatCSV
+	}
+	if columns := query.Get("columns"); columns != "" {
+		req.Columns = strings.Split(columns, ",")
+	}
+	if delimiter := query.Get("delimiter"); delimiter != "" {
+		req.Delimiter = rune(delimiter[0])
+	}
+
+	filename := fmt.Sprintf("orders_%s_%s.%s", from.Format("20060102"), to.Format("20060102"), req.Format)
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	if req.Format == accounting.FormatXML {
+		w.Header().Set("Content-Type", "application/xml")
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+	}
+
+	// the orders left out for missing prices are only known once the
+	// document is built, so they are sent as a trailer
+	w.Header().Set("Trailer", "X-Unpriced-Orders")
+
+	// the orders are marked only when the document reached the client
+	out := &flushWriter{w: w}
+	result, err := accounting.Export(ctx, h.dbStorage, h.accounting.Settings, req, out)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Export orders. Export failed.")
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			http.Error(w, fmt.Sprintf("Export failed: %v", err), http.StatusBadRequest)
+		}
+		return
+	}
+
+	if len(result.Unpriced) > 0 {
+		ids := make([]string, len(result.Unpriced))
+		for i, id := range result.Unpriced {
+			ids[i] = strconv.Itoa(id)
+		}
+		w.Header().Set("X-Unpriced-Orders", strings.Join(ids, ","))
+		h.logger.Warn().Ints("orders", result.Unpriced).Msg("Export orders. Orders without line prices were left out.")
+	}
+
+	h.logger.Info().Msgf("Exported %d orders for %s..%s", result.Exported, query.Get("from"), query.Get("to"))
+}
+
+// flushWriter sends every write on to the client, so a client that went
+// away fails the write instead of a later flush.
+type flushWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	f.written = true
+	n, err := f.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if err := http.NewResponseController(f.w).Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return n, err
+	}
+	return n, nil
+}
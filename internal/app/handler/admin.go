@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AdminOnly lets a request through only when it carries the configured admin
+// token as a bearer token. Without a configured token the admin API is closed.
+func (h *Handler) AdminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if h.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.adminToken)) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			h.logger.Warn().Str("path", r.URL.Path).Msg("Rejected admin request without a valid token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
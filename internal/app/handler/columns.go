@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/profile"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// uploadMemory is how much of a CSV tool form is held in memory, the rest
+// of the uploaded files is spooled to temporary files. The tools stream the
+// files from there, so several large uploads at once do not add up in RAM.
+const uploadMemory = 8 << 20
+
+// upload is a CSV file or workbook sent in a form field. The sheet of a
+// workbook is picked with the <field>Sheet form value, the encoding of a CSV
+// file with <field>Encoding; it is detected when not given. Without a
+// delimiter the delimiter and the header row are sniffed from the file.
+type upload struct {
+	field    string
+	file     multipart.File
+	filename string
+	opts     table.Options
+	info     *table.Info
+	profile  *profile.Profile
+}
+
+func (h *Handler) formUpload(r *http.Request, field string, delimiter rune) (*upload, error) {
+	encoding := r.FormValue(field + "Encoding")
+	if !table.ValidEncoding(encoding) {
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return nil, err
+	}
+	file = trackUpload(r, field, file)
+
+	return &upload{
+		field:    field,
+		file:     file,
+		filename: header.Filename,
+		opts: table.Options{
+			Delimiter:      delimiter,
+			Sheet:          r.FormValue(field + "Sheet"),
+			Encoding:       encoding,
+			LegacyEncoding: h.legacyEncoding,
+		},
+	}, nil
+}
+
+func (u *upload) Close() error {
+	return u.file.Close()
+}
+
+// Info detects how the upload is read, once.
+func (u *upload) Info() (table.Info, error) {
+	if u.info == nil {
+		info, err := table.Detect(u.file, u.filename, u.opts)
+		if err != nil {
+			return table.Info{}, err
+		}
+		u.info = &info
+	}
+	return *u.info, nil
+}
+
+// Reader reads the upload from its header row.
+func (u *upload) Reader() (table.Reader, error) {
+	info, err := u.Info()
+	if err != nil {
+		return nil, err
+	}
+	return table.Open(u.file, u.filename, info.Options(u.opts))
+}
+
+// readHeader returns the header row of the upload.
+func (u *upload) readHeader() ([]string, error) {
+	reader, err := u.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read header row: %w", err)
+	}
+
+	return header, nil
+}
+
+// outputOptions reads the outputFormat form value, csv by default, and
+// outputBOM to start CSV output with a BOM for Excel.
+func outputOptions(r *http.Request) (table.WriterOptions, error) {
+	var opts table.WriterOptions
+
+	switch format := strings.ToLower(r.FormValue("outputFormat")); format {
+	case "", table.FormatCSV:
+		opts.Format = table.FormatCSV
+	case table.FormatXLSX:
+		opts.Format = table.FormatXLSX
+	default:
+		return opts, fmt.Errorf("unsupported output format %q", format)
+	}
+
+	if bom := r.FormValue("outputBOM"); bom != "" {
+		var err error
+		opts.BOM, err = strconv.ParseBool(bom)
+		if err != nil {
+			return opts, fmt.Errorf("invalid outputBOM value %q", bom)
+		}
+	}
+
+	return opts, nil
+}
+
+// setOutputHeaders names the download after the output format.
+func setOutputHeaders(w http.ResponseWriter, name, format string) {
+	w.Header().Set("Content-Disposition", "attachment; filename="+name+table.Extension(format))
+	w.Header().Set("Content-Type", table.ContentType(format))
+}
+
+// setDetectedHeaders echoes how the CSV uploads were read in X-Detected-CSV,
+// one value per upload, e.g. "priceFile; format=csv; encoding=windows-1257;
+// delimiter=semicolon; quote=none; header-row=1".
+func setDetectedHeaders(w http.ResponseWriter, uploads ...*upload) {
+	for _, u := range uploads {
+		if u.info == nil || u.info.Format != table.FormatCSV {
+			continue
+		}
+		w.Header().Add("X-Detected-CSV", u.field+"; "+u.info.String())
+	}
+}
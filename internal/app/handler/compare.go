@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// CompareDealerCSVFiles compares the price lists of any number of dealers in
+// one pass. Dealers are sent as dealer1, dealer2, ... each with its own
+// dealer<N>Delimiter, dealer<N>PriceAndCodeOrder, optional dealer<N>Column
+// holding per-row dealer numbers and dealer<N>Number (N by default), or a
+// saved dealer<N>Profile in their place.
+func (h *Handler) CompareDealerCSVFiles(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	err := r.ParseMultipartForm(uploadMemory)
+	if err != nil {
+		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
+		return
+	}
+
+	var offsetPercentage int
+	if offsetPercentageStr := r.FormValue("offsetPercentage"); offsetPercentageStr != "" {
+		offsetPercentage, err = strconv.Atoi(offsetPercentageStr)
+		if err != nil {
+			http.Error(w, "Invalid offset percentage value", http.StatusBadRequest)
+			h.logger.Error().Err(err).Msg("Invalid offset percentage value")
+			return
+		}
+	}
+
+	output, err := outputOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reportMode, rep, err := formReport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var uploads []*upload
+	defer func() {
+		for _, u := range uploads {
+			u.Close()
+		}
+	}()
+
+	var dealers []services.DealerPrices
+	for n := 1; ; n++ {
+		field := "dealer" + strconv.Itoa(n)
+		if _, ok := r.MultipartForm.File[field]; !ok {
+			break
+		}
+
+		delimiterStr := r.FormValue(field + "Delimiter")
+		priceAndCodeOrder := r.FormValue(field + "PriceAndCodeOrder")
+		dealerColumnRef := r.FormValue(field + "Column")
+		number := r.FormValue(field + "Number")
+
+		p, ok := h.formProfile(w, r, field+"Profile")
+		if !ok {
+			return
+		}
+		if p != nil {
+			delimiterStr = p.Delimiter
+			priceAndCodeOrder = p.PriceColumn + "," + p.CodeColumn
+			dealerColumnRef = p.DealerColumn
+			if p.DealerNumber != "" {
+				number = p.DealerNumber
+			}
+		}
+
+		delimiter, err := table.ParseDelimiter(delimiterStr)
+		if err != nil {
+			http.Error(w, field+": "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		u, err := h.formUpload(r, field, delimiter)
+		if err != nil {
+			http.Error(w, "Error retrieving the "+field+" file", http.StatusInternalServerError)
+			h.logger.Error().Err(err).Msgf("Error retrieving the %s file", field)
+			return
+		}
+		uploads = append(uploads, u)
+		u.useProfile(p)
+
+		priceRef, codeRef, ok := columns.SplitPair(priceAndCodeOrder)
+		if !ok {
+			http.Error(w, "Invalid order values for "+field, http.StatusBadRequest)
+			h.logger.Error().Msgf("Invalid %s values", field)
+			return
+		}
+
+		refs := []string{priceRef, codeRef}
+		if dealerColumnRef != "" {
+			refs = append(refs, dealerColumnRef)
+		}
+
+		// columns are 1-based positions or header names
+		indexes, err := h.columns.Indexes(u.readHeader, refs...)
+		if err != nil {
+			http.Error(w, field+": "+err.Error(), http.StatusBadRequest)
+			h.logger.Error().Err(err).Msgf("Invalid %s columns", field)
+			return
+		}
+		dealerColumn := -1
+		if dealerColumnRef != "" {
+			dealerColumn = indexes[2]
+		}
+
+		reader, err := u.priceReader(indexes[0])
+		if err != nil {
+			http.Error(w, field+": "+err.Error(), http.StatusBadRequest)
+			h.logger.Error().Err(err).Msgf("Could not open %s file", field)
+			return
+		}
+
+		if number == "" {
+			number = strconv.Itoa(n)
+		}
+
+		// the files are read while the result is written
+		priceIndex, codeIndex, fileRep := indexes[0], indexes[1], u.report(rep)
+		dealers = append(dealers, services.DealerPrices{Number: number, Read: func(add func(services.Dealer) error) error {
+			return h.service.EachPrice(ctx, reader, priceIndex, codeIndex, dealerColumn, fileRep, add)
+		}})
+	}
+
+	if len(dealers) < 2 {
+		http.Error(w, "At least two dealer files (dealer1, dealer2) are needed", http.StatusBadRequest)
+		return
+	}
+
+	output.NumericColumns = []string{"Best Price", "Second Price", "Worst Price", "Spread", "Dealers"}
+	setDetectedHeaders(w, uploads...)
+	err = writeResult(w, "compared_dealers", output, reportMode, rep, func(writer table.Writer) error {
+		return h.service.CompareDealers(ctx, dealers, offsetPercentage, writer, rep)
+	})
+	if err != nil {
+		h.resultError(w, err)
+	}
+}
@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/dealer"
+	"github.com/trunov/virena/internal/app/postgres"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+func (h *Handler) dealerError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, postgres.ErrDealerNotFound):
+		http.Error(w, "Dealer not found", http.StatusNotFound)
+	case errors.Is(err, postgres.ErrSnapshotNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, postgres.ErrDealerExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg(action + ". Something went wrong with database.")
+	}
+}
+
+func (h *Handler) ListDealers(w http.ResponseWriter, r *http.Request) {
+	dealers, err := h.dbStorage.ListDealers(context.Background())
+	if err != nil {
+		h.dealerError(w, err, "List dealers")
+		return
+	}
+
+	if dealers == nil {
+		dealers = []dealer.Dealer{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dealers); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) CreateDealer(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	var d dealer.Dealer
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if strings.TrimSpace(d.Name) == "" {
+		http.Error(w, "Dealer name is required", http.StatusBadRequest)
+		return
+	}
+
+	if d.ProfileID != nil {
+		if _, err := h.dbStorage.GetDealerProfile(ctx, *d.ProfileID); err != nil {
+			if errors.Is(err, postgres.ErrProfileNotFound) {
+				http.Error(w, "Dealer profile not found", http.StatusBadRequest)
+				return
+			}
+			h.profileError(w, err, "Create dealer")
+			return
+		}
+	}
+
+	dealerID, err := h.dbStorage.CreateDealer(ctx, d)
+	if err != nil {
+		h.dealerError(w, err, "Create dealer")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]int{"id": dealerID}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type dealerResponse struct {
+	dealer.Dealer
+	Versions []dealer.Snapshot `json:"versions"`
+}
+
+func (h *Handler) GetDealer(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	dealerID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid dealer id", http.StatusBadRequest)
+		return
+	}
+
+	d, err := h.dbStorage.GetDealer(ctx, dealerID)
+	if err != nil {
+		h.dealerError(w, err, "Get dealer")
+		return
+	}
+
+	snapshots, err := h.dbStorage.ListDealerSnapshots(ctx, dealerID)
+	if err != nil {
+		h.dealerError(w, err, "List dealer price lists")
+		return
+	}
+
+	if snapshots == nil {
+		snapshots = []dealer.Snapshot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dealerResponse{Dealer: d, Versions: snapshots}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// UploadDealerPrices stores a price list as the next version of the dealer.
+// The file is read like the CSV tools read it: `priceAndCodeOrder`,
+// `delimiter` and `dealerColumn`, or a `profile`, the dealer's own profile
+// when neither is given.
+func (h *Handler) UploadDealerPrices(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	dealerID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid dealer id", http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseMultipartForm(uploadMemory)
+	if err != nil {
+		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
+		return
+	}
+
+	d, err := h.dbStorage.GetDealer(ctx, dealerID)
+	if err != nil {
+		h.dealerError(w, err, "Get dealer")
+		return
+	}
+
+	delimiterStr := r.FormValue("delimiter")
+	priceAndCodeOrder := r.FormValue("priceAndCodeOrder")
+	dealerColumnRef := r.FormValue("dealerColumn")
+
+	p, ok := h.formProfile(w, r, "profile")
+	if !ok {
+		return
+	}
+	if p == nil && priceAndCodeOrder == "" && d.ProfileID != nil {
+		dealerProfile, err := h.dbStorage.GetDealerProfile(ctx, *d.ProfileID)
+		if err != nil {
+			h.profileError(w, err, "Load dealer profile")
+			return
+		}
+		if err := dealerProfile.CheckCurrency(h.accounting.Settings.Currency); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		p = &dealerProfile
+	}
+	if p != nil {
+		delimiterStr = p.Delimiter
+		priceAndCodeOrder = p.PriceColumn + "," + p.CodeColumn
+		dealerColumnRef = p.DealerColumn
+	}
+
+	delimiter, err := table.ParseDelimiter(delimiterStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, err := h.formUpload(r, "file", delimiter)
+	if err != nil {
+		http.Error(w, "Error retrieving the file", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error retrieving the dealer price file")
+		return
+	}
+	defer file.Close()
+	file.useProfile(p)
+
+	priceRef, codeRef, ok := columns.SplitPair(priceAndCodeOrder)
+	if !ok {
+		http.Error(w, "Invalid order values", http.StatusBadRequest)
+		return
+	}
+
+	refs := []string{priceRef, codeRef}
+	if dealerColumnRef != "" {
+		refs = append(refs, dealerColumnRef)
+	}
+
+	// columns are 1-based positions or header names
+	indexes, err := h.columns.Indexes(file.readHeader, refs...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dealerColumn := -1
+	if dealerColumnRef != "" {
+		dealerColumn = indexes[2]
+	}
+
+	reader, err := file.priceReader(indexes[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.service.ReadPrices(ctx, reader, indexes[0], indexes[1], dealerColumn, nil)
+	if err != nil {
+		http.Error(w, "Could not read the file", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Could not read the dealer price file")
+		return
+	}
+
+	prices := make([]dealer.Price, len(rows))
+	for i, row := range rows {
+		prices[i] = dealer.Price{Code: row.Code, Price: row.Price, Dealer: row.Dealer}
+	}
+
+	snapshot, err := h.dbStorage.SaveDealerPrices(ctx, dealerID, file.filename, prices)
+	if err != nil {
+		h.dealerError(w, err, "Save dealer prices")
+		return
+	}
+
+	setDetectedHeaders(w, file)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(snapshot); err != nil {
+		h.logger.Error().Err(err).Msg("Error writing response")
+	}
+}
+
+type compareDealersRequest struct {
+	Dealers []struct {
+		ID int `json:"id"`
+		// Version 0 is the latest price list.
+		Version int `json:"version"`
+	} `json:"dealers"`
+	OffsetPercentage int `json:"offsetPercentage"`
+}
+
+// CompareStoredDealers compares stored price lists of any set of dealers
+// with the rules of the upload comparison. outputFormat and outputBOM are
+// taken from the query.
+func (h *Handler) CompareStoredDealers(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	var req compareDealersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Dealers) < 2 {
+		http.Error(w, "At least two dealers are needed", http.StatusBadRequest)
+		return
+	}
+
+	output, err := outputOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lists := make([]services.DealerPrices, 0, len(req.Dealers))
+	for _, ref := range req.Dealers {
+		d, err := h.dbStorage.GetDealer(ctx, ref.ID)
+		if err != nil {
+			h.dealerError(w, err, "Get dealer")
+			return
+		}
+
+		_, prices, err := h.dbStorage.GetDealerPrices(ctx, ref.ID, ref.Version)
+		if err != nil {
+			if errors.Is(err, postgres.ErrSnapshotNotFound) {
+				http.Error(w, "No price list of dealer "+strconv.Quote(d.Name)+" for this version", http.StatusNotFound)
+				return
+			}
+			h.dealerError(w, err, "Get dealer prices")
+			return
+		}
+
+		lists = append(lists, services.DealerPrices{Number: d.DisplayNumber(), Read: func(add func(services.Dealer) error) error {
+			for _, p := range prices {
+				if err := add(services.Dealer{Code: p.Code, Price: p.Price, Dealer: p.Dealer}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}})
+	}
+
+	output.NumericColumns = []string{"Best Price", "Second Price", "Worst Price", "Spread", "Dealers"}
+	err = writeResult(w, "compared_dealers", output, "", nil, func(writer table.Writer) error {
+		return h.service.CompareDealers(ctx, lists, req.OffsetPercentage, writer, nil)
+	})
+	if err != nil {
+		h.resultError(w, err)
+	}
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/dealer"
+	"github.com/trunov/virena/internal/app/postgres"
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// DiffPriceCSVFiles lists what changed between two versions of a dealer
+// price list: the previous and current files, read alike with delimiter
+// and priceAndCodeOrder or a saved profile. threshold leaves out price
+// changes below that many percent, outputFormat=json returns the changes
+// with their counts instead of a table.
+func (h *Handler) DiffPriceCSVFiles(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	err := r.ParseMultipartForm(uploadMemory)
+	if err != nil {
+		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
+		return
+	}
+
+	threshold, ok := formThreshold(w, r)
+	if !ok {
+		return
+	}
+
+	jsonOutput, output, err := diffOutput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reportMode, rep, err := formReport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if jsonOutput && rep != nil {
+		http.Error(w, "The report comes with csv or xlsx output", http.StatusBadRequest)
+		return
+	}
+
+	delimiterStr := r.FormValue("delimiter")
+	priceAndCodeOrder := r.FormValue("priceAndCodeOrder")
+
+	p, ok := h.formProfile(w, r, "profile")
+	if !ok {
+		return
+	}
+	if p != nil {
+		delimiterStr = p.Delimiter
+		priceAndCodeOrder = p.PriceColumn + "," + p.CodeColumn
+	}
+
+	delimiter, err := table.ParseDelimiter(delimiterStr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	priceRef, codeRef, ok := columns.SplitPair(priceAndCodeOrder)
+	if !ok {
+		http.Error(w, "Invalid order values", http.StatusBadRequest)
+		return
+	}
+
+	var uploads []*upload
+	defer func() {
+		for _, u := range uploads {
+			u.Close()
+		}
+	}()
+
+	lists := make([][]services.Dealer, 2)
+	for i, field := range []string{"previous", "current"} {
+		u, err := h.formUpload(r, field, delimiter)
+		if err != nil {
+			http.Error(w, "Error retrieving the "+field+" file", http.StatusInternalServerError)
+			h.logger.Error().Err(err).Msgf("Error retrieving the %s file", field)
+			return
+		}
+		uploads = append(uploads, u)
+		u.useProfile(p)
+
+		// columns are 1-based positions or header names
+		indexes, err := h.columns.Indexes(u.readHeader, priceRef, codeRef)
+		if err != nil {
+			http.Error(w, field+": "+err.Error(), http.StatusBadRequest)
+			h.logger.Error().Err(err).Msgf("Invalid %s columns", field)
+			return
+		}
+
+		reader, err := u.priceReader(indexes[0])
+		if err != nil {
+			http.Error(w, field+": "+err.Error(), http.StatusBadRequest)
+			h.logger.Error().Err(err).Msgf("Could not open %s file", field)
+			return
+		}
+
+		lists[i], err = h.service.ReadPrices(ctx, reader, indexes[0], indexes[1], -1, u.report(rep))
+		if err != nil {
+			http.Error(w, "Could not read "+field+" file", http.StatusInternalServerError)
+			h.logger.Error().Err(err).Msgf("Could not read %s file", field)
+			return
+		}
+	}
+
+	diff, err := h.service.DiffPrices(ctx, lists[0], lists[1], threshold)
+	if err != nil {
+		http.Error(w, "Failed during comparison of price lists", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Failed during comparison of price lists")
+		return
+	}
+
+	setDetectedHeaders(w, uploads...)
+	h.writeDiff(w, diff, jsonOutput, output, reportMode, rep)
+}
+
+// DiffStoredPrices lists what changed between two stored price lists of a
+// dealer, the from and to versions in the query. to defaults to the latest
+// version and from to the one before it.
+func (h *Handler) DiffStoredPrices(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	dealerID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid dealer id", http.StatusBadRequest)
+		return
+	}
+
+	var versions [2]int
+	for i, name := range []string{"from", "to"} {
+		if value := r.URL.Query().Get(name); value != "" {
+			versions[i], err = strconv.Atoi(value)
+			if err != nil || versions[i] < 1 {
+				http.Error(w, "Invalid "+name+" version", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	threshold, ok := formThreshold(w, r)
+	if !ok {
+		return
+	}
+
+	jsonOutput, output, err := diffOutput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.dbStorage.GetDealer(ctx, dealerID); err != nil {
+		h.dealerError(w, err, "Get dealer")
+		return
+	}
+
+	snapshot, current, err := h.dbStorage.GetDealerPrices(ctx, dealerID, versions[1])
+	if err != nil {
+		h.dealerError(w, err, "Get dealer prices")
+		return
+	}
+
+	if versions[0] == 0 {
+		versions[0] = snapshot.Version - 1
+		if versions[0] < 1 {
+			http.Error(w, "The dealer has no earlier price list", http.StatusNotFound)
+			return
+		}
+	}
+
+	_, previous, err := h.dbStorage.GetDealerPrices(ctx, dealerID, versions[0])
+	if err != nil {
+		if errors.Is(err, postgres.ErrSnapshotNotFound) {
+			http.Error(w, "No price list of the dealer for version "+strconv.Itoa(versions[0]), http.StatusNotFound)
+			return
+		}
+		h.dealerError(w, err, "Get dealer prices")
+		return
+	}
+
+	lists := make([][]services.Dealer, 2)
+	for i, prices := range [][]dealer.Price{previous, current} {
+		lists[i] = make([]services.Dealer, len(prices))
+		for j, p := range prices {
+			lists[i][j] = services.Dealer{Code: p.Code, Price: p.Price, Dealer: p.Dealer}
+		}
+	}
+
+	diff, err := h.service.DiffPrices(ctx, lists[0], lists[1], threshold)
+	if err != nil {
+		http.Error(w, "Failed during comparison of price lists", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Failed during comparison of price lists")
+		return
+	}
+
+	h.writeDiff(w, diff, jsonOutput, output, "", nil)
+}
+
+// formThreshold reads the change threshold in percent, 0 when not given.
+// Errors are written to w.
+func formThreshold(w http.ResponseWriter, r *http.Request) (float64, bool) {
+	value := r.FormValue("threshold")
+	if value == "" {
+		return 0, true
+	}
+
+	threshold, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || threshold < 0 {
+		http.Error(w, "Invalid threshold value", http.StatusBadRequest)
+		return 0, false
+	}
+	return threshold, true
+}
+
+// diffOutput takes outputFormat=json besides the table formats.
+func diffOutput(r *http.Request) (bool, table.WriterOptions, error) {
+	if strings.EqualFold(r.FormValue("outputFormat"), "json") {
+		return true, table.WriterOptions{}, nil
+	}
+	output, err := outputOptions(r)
+	return false, output, err
+}
+
+func (h *Handler) writeDiff(w http.ResponseWriter, diff services.PriceDiff, jsonOutput bool, output table.WriterOptions, reportMode string, rep *report.Report) {
+	if jsonOutput {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(diff); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	output.NumericColumns = []string{"Old Price", "New Price", "Delta"}
+	err := writeResult(w, "price_changes", output, reportMode, rep, func(writer table.Writer) error {
+		return table.WriteAll(writer, diff.Rows())
+	})
+	if err != nil {
+		h.resultError(w, err)
+	}
+}
@@ -2,20 +2,30 @@ package handler
 
 import (
 	"context"
-	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/sendgrid/sendgrid-go"
+	"github.com/trunov/virena/internal/app/accounting"
+	"github.com/trunov/virena/internal/app/antispam"
+	"github.com/trunov/virena/internal/app/attachment"
+	"github.com/trunov/virena/internal/app/columns"
+	"github.com/trunov/virena/internal/app/config"
+	"github.com/trunov/virena/internal/app/delivery"
+	"github.com/trunov/virena/internal/app/email"
+	"github.com/trunov/virena/internal/app/partcode"
 	"github.com/trunov/virena/internal/app/postgres"
-	sg "github.com/trunov/virena/internal/app/sendgrid"
 	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/storage"
+	"github.com/trunov/virena/internal/app/table"
+	"github.com/trunov/virena/internal/app/ticket"
 	"github.com/trunov/virena/internal/app/util"
 
 	"github.com/go-chi/chi/v5"
@@ -23,24 +33,78 @@ import (
 	"github.com/rs/zerolog"
 )
 
-type CodeInfo struct {
-	Price             string
-	Dealer            string
-	WorstPrice        string
-	WorstDealerNumber string
-	PriceRatio        string
-}
-
 type Handler struct {
 	dbStorage      postgres.DBStorager
 	logger         zerolog.Logger
 	service        services.FileService
-	sendGridClient *sendgrid.Client
+	files          *storage.Local
+	guard          *antispam.Guard
+	webhooks       *delivery.Verifier
+	emails         email.Settings
+	legacyEncoding string
+	columns        *columns.Resolver
+	codes          *partcode.Normalizer
+	contact        contactOptions
+	adminToken     string
+	accounting     accountingOptions
+	jobs           jobOptions
+	trustedProxies []netip.Prefix
+}
+
+type jobOptions struct {
+	MaxUploadSize int64
 }
 
-func NewHandler(dbStorage postgres.DBStorager, service services.FileService, logger zerolog.Logger, sendGridAPIKey string) *Handler {
-	sendGridClient := sendgrid.NewSendClient(sendGridAPIKey)
-	return &Handler{dbStorage: dbStorage, service: service, logger: logger, sendGridClient: sendGridClient}
+type contactOptions struct {
+	HoneypotField string
+	CaptchaField  string
+	Attachments   attachment.Policy
+	// Scanner is nil when attachments are not scanned.
+	Scanner attachment.Scanner
+}
+
+type accountingOptions struct {
+	Settings  accounting.Settings
+	Columns   []string
+	Delimiter rune
+}
+
+func NewHandler(dbStorage postgres.DBStorager, service services.FileService, codes *partcode.Normalizer, files *storage.Local, guard *antispam.Guard, webhooks *delivery.Verifier, emails email.Settings, logger zerolog.Logger, cfg config.Config) *Handler {
+	accountingOpts := accountingOptions{
+		Settings: accounting.Settings{
+			Currency: cfg.Currency,
+			VATCodes: accounting.VATCodes(cfg.AccountingVATCodes),
+			VATRate:  util.VATRate,
+		},
+		Columns: cfg.AccountingCSVColumns,
+	}
+	if cfg.AccountingCSVDelimiter != "" {
+		accountingOpts.Delimiter = rune(cfg.AccountingCSVDelimiter[0])
+	}
+
+	contactOpts := contactOptions{
+		HoneypotField: cfg.ContactHoneypotField,
+		CaptchaField:  cfg.CaptchaField,
+		Attachments: attachment.Policy{
+			MaxFiles:     cfg.ContactMaxFiles,
+			MaxFileSize:  cfg.ContactMaxFileSize,
+			MaxTotalSize: cfg.ContactMaxTotalSize,
+			AllowedTypes: cfg.ContactAllowedTypes,
+		},
+	}
+	synonyms := make(map[string][]string)
+	for column, names := range cfg.ColumnSynonyms {
+		synonyms[column] = strings.Split(names, "|")
+	}
+
+	// the config is validated on start
+	trustedProxies, _ := config.ParseTrustedProxies(cfg.TrustedProxies)
+
+	if cfg.ClamdAddr != "" {
+		contactOpts.Scanner = attachment.NewClamd(cfg.ClamdAddr, cfg.ClamdTimeout)
+	}
+
+	return &Handler{dbStorage: dbStorage, service: service, codes: codes, files: files, guard: guard, webhooks: webhooks, emails: emails, columns: columns.NewResolver(synonyms), legacyEncoding: cfg.CSVLegacyEncoding, contact: contactOpts, logger: logger, adminToken: cfg.AdminToken, accounting: accountingOpts, jobs: jobOptions{MaxUploadSize: cfg.JobMaxUploadSize}, trustedProxies: trustedProxies}
 }
 
 func (h *Handler) GetProductResults(w http.ResponseWriter, r *http.Request) {
@@ -49,7 +113,7 @@ func (h *Handler) GetProductResults(w http.ResponseWriter, r *http.Request) {
 
 	country := r.Header.Get("X-Country")
 
-	products, err := h.dbStorage.GetProductResults(ctx, productID)
+	products, err := h.dbStorage.GetProductResults(ctx, h.codes.Candidates(productID))
 	if err != nil {
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
 		h.logger.Err(err).Msg("Get product. Something went wrong with database.")
@@ -105,31 +169,81 @@ func (h *Handler) SaveOrder(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	createdDate, err := h.dbStorage.SaveOrder(ctx, order, orderID)
+	// the column holds a two letter code, anything else is dropped
+	order.PersonalInformation.Language = email.NormalizeLanguage(order.PersonalInformation.Language)
+
+	// the invoice email is queued together with the order and sent by the outbox worker
+	_, err = h.dbStorage.SaveOrder(ctx, order, orderID)
 	if err != nil {
 		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Save order. Something went wrong with database.")
 		return
 	}
 
-	// send sendgrid email
-	sg.SendOrderEmail(h.sendGridClient, orderID, order, createdDate, h.logger)
-
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) SendCustomerMessage(w http.ResponseWriter, r *http.Request) {
+	// leave some room over the attachment limit for the text fields
+	if h.contact.Attachments.MaxTotalSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.contact.Attachments.MaxTotalSize+1<<20)
+	}
+
 	err := r.ParseMultipartForm(32 << 20)
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, "Attachments are too large", http.StatusRequestEntityTooLarge)
+			h.logger.Warn().Err(err).Str("ip", h.clientIP(r)).Msg("Contact form submission is too large")
+			return
+		}
 		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
 		h.logger.Error().Err(err).Msg("Error parsing form data. File size is larger than 32MB.")
 		return
 	}
 
-	formData := make(map[string]string)
-	formData["name"] = r.FormValue("name")
-	formData["email"] = r.FormValue("email")
-	formData["subject"] = r.FormValue("subject")
-	formData["message"] = r.FormValue("message")
+	submission := antispam.Submission{
+		RemoteIP:     h.clientIP(r),
+		Email:        r.FormValue("email"),
+		Honeypot:     r.FormValue(h.contact.HoneypotField),
+		RenderedAt:   r.FormValue("formRenderedAt"),
+		CaptchaToken: r.FormValue(h.contact.CaptchaField),
+	}
+
+	if err := h.guard.Check(r.Context(), submission); err != nil {
+		var rejection *antispam.Rejection
+		if !errors.As(err, &rejection) {
+			rejection = &antispam.Rejection{Reason: "unknown", Err: err}
+		}
+
+		h.logger.Warn().
+			Str("reason", rejection.Reason).
+			Str("ip", submission.RemoteIP).
+			Str("email", submission.Email).
+			Err(rejection.Err).
+			Msg("Contact form submission rejected")
+
+		switch rejection.Reason {
+		case antispam.ReasonIPLimit, antispam.ReasonEmailLimit:
+			http.Error(w, "Too many messages, please try again later", http.StatusTooManyRequests)
+		case antispam.ReasonCaptchaDown:
+			http.Error(w, "Could not verify the captcha, please try again later", http.StatusServiceUnavailable)
+		default:
+			if rejection.Silent {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			http.Error(w, "The form could not be verified, please reload the page and try again", http.StatusBadRequest)
+		}
+		return
+	}
+
+	t := ticket.Ticket{
+		Name:    r.FormValue("name"),
+		Email:   r.FormValue("email"),
+		Subject: r.FormValue("subject"),
+		Message: r.FormValue("message"),
+	}
 
 	var fileHeaders []*multipart.FileHeader
 
@@ -140,29 +254,72 @@ func (h *Handler) SendCustomerMessage(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = sg.SendCustomerMessageEmail(h.sendGridClient, formData, fileHeaders, h.logger)
+	files, err := h.contact.Attachments.Check(fileHeaders)
 	if err != nil {
+		if errors.Is(err, attachment.ErrPolicy) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			h.logger.Warn().Err(err).Str("ip", submission.RemoteIP).Str("email", submission.Email).Msg("Contact form attachment rejected")
+			return
+		}
+		http.Error(w, "Error reading file attachment", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Failed to read file attachment")
+		return
+	}
+
+	if err := h.scanAttachments(r.Context(), files); err != nil {
+		if errors.Is(err, attachment.ErrInfected) {
+			http.Error(w, "An attachment was blocked by the virus scanner", http.StatusUnprocessableEntity)
+			h.logger.Warn().Err(err).Str("ip", submission.RemoteIP).Str("email", submission.Email).Msg("Infected contact form attachment blocked")
+			return
+		}
+		http.Error(w, "Could not scan the attachments, please try again later", http.StatusServiceUnavailable)
+		h.logger.Error().Err(err).Msg("Failed to scan file attachment")
+		return
+	}
+
+	for _, file := range files {
+		stored, err := h.storeAttachment(file)
+		if err != nil {
+			h.removeAttachments(t.Attachments)
+			http.Error(w, "Error saving file attachment", http.StatusInternalServerError)
+			h.logger.Error().Err(err).Msg("Failed to store file attachment")
+			return
+		}
+
+		t.Attachments = append(t.Attachments, stored)
+	}
+
+	// the ticket is the record of the request, the email to the staff is
+	// only a notification queued with it
+	ticketID, err := h.dbStorage.CreateTicket(context.Background(), t)
+	if err != nil {
+		h.removeAttachments(t.Attachments)
 		http.Error(w, "Error sending email", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error sending email")
+		h.logger.Error().Err(err).Msg("Failed to save customer message")
 		return
 	}
 
+	h.logger.Info().Int("ticket_id", ticketID).Msg("Customer message saved")
+
 	w.WriteHeader(http.StatusOK)
 }
 
 func (h *Handler) ProcessPriceCSVFiles(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(128 << 20)
+	err := r.ParseMultipartForm(uploadMemory)
 	if err != nil {
 		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error parsing form data. File size is larger than 128MB.")
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
 		return
 	}
 
+	// a panic while the result is written comes back from writeResult as
+	// an error, one before it has sent nothing and gets a plain 500
 	defer func() {
 		if r := recover(); r != nil {
-			errMsg := fmt.Sprintf("Panic occurred: %v", r)
-			h.logger.Error().Msg(errMsg)
-			http.Error(w, errMsg, http.StatusInternalServerError)
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			h.resultError(w, fmt.Errorf("panic: %v", r))
 		}
 	}()
 
@@ -171,221 +328,152 @@ func (h *Handler) ProcessPriceCSVFiles(w http.ResponseWriter, r *http.Request) {
 	priceAndCodeOrder := r.FormValue("priceAndCodeOrder")
 	productDelimiter := r.FormValue("productDelimiter")
 	productOrder := r.FormValue("productOrder")
-	percentage := r.FormValue("percentage")
+	// the brand of a product picks its markup tiers
+	brandColumnRef := r.FormValue("brandColumn")
 	// let's add column identifier which will be saved by name dealer if number is presented
-	dealerColumnStr := r.FormValue("dealerColumn")
-	dealerColumn := -1
+	// columns are 1-based positions or header names
+	dealerColumnRef := r.FormValue("dealerColumn")
 
-	if dealerColumnStr != "" {
-		var err error
-		dealerColumn, err = strconv.Atoi(dealerColumnStr)
-		if err != nil {
-			http.Error(w, "Invalid dealer column value", http.StatusBadRequest)
-			h.logger.Error().Err(err).Msg("Invalid dealer column value")
-			return
-		}
-		dealerColumn-- // Adjust for 0-indexing
+	// a saved dealer profile replaces the price file fields
+	priceProfile, ok := h.formProfile(w, r, "profile")
+	if !ok {
+		return
+	}
+	if priceProfile != nil {
+		priceDelimiter = priceProfile.Delimiter
+		priceAndCodeOrder = priceProfile.PriceColumn + "," + priceProfile.CodeColumn
+		dealerColumnRef = priceProfile.DealerColumn
 	}
 
-	priceFile, _, err := r.FormFile("priceFile")
-	if err != nil {
-		http.Error(w, "Error retrieving the price file", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error retrieving the price file")
+	policy, ok := h.formMarkup(w, r)
+	if !ok {
 		return
 	}
-	defer priceFile.Close()
 
-	productFile, _, err := r.FormFile("productFile")
+	output, err := outputOptions(r)
 	if err != nil {
-		http.Error(w, "Error retrieving the product file", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error retrieving the product file")
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer productFile.Close()
 
-	priceReader := csv.NewReader(priceFile)
-	if priceDelimiter == ";" {
-		priceReader.Comma = ';'
-	} else {
-		priceReader.Comma = ','
+	reportMode, rep, err := formReport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	priceReader.FieldsPerRecord = -1
 
-	priceAndCodeOrderSplit := strings.Split(priceAndCodeOrder, ",")
-	// trim for priceAndCodeOrder
-	productOrderIndex, err := strconv.Atoi(productOrder)
-	if err != nil || len(priceAndCodeOrderSplit) != 2 {
-		http.Error(w, "Invalid order values", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid order values")
+	// the delimiters only matter for CSV uploads, workbooks are read as is;
+	// when omitted they are sniffed from the files
+	priceComma, err := table.ParseDelimiter(priceDelimiter)
+	if err != nil {
+		http.Error(w, "Price file: "+err.Error(), http.StatusBadRequest)
 		return
 	}
-	priceIndex, err := strconv.Atoi(priceAndCodeOrderSplit[0])
+	productComma, err := table.ParseDelimiter(productDelimiter)
 	if err != nil {
-		http.Error(w, "Invalid index values in order", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in order")
+		http.Error(w, "Product file: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	codeIndex, err := strconv.Atoi(priceAndCodeOrderSplit[1])
+	priceFile, err := h.formUpload(r, "priceFile", priceComma)
 	if err != nil {
-		http.Error(w, "Invalid index values in order", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in order")
+		http.Error(w, "Error retrieving the price file", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error retrieving the price file")
 		return
 	}
+	defer priceFile.Close()
+	priceFile.useProfile(priceProfile)
 
-	percentageNum, err := strconv.ParseFloat(percentage, 64)
+	productFile, err := h.formUpload(r, "productFile", productComma)
 	if err != nil {
-		http.Error(w, "Invalid index values in order", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in order")
+		http.Error(w, "Error retrieving the product file", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error retrieving the product file")
 		return
 	}
+	defer productFile.Close()
 
-	// Adjust indices (assuming they start from 1 in the input)
-	priceIndex--
-	codeIndex--
-	productOrderIndex--
-
-	// Creating a map for prices
-	pricesMap := make(map[string]CodeInfo)
-	for {
-		record, err := priceReader.Read()
-		if err == io.EOF {
-			break
-		}
-
-		if err != nil {
-			http.Error(w, "Error reading the price file", http.StatusInternalServerError)
-			h.logger.Error().Err(err).Msg("Error reading the price file")
-			return
-		}
-
-		recordLength := len(record)
-
-		if recordLength > codeIndex && recordLength > priceIndex {
-			partCode := record[codeIndex]
-			partPrice := record[priceIndex]
-
-			var dealerInfo string
-			if dealerColumn >= 0 && len(record) > dealerColumn {
-				dealerInfo = record[dealerColumn]
-			}
-
-			if withAdditionalData == "" {
-				pricesMap[partCode] = CodeInfo{Price: partPrice, Dealer: dealerInfo}
-			} else {
-				pricesMap[partCode] = CodeInfo{
-					Price:             partPrice,
-					Dealer:            dealerInfo,
-					WorstPrice:        record[recordLength-3],
-					WorstDealerNumber: record[recordLength-2],
-					PriceRatio:        record[recordLength-1],
-				}
-			}
-		}
+	priceRef, codeRef, ok := columns.SplitPair(priceAndCodeOrder)
+	if !ok || strings.TrimSpace(productOrder) == "" {
+		http.Error(w, "Invalid order values", http.StatusBadRequest)
+		h.logger.Error().Msg("Invalid order values")
+		return
 	}
 
-	productReader := csv.NewReader(productFile)
-	if productDelimiter == ";" {
-		productReader.Comma = ';'
-	} else {
-		productReader.Comma = ','
+	priceRefs := []string{priceRef, codeRef}
+	if dealerColumnRef != "" {
+		priceRefs = append(priceRefs, dealerColumnRef)
 	}
 
-	productRecords, err := productReader.ReadAll()
+	priceColumns, err := h.columns.Indexes(priceFile.readHeader, priceRefs...)
 	if err != nil {
-		http.Error(w, "Error reading the product file", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error reading the product file")
+		http.Error(w, "Price file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Invalid price file columns")
 		return
 	}
+	priceIndex, codeIndex := priceColumns[0], priceColumns[1]
+	dealerColumn := -1
+	if dealerColumnRef != "" {
+		dealerColumn = priceColumns[2]
+	}
 
-	for i, record := range productRecords {
-		if i == 0 {
-			if priceIndex == len(record)-1 {
-				record = append(record, "new price")
-			} else {
-				record = append(record[:priceIndex+1], append([]string{"new price"}, record[priceIndex+1:]...)...)
-			}
-
-			if dealerColumn >= 0 {
-				record = append(record, "dealer")
-			}
-
-			if withAdditionalData != "" {
-				record = append(record, "Worst Price", "Worst Dealer Number", "Price Ratio")
-			}
-
-			productRecords[i] = record
-			continue
-		}
-
-		productCode := record[productOrderIndex]
-
-		info, ok := pricesMap[productCode]
-		if !ok {
-			if productCode[0] == '0' {
-				unprefixedCode := productCode[1:]
-				info, ok = pricesMap[unprefixedCode]
-			} else {
-				prefixedCode := "0" + productCode
-				info, ok = pricesMap[prefixedCode]
-			}
-		}
-
-		var newPriceStr string
-		if ok {
-			cleanedPrice := strings.ReplaceAll(info.Price, " ", "")
-			cleanedPrice = strings.ReplaceAll(cleanedPrice, ",", ".")
-
-			if price, err := strconv.ParseFloat(cleanedPrice, 64); err == nil {
-				newPrice := price * (1 + percentageNum/100)
-				if newPrice > 10 {
-					newPriceStr = fmt.Sprintf("%.2f", newPrice)
-				} else {
-					newPriceStr = fmt.Sprintf("%.3f", newPrice)
-				}
-			} else {
-				newPriceStr = "N/A"
-			}
-		} else {
-			newPriceStr = "N/A"
-		}
+	productRefs := []string{productOrder}
+	if brandColumnRef != "" {
+		productRefs = append(productRefs, brandColumnRef)
+	}
 
-		if priceIndex == len(record)-1 {
-			record = append(record, newPriceStr)
-		} else {
-			record = append(record[:priceIndex+1], append([]string{newPriceStr}, record[priceIndex+1:]...)...)
-		}
+	productColumns, err := h.columns.Indexes(productFile.readHeader, productRefs...)
+	if err != nil {
+		http.Error(w, "Product file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Invalid product file columns")
+		return
+	}
+	productOrderIndex := productColumns[0]
+	brandColumn := -1
+	if brandColumnRef != "" {
+		brandColumn = productColumns[1]
+	}
 
-		if dealerColumn >= 0 {
-			dealerInfo := info.Dealer
-			record = append(record, dealerInfo)
-		}
+	priceReader, err := priceFile.priceReader(priceIndex)
+	if err != nil {
+		http.Error(w, "Price file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error opening the price file")
+		return
+	}
 
-		if withAdditionalData != "" {
-			record = append(record, info.WorstPrice, info.WorstDealerNumber, info.PriceRatio)
-		}
+	productReader, err := productFile.Reader()
+	if err != nil {
+		http.Error(w, "Product file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error opening the product file")
+		return
+	}
 
-		productRecords[i] = record
+	update := services.PriceUpdate{
+		PriceIndex:         priceIndex,
+		CodeIndex:          codeIndex,
+		DealerColumn:       dealerColumn,
+		WithAdditionalData: withAdditionalData != "",
+		ProductCodeIndex:   productOrderIndex,
+		BrandColumn:        brandColumn,
+		Markup:             policy,
 	}
 
-	w.Header().Set("Content-Disposition", "attachment; filename=updated_products.csv")
-	w.Header().Set("Content-Type", "text/csv")
-	csvWriter := csv.NewWriter(w)
-	err = csvWriter.WriteAll(productRecords)
+	output.NumericColumns = []string{"new price", "Worst Price", "Price Ratio"}
+	setDetectedHeaders(w, priceFile, productFile)
+	err = writeResult(w, "updated_products", output, reportMode, rep, func(writer table.Writer) error {
+		return h.service.UpdatePrices(context.Background(), priceReader, productReader, update, writer, priceFile.report(rep), productFile.report(rep), rep)
+	})
 	if err != nil {
-		http.Error(w, "Error writing to output file", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error writing to output file")
-		return
+		h.resultError(w, err)
 	}
 }
 
 func (h *Handler) ProcessDealerCSVFiles(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 
-	err := r.ParseMultipartForm(128 << 20)
+	err := r.ParseMultipartForm(uploadMemory)
 	if err != nil {
 		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error parsing form data. File size is larger than 128MB.")
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
 		return
 	}
 
@@ -402,7 +490,32 @@ func (h *Handler) ProcessDealerCSVFiles(w http.ResponseWriter, r *http.Request)
 
 	firstDealerNumber := r.FormValue("firstDealerNumber")
 
-	dealerColumn := -1
+	// saved dealer profiles replace the fields of their file
+	dealerOneProfile, ok := h.formProfile(w, r, "dealerOneProfile")
+	if !ok {
+		return
+	}
+	if dealerOneProfile != nil {
+		dealerOneDelimiter = dealerOneProfile.Delimiter
+		dealerOnePriceAndCodeOrder = dealerOneProfile.PriceColumn + "," + dealerOneProfile.CodeColumn
+		dealerColumnStr = dealerOneProfile.DealerColumn
+		if dealerOneProfile.DealerNumber != "" {
+			firstDealerNumber = dealerOneProfile.DealerNumber
+		}
+	}
+
+	dealerTwoProfile, ok := h.formProfile(w, r, "dealerTwoProfile")
+	if !ok {
+		return
+	}
+	if dealerTwoProfile != nil {
+		dealerTwoDelimiter = dealerTwoProfile.Delimiter
+		dealerTwoPriceAndCodeOrder = dealerTwoProfile.PriceColumn + "," + dealerTwoProfile.CodeColumn
+		if dealerTwoProfile.DealerNumber != "" {
+			secondDealerNumberStr = dealerTwoProfile.DealerNumber
+		}
+	}
+
 	var secondDealerNumber, offsetPercentage int
 
 	if offsetPercentageStr != "" {
@@ -415,16 +528,8 @@ func (h *Handler) ProcessDealerCSVFiles(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	if dealerColumnStr != "" {
+	if dealerColumnStr != "" || (dealerTwoProfile != nil && dealerTwoProfile.DealerNumber != "") {
 		var err error
-		dealerColumn, err = strconv.Atoi(dealerColumnStr)
-		if err != nil {
-			http.Error(w, "Invalid dealer column value", http.StatusBadRequest)
-			h.logger.Error().Err(err).Msg("Invalid dealer column value")
-			return
-		}
-		dealerColumn-- // Adjust for 0-indexing
-
 		secondDealerNumber, err = strconv.Atoi(secondDealerNumberStr)
 		if err != nil {
 			http.Error(w, "Invalid dealer number value", http.StatusBadRequest)
@@ -433,215 +538,298 @@ func (h *Handler) ProcessDealerCSVFiles(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	dealerOne, _, err := r.FormFile("dealerOne")
+	output, err := outputOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reportMode, rep, err := formReport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dealerOneComma, err := table.ParseDelimiter(dealerOneDelimiter)
+	if err != nil {
+		http.Error(w, "Dealer one file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	dealerTwoComma, err := table.ParseDelimiter(dealerTwoDelimiter)
+	if err != nil {
+		http.Error(w, "Dealer two file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dealerOne, err := h.formUpload(r, "dealerOne", dealerOneComma)
 	if err != nil {
 		http.Error(w, "Error retrieving the dealerOne file", http.StatusInternalServerError)
 		h.logger.Error().Err(err).Msg("Error retrieving the dealerOne file")
 		return
 	}
 	defer dealerOne.Close()
+	dealerOne.useProfile(dealerOneProfile)
 
-	dealerTwo, _, err := r.FormFile("dealerTwo")
+	dealerTwo, err := h.formUpload(r, "dealerTwo", dealerTwoComma)
 	if err != nil {
 		http.Error(w, "Error retrieving the dealerTwo file", http.StatusInternalServerError)
 		h.logger.Error().Err(err).Msg("Error retrieving the dealerTwo file")
 		return
 	}
 	defer dealerTwo.Close()
+	dealerTwo.useProfile(dealerTwoProfile)
 
-	dealerOnePriceAndCodeOrderSplit := strings.Split(dealerOnePriceAndCodeOrder, ",")
-	if len(dealerOnePriceAndCodeOrderSplit) != 2 {
+	dealerOnePriceRef, dealerOneCodeRef, ok := columns.SplitPair(dealerOnePriceAndCodeOrder)
+	if !ok {
 		http.Error(w, "Invalid order values", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid dealer one values")
+		h.logger.Error().Msg("Invalid dealer one values")
 		return
 	}
 
-	dealerOnePriceIndex, err := strconv.Atoi(dealerOnePriceAndCodeOrderSplit[0])
-	if err != nil {
-		http.Error(w, "Invalid index values in dealer one", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in dealer one")
-		return
+	dealerOneRefs := []string{dealerOnePriceRef, dealerOneCodeRef}
+	if dealerColumnStr != "" {
+		dealerOneRefs = append(dealerOneRefs, dealerColumnStr)
 	}
 
-	dealerOneCodeIndex, err := strconv.Atoi(dealerOnePriceAndCodeOrderSplit[1])
+	// columns are 1-based positions or header names
+	dealerOneColumns, err := h.columns.Indexes(dealerOne.readHeader, dealerOneRefs...)
 	if err != nil {
-		http.Error(w, "Invalid index values in order", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in dealer one")
+		http.Error(w, "Dealer one file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Invalid dealer one columns")
 		return
 	}
-
-	// Adjust indices (assuming they start from 1 in the input)
-	dealerOnePriceIndex--
-	dealerOneCodeIndex--
-
-	dealerTwoReader := csv.NewReader(dealerTwo)
-	if dealerTwoDelimiter == ";" {
-		dealerTwoReader.Comma = ';'
-	} else {
-		dealerTwoReader.Comma = ','
+	dealerOnePriceIndex, dealerOneCodeIndex := dealerOneColumns[0], dealerOneColumns[1]
+	dealerColumn := -1
+	if dealerColumnStr != "" {
+		dealerColumn = dealerOneColumns[2]
 	}
 
-	dealerTwoPriceAndCodeOrderSplit := strings.Split(dealerTwoPriceAndCodeOrder, ",")
-	if len(dealerTwoPriceAndCodeOrderSplit) != 2 {
+	dealerTwoPriceRef, dealerTwoCodeRef, ok := columns.SplitPair(dealerTwoPriceAndCodeOrder)
+	if !ok {
 		http.Error(w, "Invalid order values", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid dealer one values")
+		h.logger.Error().Msg("Invalid dealer two values")
 		return
 	}
-	dealerTwoPriceIndex, err := strconv.Atoi(dealerTwoPriceAndCodeOrderSplit[0])
+
+	dealerTwoColumns, err := h.columns.Indexes(dealerTwo.readHeader, dealerTwoPriceRef, dealerTwoCodeRef)
 	if err != nil {
-		http.Error(w, "Invalid index values in dealer one", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in dealer one")
+		http.Error(w, "Dealer two file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Invalid dealer two columns")
 		return
 	}
+	dealerTwoPriceIndex, dealerTwoCodeIndex := dealerTwoColumns[0], dealerTwoColumns[1]
 
-	dealerTwoCodeIndex, err := strconv.Atoi(dealerTwoPriceAndCodeOrderSplit[1])
+	dealerOneReader, err := dealerOne.priceReader(dealerOnePriceIndex)
 	if err != nil {
-		http.Error(w, "Invalid index values in order", http.StatusBadRequest)
-		h.logger.Error().Err(err).Msg("Invalid index values in dealer one")
+		http.Error(w, "Dealer one file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Could not open dealer one file")
 		return
 	}
 
-	dealerTwoPriceIndex--
-	dealerTwoCodeIndex--
-
-	d1, err := h.service.ReadFile(ctx, dealerOne, rune(dealerOneDelimiter[0]), dealerOnePriceIndex, dealerOneCodeIndex, dealerColumn)
+	dealerTwoReader, err := dealerTwo.priceReader(dealerTwoPriceIndex)
 	if err != nil {
-		http.Error(w, "Could not read dealer one file", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Could not read dealer one file")
+		http.Error(w, "Dealer two file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Could not open dealer two file")
 		return
 	}
 
-	d2, err := h.service.ReadFileToMap(ctx, dealerTwo, rune(dealerTwoDelimiter[0]), dealerTwoPriceIndex, dealerTwoCodeIndex)
+	// dealer two is the lookup side, dealer one is streamed to the output
+	dealerOneRep := dealerOne.report(rep)
+	d2, err := h.service.ReadFileToMap(ctx, dealerTwoReader, dealerTwoPriceIndex, dealerTwoCodeIndex, dealerTwo.report(rep))
 	if err != nil {
 		http.Error(w, "Could not read dealer two file", http.StatusInternalServerError)
 		h.logger.Error().Err(err).Msg("Could not read dealer two file")
 		return
 	}
 
-	res, err := h.service.CompareAndProcessFiles(ctx, d1, d2, dealerColumn, secondDealerNumber, offsetPercentage, firstDealerNumber)
-	if err != nil {
-		http.Error(w, "Failed during comparison of dealers", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Failed during comparison of dealers")
-		return
+	comparison := services.DealerComparison{
+		PriceIndex:         dealerOnePriceIndex,
+		CodeIndex:          dealerOneCodeIndex,
+		DealerColumn:       dealerColumn,
+		FirstDealerNumber:  firstDealerNumber,
+		SecondDealerNumber: secondDealerNumber,
+		OffsetPercentage:   offsetPercentage,
 	}
 
-	w.Header().Set("Content-Disposition", "attachment; filename=updated_products.csv")
-	w.Header().Set("Content-Type", "text/csv")
-	csvWriter := csv.NewWriter(w)
-	err = csvWriter.WriteAll(res)
+	output.NumericColumns = []string{"Best Price", "Second Price", "Price Ratio"}
+	setDetectedHeaders(w, dealerOne, dealerTwo)
+	err = writeResult(w, "updated_products", output, reportMode, rep, func(writer table.Writer) error {
+		return h.service.CompareAndProcessFiles(ctx, dealerOneReader, d2, comparison, writer, dealerOneRep, rep)
+	})
 	if err != nil {
-		http.Error(w, "Error writing to output file", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error writing to output file")
-		return
+		h.resultError(w, err)
 	}
 }
 
 func (h *Handler) AttachExtraField(w http.ResponseWriter, r *http.Request) {
-	err := r.ParseMultipartForm(128 << 20)
+	err := r.ParseMultipartForm(uploadMemory)
 	if err != nil {
 		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
-		h.logger.Error().Err(err).Msg("Error parsing form data. File size is larger than 128MB.")
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
 		return
 	}
 
-	dealerOneDelimiter := rune(r.FormValue("dealerOneDelimiter")[0])
+	dealerOneDelimiterStr := r.FormValue("dealerOneDelimiter")
+	dealerTwoDelimiterStr := r.FormValue("dealerTwoDelimiter")
+
+	// columns are 1-based positions or header names
 	firstDealerCodeOrderStr := r.FormValue("firstDealerCodeOrder")
-	firstDealerCodeOrder, err := strconv.Atoi(firstDealerCodeOrderStr)
-	if err != nil || firstDealerCodeOrder <= 0 {
-		http.Error(w, "Invalid value for firstDealerCodeOrder", http.StatusBadRequest)
-		h.logger.Error().Msgf("Invalid value for firstDealerCodeOrder: %s", firstDealerCodeOrderStr)
+	secondDealerCodeOrderStr := r.FormValue("secondDealerCodeOrder")
+	extraField := r.FormValue("extraField")
+
+	// saved dealer profiles replace the delimiter and code column fields
+	dealerOneProfile, ok := h.formProfile(w, r, "dealerOneProfile")
+	if !ok {
 		return
 	}
+	if dealerOneProfile != nil {
+		dealerOneDelimiterStr = dealerOneProfile.Delimiter
+		firstDealerCodeOrderStr = dealerOneProfile.CodeColumn
+	}
 
-	dealerTwoDelimiter := rune(r.FormValue("dealerTwoDelimiter")[0])
-	secondDealerCodeOrderStr := r.FormValue("secondDealerCodeOrder")
-	secondDealerCodeOrder, err := strconv.Atoi(secondDealerCodeOrderStr)
-	if err != nil || secondDealerCodeOrder <= 0 {
-		http.Error(w, "Invalid value for secondDealerCodeOrder", http.StatusBadRequest)
-		h.logger.Error().Msgf("Invalid value for secondDealerCodeOrder: %s", secondDealerCodeOrderStr)
+	dealerTwoProfile, ok := h.formProfile(w, r, "dealerTwoProfile")
+	if !ok {
+		return
+	}
+	if dealerTwoProfile != nil {
+		dealerTwoDelimiterStr = dealerTwoProfile.Delimiter
+		secondDealerCodeOrderStr = dealerTwoProfile.CodeColumn
+	}
+
+	dealerOneDelimiter, err := table.ParseDelimiter(dealerOneDelimiterStr)
+	if err != nil {
+		http.Error(w, "Dealer one file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	dealerTwoDelimiter, err := table.ParseDelimiter(dealerTwoDelimiterStr)
+	if err != nil {
+		http.Error(w, "Dealer two file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	output, err := outputOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	dealerOne, _, err := r.FormFile("dealerOne")
+	reportMode, rep, err := formReport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dealerOne, err := h.formUpload(r, "dealerOne", dealerOneDelimiter)
 	if err != nil {
 		http.Error(w, "Error retrieving the dealerOne file", http.StatusInternalServerError)
 		h.logger.Error().Err(err).Msg("Error retrieving the dealerOne file")
 		return
 	}
 	defer dealerOne.Close()
+	dealerOne.useProfile(dealerOneProfile)
 
-	dealerTwo, _, err := r.FormFile("dealerTwo")
+	dealerTwo, err := h.formUpload(r, "dealerTwo", dealerTwoDelimiter)
 	if err != nil {
 		http.Error(w, "Error retrieving the dealerTwo file", http.StatusInternalServerError)
 		h.logger.Error().Err(err).Msg("Error retrieving the dealerTwo file")
 		return
 	}
 	defer dealerTwo.Close()
+	dealerTwo.useProfile(dealerTwoProfile)
 
-	extraField := r.FormValue("extraField")
-	extraFieldIndex, err := strconv.Atoi(extraField)
-	if err != nil || extraFieldIndex <= 0 {
-		http.Error(w, "Invalid value for extraField", http.StatusBadRequest)
-		h.logger.Error().Msgf("Invalid value for extraField: %s", extraField)
+	dealerOneColumns, err := h.columns.Indexes(dealerOne.readHeader, firstDealerCodeOrderStr, extraField)
+	if err != nil {
+		http.Error(w, "Dealer one file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msgf("Invalid dealer one columns: %s, %s", firstDealerCodeOrderStr, extraField)
 		return
 	}
+	firstDealerCodeIndex, extraFieldIndex := dealerOneColumns[0], dealerOneColumns[1]
 
-	dealerTwoReader := csv.NewReader(dealerTwo)
-	dealerTwoReader.Comma = dealerTwoDelimiter
+	dealerTwoColumns, err := h.columns.Indexes(dealerTwo.readHeader, secondDealerCodeOrderStr)
+	if err != nil {
+		http.Error(w, "Dealer two file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msgf("Invalid value for secondDealerCodeOrder: %s", secondDealerCodeOrderStr)
+		return
+	}
+	secondDealerCodeIndex := dealerTwoColumns[0]
 
-	dealerOneReader := csv.NewReader(dealerOne)
-	dealerOneReader.Comma = dealerOneDelimiter
+	dealerOneReader, err := dealerOne.Reader()
+	if err != nil {
+		http.Error(w, "Dealer one file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error opening dealerOne file")
+		return
+	}
 
-	dealerOneData := make(map[string]string)
-	for {
-		record, err := dealerOneReader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			http.Error(w, "Error reading dealerTwo file", http.StatusInternalServerError)
-			h.logger.Error().Err(err).Msg("Error reading dealerTwo file")
-			return
-		}
-		if len(record) > secondDealerCodeOrder-1 && len(record) > extraFieldIndex-1 {
-			dealerOneData[record[firstDealerCodeOrder-1]] = record[extraFieldIndex-1]
-		}
+	dealerTwoReader, err := dealerTwo.Reader()
+	if err != nil {
+		http.Error(w, "Dealer two file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error opening dealerTwo file")
+		return
 	}
 
-	var result strings.Builder
-	writer := csv.NewWriter(&result)
-	writer.Comma = dealerOneDelimiter
+	// the output keeps the delimiter of dealer one, given or sniffed
+	output.Delimiter = dealerOne.info.Delimiter
+	opts := services.JoinOptions{
+		SourceKey:  firstDealerCodeIndex,
+		TargetKey:  secondDealerCodeIndex,
+		Columns:    []int{extraFieldIndex},
+		Type:       services.JoinLeft,
+		Duplicates: services.DuplicatesLast,
+	}
 
-	for {
-		record, err := dealerTwoReader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			http.Error(w, "Error processing dealerOne file", http.StatusInternalServerError)
-			h.logger.Error().Err(err).Msg("Error processing dealerOne file")
-			return
-		}
+	// the join streams into the response, its errors after the first row
+	// can not change the status any more
+	setDetectedHeaders(w, dealerOne, dealerTwo)
+	err = writeResult(w, "updated_dealer_one", output, reportMode, rep, func(writer table.Writer) error {
+		return h.service.Join(context.Background(), dealerOneReader, dealerTwoReader, opts, writer, dealerOne.report(rep), dealerTwo.report(rep), rep)
+	})
+	if err != nil {
+		h.resultError(w, err)
+	}
+}
 
-		if len(record) > secondDealerCodeOrder-1 {
-			code := record[secondDealerCodeOrder-1]
-			if extraValue, exists := dealerOneData[code]; exists {
-				record = append(record, extraValue)
-			} else {
-				record = append(record, "")
-			}
+// clientIP is the address of the client. X-Forwarded-For is only read when
+// the connection comes from a trusted proxy, anyone else could send any
+// address. Its entries are taken from the right, the ones our proxies
+// appended, up to the first address that is not a proxy of ours.
+func (h *Handler) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !h.trustedProxy(host) {
+		return host
+	}
+
+	var forwarded []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(value, ",")...)
+	}
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		host = address
+		if !h.trustedProxy(address) {
+			break
 		}
-		writer.Write(record)
 	}
-	writer.Flush()
+	return host
+}
 
-	w.Header().Set("Content-Disposition", "attachment; filename=updated_dealer_one.csv")
-	w.Header().Set("Content-Type", "text/csv")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write([]byte(result.String()))
+func (h *Handler) trustedProxy(address string) bool {
+	addr, err := netip.ParseAddr(address)
 	if err != nil {
-		h.logger.Error().Err(err).Msg("Error writing response")
+		return false
+	}
+	addr = addr.Unmap()
+	for _, proxy := range h.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
 	}
+	return false
 }
 
 func (h *Handler) PingDB(w http.ResponseWriter, r *http.Request) {
@@ -662,9 +850,9 @@ func NewRouter(h *Handler) chi.Router {
 
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://www.virena.ee", "http://localhost:3000"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Country"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   []string{"Link", "Content-Disposition", "X-Detected-CSV", "Location"},
 		AllowCredentials: false,
 		MaxAge:           300, // Maximum value not ignored by any of the major browsers
 	}))
@@ -678,7 +866,46 @@ func NewRouter(h *Handler) chi.Router {
 		r.Post("/contact", h.SendCustomerMessage)
 		r.Post("/handle-price-csv", h.ProcessPriceCSVFiles)
 		r.Post("/handle-dealer-csv", h.ProcessDealerCSVFiles)
+		r.Post("/compare-dealers-csv", h.CompareDealerCSVFiles)
 		r.Post("/attach-extra-column", h.AttachExtraField)
+		r.Post("/join-csv", h.JoinCSVFiles)
+		r.Post("/diff-prices-csv", h.DiffPriceCSVFiles)
+		r.Post("/webhooks/sendgrid", h.SendGridEvents)
+		r.Post("/jobs/{tool}", h.CreateJob)
+		r.Get("/jobs/{id}", h.GetJob)
+		r.Get("/jobs/{id}/result", h.GetJobResult)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(h.AdminOnly)
+
+			r.Post("/payments/import", h.ImportBankStatement)
+			r.Get("/payments/unmatched", h.GetUnmatchedPayments)
+			r.Get("/export/orders", h.ExportOrders)
+			r.Get("/outbox", h.ListOutboxMessages)
+			r.Post("/outbox/{id}/retry", h.RetryOutboxMessage)
+			r.Get("/orders/{id}", h.GetOrder)
+			r.Get("/orders/{id}/email-preview", h.PreviewOrderEmail)
+			r.Get("/tickets", h.ListTickets)
+			r.Get("/tickets/{id}", h.GetTicket)
+			r.Patch("/tickets/{id}", h.UpdateTicket)
+			r.Get("/tickets/{id}/attachments/{attachmentID}", h.GetTicketAttachment)
+			r.Get("/dealer-profiles", h.ListDealerProfiles)
+			r.Post("/dealer-profiles", h.CreateDealerProfile)
+			r.Get("/dealer-profiles/{id}", h.GetDealerProfile)
+			r.Put("/dealer-profiles/{id}", h.UpdateDealerProfile)
+			r.Delete("/dealer-profiles/{id}", h.DeleteDealerProfile)
+			r.Get("/dealers", h.ListDealers)
+			r.Post("/dealers", h.CreateDealer)
+			r.Post("/dealers/compare", h.CompareStoredDealers)
+			r.Get("/dealers/{id}", h.GetDealer)
+			r.Post("/dealers/{id}/prices", h.UploadDealerPrices)
+			r.Get("/dealers/{id}/diff", h.DiffStoredPrices)
+			r.Get("/markup-policies", h.ListMarkupPolicies)
+			r.Post("/markup-policies", h.CreateMarkupPolicy)
+			r.Get("/markup-policies/{id}", h.GetMarkupPolicy)
+			r.Put("/markup-policies/{id}", h.UpdateMarkupPolicy)
+			r.Delete("/markup-policies/{id}", h.DeleteMarkupPolicy)
+		})
 	})
 
 	return r
@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/job"
+	"github.com/trunov/virena/internal/app/postgres"
+)
+
+// jobTools are the CSV tools that can run as jobs, by their path under /api.
+func (h *Handler) jobTools() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"handle-price-csv":    h.ProcessPriceCSVFiles,
+		"handle-dealer-csv":   h.ProcessDealerCSVFiles,
+		"compare-dealers-csv": h.CompareDealerCSVFiles,
+		"attach-extra-column": h.AttachExtraField,
+		"join-csv":            h.JoinCSVFiles,
+		"diff-prices-csv":     h.DiffPriceCSVFiles,
+	}
+}
+
+// CreateJob takes the same multipart form as the tool in the path and
+// queues it, so large files are not processed within the request.
+func (h *Handler) CreateJob(w http.ResponseWriter, r *http.Request) {
+	tool := chi.URLParam(r, "tool")
+	if _, ok := h.jobTools()[tool]; !ok {
+		http.Error(w, "Unknown tool", http.StatusNotFound)
+		return
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err != nil || mediaType != "multipart/form-data" {
+		http.Error(w, "Expected a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	id, err := job.NewID()
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Could not generate a job id")
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, h.jobs.MaxUploadSize)
+	key, _, err := h.files.Save("jobs/input", body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Upload is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Could not store the upload", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Could not store the job upload")
+		return
+	}
+
+	j := job.Job{ID: id, Tool: tool, Status: job.StatusQueued, ContentType: contentType, InputKey: key}
+	if err := h.dbStorage.CreateJob(context.Background(), j); err != nil {
+		h.files.Remove(key)
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Create job. Something went wrong with database.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+id)
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(jobResponse{Job: j}); err != nil {
+		h.logger.Error().Err(err).Msg("Error writing response")
+	}
+}
+
+type jobResponse struct {
+	job.Job
+	ResultURL string `json:"resultUrl,omitempty"`
+}
+
+func (h *Handler) loadJob(w http.ResponseWriter, r *http.Request) (job.Job, bool) {
+	j, err := h.dbStorage.GetJob(context.Background(), chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, postgres.ErrJobNotFound) {
+			http.Error(w, "Job not found", http.StatusNotFound)
+			return job.Job{}, false
+		}
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Get job. Something went wrong with database.")
+		return job.Job{}, false
+	}
+
+	return j, true
+}
+
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	res := jobResponse{Job: j}
+	if j.Status == job.StatusDone {
+		res.ResultURL = "/api/jobs/" + j.ID + "/result"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) GetJobResult(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.loadJob(w, r)
+	if !ok {
+		return
+	}
+
+	if j.Status != job.StatusDone {
+		http.Error(w, "Job is "+j.Status, http.StatusConflict)
+		return
+	}
+
+	file, err := h.files.Open(j.ResultKey)
+	if err != nil {
+		http.Error(w, "Result is not available", http.StatusInternalServerError)
+		h.logger.Err(err).Str("key", j.ResultKey).Msg("Failed to open stored job result")
+		return
+	}
+	defer file.Close()
+
+	for _, detected := range j.Detected {
+		w.Header().Add("X-Detected-CSV", detected)
+	}
+	w.Header().Set("Content-Type", j.ResultContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": j.ResultFilename}))
+	if _, err := io.Copy(w, file); err != nil {
+		h.logger.Error().Err(err).Msg("Error writing response")
+	}
+}
+
+// RunJob replays the stored upload through the tool handler and keeps what
+// it writes as the job result. A tool error fails the job with its message.
+func (h *Handler) RunJob(ctx context.Context, j job.Job, progress func(int)) (job.Result, error) {
+	tool, ok := h.jobTools()[j.Tool]
+	if !ok {
+		return job.Result{}, fmt.Errorf("unknown tool %q", j.Tool)
+	}
+
+	input, err := h.files.Open(j.InputKey)
+	if err != nil {
+		return job.Result{}, fmt.Errorf("open job input: %w", err)
+	}
+	defer input.Close()
+
+	key, output, err := h.files.Create("jobs/result")
+	if err != nil {
+		return job.Result{}, fmt.Errorf("create job result: %w", err)
+	}
+
+	res := &jobResponseWriter{header: make(http.Header), file: output}
+
+	r, err := http.NewRequestWithContext(withProgress(ctx, progress), http.MethodPost, "/api/"+j.Tool, input)
+	if err != nil {
+		output.Close()
+		h.files.Remove(key)
+		return job.Result{}, err
+	}
+	r.Header.Set("Content-Type", j.ContentType)
+
+	tool(res, r)
+	if r.MultipartForm != nil {
+		r.MultipartForm.RemoveAll()
+	}
+
+	if err := output.Close(); err != nil && res.err == nil {
+		res.err = err
+	}
+
+	if res.status() >= http.StatusBadRequest || res.err != nil {
+		defer h.files.Remove(key)
+		if res.err != nil {
+			return job.Result{}, fmt.Errorf("write job result: %w", res.err)
+		}
+		return job.Result{}, errors.New(h.jobError(key, res.status()))
+	}
+
+	filename := "result"
+	if _, params, err := mime.ParseMediaType(res.header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		filename = params["filename"]
+	}
+
+	return job.Result{
+		Key:         key,
+		ContentType: res.header.Get("Content-Type"),
+		Filename:    filename,
+		Detected:    res.header.Values("X-Detected-CSV"),
+	}, nil
+}
+
+// jobError is the error message the tool wrote, as the client would have
+// seen it in the response.
+func (h *Handler) jobError(key string, status int) string {
+	msg := http.StatusText(status)
+
+	file, err := h.files.Open(key)
+	if err != nil {
+		return msg
+	}
+	defer file.Close()
+
+	body, err := io.ReadAll(io.LimitReader(file, 1024))
+	if err == nil && len(strings.TrimSpace(string(body))) > 0 {
+		msg = strings.TrimSpace(string(body))
+	}
+	return msg
+}
+
+// jobResponseWriter writes a tool response to the result file.
+type jobResponseWriter struct {
+	header http.Header
+	code   int
+	file   *os.File
+	err    error
+}
+
+func (w *jobResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *jobResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *jobResponseWriter) Write(p []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.file.Write(p)
+	w.err = err
+	return n, err
+}
+
+// failResult fails a job whose tool broke off after writing part of the
+// result.
+func (w *jobResponseWriter) failResult(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *jobResponseWriter) status() int {
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
+
+type progressKey struct{}
+
+func withProgress(ctx context.Context, report func(int)) context.Context {
+	return context.WithValue(ctx, progressKey{}, &progressTracker{report: report})
+}
+
+// progressTracker turns how far the uploads of a job were read into a
+// percentage. Files are read more than once, the header row first, so only
+// the furthest position of each counts.
+type progressTracker struct {
+	mu       sync.Mutex
+	report   func(int)
+	total    int64
+	furthest map[string]int64
+}
+
+// trackUpload wraps the file of a form field when the request runs as a job.
+func trackUpload(r *http.Request, field string, file multipart.File) multipart.File {
+	tracker, ok := r.Context().Value(progressKey{}).(*progressTracker)
+	if !ok {
+		return file
+	}
+
+	tracker.mu.Lock()
+	if tracker.furthest == nil {
+		tracker.furthest = make(map[string]int64)
+		for _, headers := range r.MultipartForm.File {
+			for _, header := range headers {
+				tracker.total += header.Size
+			}
+		}
+	}
+	tracker.mu.Unlock()
+
+	return &trackedFile{File: file, field: field, tracker: tracker}
+}
+
+func (t *progressTracker) advance(field string, pos int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if pos <= t.furthest[field] {
+		return
+	}
+	t.furthest[field] = pos
+
+	var read int64
+	for _, n := range t.furthest {
+		read += n
+	}
+	if t.total > 0 {
+		// the last percent is for writing out the result
+		t.report(int(read * 99 / t.total))
+	}
+}
+
+type trackedFile struct {
+	multipart.File
+	field   string
+	tracker *progressTracker
+	pos     int64
+}
+
+func (f *trackedFile) Read(p []byte) (int, error) {
+	n, err := f.File.Read(p)
+	f.pos += int64(n)
+	f.tracker.advance(f.field, f.pos)
+	return n, err
+}
+
+func (f *trackedFile) Seek(offset int64, whence int) (int64, error) {
+	pos, err := f.File.Seek(offset, whence)
+	if err == nil {
+		f.pos = pos
+	}
+	return pos, err
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// JoinCSVFiles adds columns of the source file to the rows of the target
+// file by matching part codes. The form takes the source and target files,
+// sourceKey and targetKey columns, the source columns as "Hind=Price,Stock"
+// (renamed with =), joinType left, inner or anti and duplicates first, last,
+// error or all for keys on several source rows.
+func (h *Handler) JoinCSVFiles(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseMultipartForm(uploadMemory)
+	if err != nil {
+		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error parsing form data.")
+		return
+	}
+
+	joinType, duplicates := r.FormValue("joinType"), r.FormValue("duplicates")
+	if err := services.ValidJoin(joinType, duplicates); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	refs, headers := joinColumns(r.FormValue("columns"))
+	if len(refs) == 0 && joinType != services.JoinAnti {
+		http.Error(w, "No source columns to join", http.StatusBadRequest)
+		return
+	}
+
+	output, err := outputOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	reportMode, rep, err := formReport(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sourceDelimiter, err := table.ParseDelimiter(r.FormValue("sourceDelimiter"))
+	if err != nil {
+		http.Error(w, "Source file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	targetDelimiter, err := table.ParseDelimiter(r.FormValue("targetDelimiter"))
+	if err != nil {
+		http.Error(w, "Target file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	source, err := h.formUpload(r, "source", sourceDelimiter)
+	if err != nil {
+		http.Error(w, "Error retrieving the source file", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error retrieving the source file")
+		return
+	}
+	defer source.Close()
+
+	target, err := h.formUpload(r, "target", targetDelimiter)
+	if err != nil {
+		http.Error(w, "Error retrieving the target file", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error retrieving the target file")
+		return
+	}
+	defer target.Close()
+
+	// columns are 1-based positions or header names
+	sourceColumns, err := h.columns.Indexes(source.readHeader, append([]string{r.FormValue("sourceKey")}, refs...)...)
+	if err != nil {
+		http.Error(w, "Source file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Invalid source file columns")
+		return
+	}
+
+	targetColumns, err := h.columns.Indexes(target.readHeader, r.FormValue("targetKey"))
+	if err != nil {
+		http.Error(w, "Target file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Invalid target file columns")
+		return
+	}
+
+	opts := services.JoinOptions{
+		SourceKey:  sourceColumns[0],
+		TargetKey:  targetColumns[0],
+		Columns:    sourceColumns[1:],
+		Headers:    headers,
+		Type:       joinType,
+		Duplicates: duplicates,
+	}
+
+	sourceReader, err := source.Reader()
+	if err != nil {
+		http.Error(w, "Source file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error opening the source file")
+		return
+	}
+
+	targetReader, err := target.Reader()
+	if err != nil {
+		http.Error(w, "Target file: "+err.Error(), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error opening the target file")
+		return
+	}
+
+	// the output keeps the delimiter of the target, given or sniffed
+	output.Delimiter = target.info.Delimiter
+
+	// duplicate keys are found before a row is written, so the error policy
+	// answers with a 400 unless part of the result was sent
+	setDetectedHeaders(w, source, target)
+	err = writeResult(w, "joined", output, reportMode, rep, func(writer table.Writer) error {
+		return h.service.Join(context.Background(), sourceReader, targetReader, opts, writer, source.report(rep), target.report(rep), rep)
+	})
+
+	var duplicateErr *services.DuplicateKeyError
+	switch {
+	case errors.As(err, &duplicateErr) && !resultStarted(err):
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "Source file: "+err.Error(), http.StatusBadRequest)
+	case err != nil:
+		h.resultError(w, err)
+	}
+}
+
+// joinColumns splits "Hind=Price,Stock" into column references and their
+// output names, empty when not renamed.
+func joinColumns(value string) (refs, headers []string) {
+	for _, column := range strings.Split(value, ",") {
+		ref, name, _ := strings.Cut(column, "=")
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		refs = append(refs, ref)
+		headers = append(headers, strings.TrimSpace(name))
+	}
+	return refs, headers
+}
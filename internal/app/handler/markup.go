@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/markup"
+	"github.com/trunov/virena/internal/app/postgres"
+)
+
+func (h *Handler) ListMarkupPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.dbStorage.ListMarkupPolicies(context.Background())
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("List markup policies. Something went wrong with database.")
+		return
+	}
+
+	if policies == nil {
+		policies = []markup.Policy{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(policies); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) GetMarkupPolicy(w http.ResponseWriter, r *http.Request) {
+	policyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid policy id", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.dbStorage.GetMarkupPolicy(context.Background(), policyID)
+	if err != nil {
+		h.policyError(w, err, "Get markup policy")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) CreateMarkupPolicy(w http.ResponseWriter, r *http.Request) {
+	var p markup.Policy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := p.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	policyID, err := h.dbStorage.CreateMarkupPolicy(context.Background(), p)
+	if err != nil {
+		h.policyError(w, err, "Create markup policy")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]int{"id": policyID}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) UpdateMarkupPolicy(w http.ResponseWriter, r *http.Request) {
+	policyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid policy id", http.StatusBadRequest)
+		return
+	}
+
+	var p markup.Policy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	p.ID = policyID
+
+	if err := p.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.dbStorage.UpdateMarkupPolicy(context.Background(), p); err != nil {
+		h.policyError(w, err, "Update markup policy")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) DeleteMarkupPolicy(w http.ResponseWriter, r *http.Request) {
+	policyID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid policy id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.dbStorage.DeleteMarkupPolicy(context.Background(), policyID); err != nil {
+		h.policyError(w, err, "Delete markup policy")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) policyError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, postgres.ErrPolicyNotFound):
+		http.Error(w, "Markup policy not found", http.StatusNotFound)
+	case errors.Is(err, postgres.ErrPolicyExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg(action + ". Something went wrong with database.")
+	}
+}
+
+// formMarkup reads the markup of a price tool run: a saved policy named in
+// markupPolicy, a policy given as JSON in markup, or the flat percentage.
+// A percentage given next to a policy replaces its base percentage. Errors
+// are written to w.
+func (h *Handler) formMarkup(w http.ResponseWriter, r *http.Request) (markup.Policy, bool) {
+	var p markup.Policy
+	name, rules, percentage := r.FormValue("markupPolicy"), r.FormValue("markup"), r.FormValue("percentage")
+
+	switch {
+	case name != "" && rules != "":
+		http.Error(w, "Send either markupPolicy or markup, not both", http.StatusBadRequest)
+		return p, false
+	case name != "":
+		var err error
+		p, err = h.dbStorage.GetMarkupPolicyByName(context.Background(), name)
+		if err != nil {
+			if errors.Is(err, postgres.ErrPolicyNotFound) {
+				http.Error(w, "Markup policy "+strconv.Quote(name)+" not found", http.StatusBadRequest)
+				return p, false
+			}
+			h.policyError(w, err, "Load markup policy")
+			return p, false
+		}
+	case rules != "":
+		var err error
+		p, err = markup.Parse(rules)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return p, false
+		}
+	case percentage == "":
+		http.Error(w, "Percentage or markup is required", http.StatusBadRequest)
+		return p, false
+	}
+
+	if percentage != "" {
+		value, err := strconv.ParseFloat(percentage, 64)
+		if err != nil {
+			http.Error(w, "Invalid percentage value", http.StatusBadRequest)
+			h.logger.Error().Err(err).Msg("Invalid percentage value")
+			return p, false
+		}
+		p.Percentage = value
+	}
+
+	return p, true
+}
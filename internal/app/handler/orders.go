@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/delivery"
+	"github.com/trunov/virena/internal/app/email"
+	"github.com/trunov/virena/internal/app/postgres"
+)
+
+type adminOrder struct {
+	ID          int            `json:"id"`
+	CreatedDate time.Time      `json:"createdDate"`
+	Order       postgres.Order `json:"order"`
+	Email       delivery.State `json:"email"`
+}
+
+func (h *Handler) GetOrder(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order id", http.StatusBadRequest)
+		return
+	}
+
+	order, createdDate, err := h.dbStorage.GetOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrOrderNotFound) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Get order. Something went wrong with database.")
+		return
+	}
+
+	state, err := h.dbStorage.GetOrderEmailDelivery(ctx, orderID)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Get order email delivery. Something went wrong with database.")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(adminOrder{ID: orderID, CreatedDate: createdDate, Order: order, Email: state})
+}
+
+func (h *Handler) PreviewOrderEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid order id", http.StatusBadRequest)
+		return
+	}
+
+	lang := r.URL.Query().Get("lang")
+	if lang != "" && !email.SupportedLanguage(lang) {
+		http.Error(w, "Unsupported language", http.StatusBadRequest)
+		return
+	}
+
+	order, createdDate, err := h.dbStorage.GetOrder(ctx, orderID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrOrderNotFound) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Preview order email. Something went wrong with database.")
+		return
+	}
+
+	message, err := email.BuildOrderMessage(h.emails, orderID, order, createdDate, lang)
+	if err != nil {
+		http.Error(w, "Could not render the email", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Preview order email. Rendering failed.")
+		return
+	}
+
+	w.Header().Set("X-Email-Subject", message.Subject)
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(message.Text))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(message.HTML))
+}
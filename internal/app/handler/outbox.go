@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/outbox"
+)
+
+func (h *Handler) ListOutboxMessages(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = outbox.StatusDead
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	messages, err := h.dbStorage.ListOutboxMessages(ctx, status, limit)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("List outbox. Something went wrong with database.")
+		return
+	}
+
+	// payloads can carry attachments, the listing is about delivery state
+	for i := range messages {
+		messages[i].Payload = nil
+	}
+	if messages == nil {
+		messages = []outbox.Message{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(messages); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) RetryOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid outbox message id", http.StatusBadRequest)
+		return
+	}
+
+	found, err := h.dbStorage.RetryOutboxMessage(ctx, id)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Retry outbox message. Something went wrong with database.")
+		return
+	}
+
+	if !found {
+		http.Error(w, "No dead outbox message with this id", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
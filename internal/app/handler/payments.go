@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/trunov/virena/internal/app/bank"
+)
+
+type importStatementResponse struct {
+	Parsed    int            `json:"parsed"`
+	Saved     int            `json:"saved"`
+	Matched   []bank.Payment `json:"matched"`
+	Unmatched []bank.Payment `json:"unmatched"`
+}
+
+func (h *Handler) ImportBankStatement(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		http.Error(w, "Error parsing form data", http.StatusInternalServerError)
+		h.logger.Error().Err(err).Msg("Error parsing form data. File size is larger than 32MB.")
+		return
+	}
+
+	statement, _, err := r.FormFile("statement")
+	if err != nil {
+		http.Error(w, "Error retrieving the statement file", http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Error retrieving the statement file")
+		return
+	}
+	defer statement.Close()
+
+	payments, err := bank.Parse(statement, r.FormValue("format"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Could not parse the statement: %v", err), http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("Could not parse the bank statement")
+		return
+	}
+
+	refs := make([]string, 0, len(payments))
+	for _, p := range payments {
+		refs = append(refs, p.EntryRef)
+	}
+
+	alreadyMatched, err := h.dbStorage.GetMatchedPaymentRefs(ctx, refs)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Import statement. Failed to load already matched payments.")
+		return
+	}
+
+	fresh := payments[:0]
+	for _, p := range payments {
+		if _, ok := alreadyMatched[p.EntryRef]; !ok {
+			fresh = append(fresh, p)
+		}
+	}
+
+	openOrders, err := h.dbStorage.GetOpenOrders(ctx)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Import statement. Failed to load open orders.")
+		return
+	}
+
+	matched := bank.Match(fresh, openOrders, h.accounting.Settings.Currency)
+
+	saved, err := h.dbStorage.SavePayments(ctx, matched)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Import statement. Failed to save payments.")
+		return
+	}
+
+	resp := importStatementResponse{
+		Parsed:    len(payments),
+		Saved:     saved,
+		Matched:   []bank.Payment{},
+		Unmatched: []bank.Payment{},
+	}
+	for _, p := range matched {
+		if p.OrderID != 0 {
+			resp.Matched = append(resp.Matched, p)
+		} else {
+			resp.Unmatched = append(resp.Unmatched, p)
+		}
+	}
+
+	h.logger.Info().Msgf("Imported bank statement: %d payments, %d matched", len(payments), len(resp.Matched))
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) GetUnmatchedPayments(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	payments, err := h.dbStorage.GetUnmatchedPayments(ctx)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Get unmatched payments. Something went wrong with database.")
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Disposition", "attachment; filename=unmatched_payments.csv")
+		w.Header().Set("Content-Type", "text/csv")
+
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write([]string{"Booking Date", "Amount", "Currency", "Payer", "Reference", "Description", "Entry Reference", "Partial Payment Of Order"})
+		for _, p := range payments {
+			var order string
+			if p.Partial {
+				order = strconv.Itoa(p.OrderID)
+			}
+			csvWriter.Write([]string{p.BookingDate.Format("2006-01-02"), fmt.Sprintf("%.2f", p.Amount), p.Currency, p.PayerName, p.Reference, p.Description, p.EntryRef, order})
+		}
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			h.logger.Error().Err(err).Msg("Error writing unmatched payments")
+		}
+		return
+	}
+
+	if payments == nil {
+		payments = []bank.Payment{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(payments); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
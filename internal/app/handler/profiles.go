@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/postgres"
+	"github.com/trunov/virena/internal/app/profile"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+func (h *Handler) ListDealerProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := h.dbStorage.ListDealerProfiles(context.Background())
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("List dealer profiles. Something went wrong with database.")
+		return
+	}
+
+	if profiles == nil {
+		profiles = []profile.Profile{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(profiles); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) GetDealerProfile(w http.ResponseWriter, r *http.Request) {
+	profileID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid profile id", http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.dbStorage.GetDealerProfile(context.Background(), profileID)
+	if err != nil {
+		h.profileError(w, err, "Get dealer profile")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) CreateDealerProfile(w http.ResponseWriter, r *http.Request) {
+	var p profile.Profile
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := p.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profileID, err := h.dbStorage.CreateDealerProfile(context.Background(), p)
+	if err != nil {
+		h.profileError(w, err, "Create dealer profile")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(map[string]int{"id": profileID}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) UpdateDealerProfile(w http.ResponseWriter, r *http.Request) {
+	profileID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid profile id", http.StatusBadRequest)
+		return
+	}
+
+	var p profile.Profile
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	p.ID = profileID
+
+	if err := p.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.dbStorage.UpdateDealerProfile(context.Background(), p); err != nil {
+		h.profileError(w, err, "Update dealer profile")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) DeleteDealerProfile(w http.ResponseWriter, r *http.Request) {
+	profileID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid profile id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.dbStorage.DeleteDealerProfile(context.Background(), profileID); err != nil {
+		h.profileError(w, err, "Delete dealer profile")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) profileError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, postgres.ErrProfileNotFound):
+		http.Error(w, "Dealer profile not found", http.StatusNotFound)
+	case errors.Is(err, postgres.ErrProfileExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg(action + ". Something went wrong with database.")
+	}
+}
+
+// formProfile loads the dealer profile named in a form field, nil when the
+// field is empty. Profiles in another currency are refused. Errors are
+// written to w.
+func (h *Handler) formProfile(w http.ResponseWriter, r *http.Request, field string) (*profile.Profile, bool) {
+	name := r.FormValue(field)
+	if name == "" {
+		return nil, true
+	}
+
+	p, err := h.dbStorage.GetDealerProfileByName(context.Background(), name)
+	if err != nil {
+		if errors.Is(err, postgres.ErrProfileNotFound) {
+			http.Error(w, "Dealer profile "+strconv.Quote(name)+" not found", http.StatusBadRequest)
+			return nil, false
+		}
+		h.profileError(w, err, "Load dealer profile")
+		return nil, false
+	}
+	if err := p.CheckCurrency(h.accounting.Settings.Currency); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	return &p, true
+}
+
+// useProfile reads the upload the way the profile says, in place of the
+// form values.
+func (u *upload) useProfile(p *profile.Profile) {
+	if p == nil {
+		return
+	}
+
+	u.profile = p
+	u.opts.Encoding = p.Encoding
+	u.opts.Sheet = p.Sheet
+	u.opts.SkipRows = p.HeaderRows
+	u.opts.FixedHeader = true
+}
+
+// priceReader is Reader with the prices cleaned by the profile rules.
+func (u *upload) priceReader(priceIndex int) (table.Reader, error) {
+	reader, err := u.Reader()
+	if err != nil || u.profile == nil {
+		return reader, err
+	}
+	return u.profile.Cleaning.Reader(reader, priceIndex), nil
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+const (
+	reportJSON = "json"
+	reportZip  = "zip"
+)
+
+// formReport reads the report form value of the CSV tools: "json" returns
+// the validation report instead of the result, "zip" both in one archive.
+// Without it there is no report and a nil one is returned, it notes nothing.
+func formReport(r *http.Request) (string, *report.Report, error) {
+	switch mode := r.FormValue("report"); mode {
+	case "":
+		return "", nil, nil
+	case reportJSON, reportZip:
+		return mode, report.New(), nil
+	default:
+		return "", nil, fmt.Errorf("unknown report %q, use json or zip", mode)
+	}
+}
+
+// report starts the report of the upload, raw rows are joined with its
+// delimiter.
+func (u *upload) report(rep *report.Report) *report.File {
+	var delimiter rune
+	if u.info != nil {
+		delimiter = u.info.Delimiter
+	}
+	return rep.File(u.field, delimiter)
+}
+
+// writeResult writes the rows of a tool by the report mode: the result file
+// alone, the report JSON or a zip of both. write gets the writer of the
+// result; for the JSON report its output is discarded, the rows still have
+// to be written for the report to count them. A panic of write is returned
+// as an error too, it is answered like any other failed result.
+func writeResult(w http.ResponseWriter, name string, output table.WriterOptions, mode string, rep *report.Report, write func(table.Writer) error) (err error) {
+	rw := &resultWriter{ResponseWriter: w}
+	defer func() {
+		if r := recover(); r != nil {
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			err = fmt.Errorf("panic: %v", r)
+		}
+		if err != nil && rw.written {
+			err = &resultStartedError{err: err}
+		}
+	}()
+	return writeResultTo(rw, name, output, mode, rep, write)
+}
+
+func writeResultTo(w http.ResponseWriter, name string, output table.WriterOptions, mode string, rep *report.Report, write func(table.Writer) error) error {
+	switch mode {
+	case reportJSON:
+		writer, err := table.NewWriter(io.Discard, output)
+		if err != nil {
+			return err
+		}
+		if err := write(writer); err != nil {
+			return err
+		}
+
+		rep.Finish()
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(rep)
+	case reportZip:
+		w.Header().Set("Content-Disposition", "attachment; filename="+name+".zip")
+		w.Header().Set("Content-Type", "application/zip")
+
+		archive := zip.NewWriter(w)
+		result, err := archive.Create(name + table.Extension(output.Format))
+		if err != nil {
+			return err
+		}
+		writer, err := table.NewWriter(result, output)
+		if err != nil {
+			return err
+		}
+		if err := write(writer); err != nil {
+			return err
+		}
+
+		rep.Finish()
+		file, err := archive.Create("report.json")
+		if err != nil {
+			return err
+		}
+		if err := json.NewEncoder(file).Encode(rep); err != nil {
+			return err
+		}
+		return archive.Close()
+	default:
+		setOutputHeaders(w, name, output.Format)
+		writer, err := table.NewWriter(w, output)
+		if err != nil {
+			return err
+		}
+		return write(writer)
+	}
+}
+
+// resultWriter notes whether anything of the result was sent.
+type resultWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *resultWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *resultWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// resultStartedError is an error of writeResult after the status and part
+// of the result were sent.
+type resultStartedError struct {
+	err error
+}
+
+func (e *resultStartedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *resultStartedError) Unwrap() error {
+	return e.err
+}
+
+func resultStarted(err error) bool {
+	var started *resultStartedError
+	return errors.As(err, &started)
+}
+
+// resultFailer is a response that can be marked failed after it was
+// started, the result file of a job.
+type resultFailer interface {
+	failResult(err error)
+}
+
+// resultError answers a tool whose writeResult failed: with a 500 when
+// nothing was sent yet. Otherwise the status can not change any more, a job
+// is failed and the connection of a client is broken off, so the partial
+// result is not taken for a complete one.
+func (h *Handler) resultError(w http.ResponseWriter, err error) {
+	h.logger.Error().Err(err).Msg("Error writing to output file")
+	if !resultStarted(err) {
+		w.Header().Del("Content-Disposition")
+		http.Error(w, "Error writing to output file", http.StatusInternalServerError)
+		return
+	}
+	if f, ok := w.(resultFailer); ok {
+		f.failResult(err)
+		return
+	}
+	panic(http.ErrAbortHandler)
+}
+
+// blankRow reports whether a row has no values, such as the empty rows
+// between tables of a workbook.
+func blankRow(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trunov/virena/internal/app/table"
+)
+
+func TestWriteResultPanic(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        int
+		wantStarted bool
+	}{
+		{"before any row", 0, false},
+		{"after rows were sent", 3, true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		err := writeResult(w, "result", table.WriterOptions{Delimiter: ';'}, "", nil, func(writer table.Writer) error {
+			for i := 0; i < tt.rows; i++ {
+				if err := writer.Write([]string{"A1", "10"}); err != nil {
+					return err
+				}
+			}
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+			panic("index out of range")
+		})
+		if err == nil {
+			t.Errorf("%s: writeResult() = nil, want the panic as an error", tt.name)
+			continue
+		}
+		if got := resultStarted(err); got != tt.wantStarted {
+			t.Errorf("%s: resultStarted(%v) = %v, want %v", tt.name, err, got, tt.wantStarted)
+		}
+	}
+}
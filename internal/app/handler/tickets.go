@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trunov/virena/internal/app/attachment"
+	"github.com/trunov/virena/internal/app/postgres"
+	"github.com/trunov/virena/internal/app/ticket"
+)
+
+func (h *Handler) storeAttachment(file attachment.Checked) (ticket.Attachment, error) {
+	f, err := file.Header.Open()
+	if err != nil {
+		return ticket.Attachment{}, err
+	}
+	defer f.Close()
+
+	key, size, err := h.files.Save("tickets", f)
+	if err != nil {
+		return ticket.Attachment{}, err
+	}
+
+	return ticket.Attachment{
+		Filename:    file.Header.Filename,
+		ContentType: file.ContentType,
+		Size:        size,
+		StorageKey:  key,
+	}, nil
+}
+
+// scanAttachments runs every file through the scanner, so an infected file
+// is neither stored nor mailed.
+func (h *Handler) scanAttachments(ctx context.Context, files []attachment.Checked) error {
+	if h.contact.Scanner == nil {
+		return nil
+	}
+
+	for _, file := range files {
+		f, err := file.Header.Open()
+		if err != nil {
+			return err
+		}
+
+		err = h.contact.Scanner.Scan(ctx, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Header.Filename, err)
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) removeAttachments(attachments []ticket.Attachment) {
+	for _, a := range attachments {
+		if err := h.files.Remove(a.StorageKey); err != nil {
+			h.logger.Error().Err(err).Str("key", a.StorageKey).Msg("Failed to remove stored attachment")
+		}
+	}
+}
+
+func (h *Handler) ListTickets(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	status := r.URL.Query().Get("status")
+	if status != "" && !ticket.ValidStatus(status) {
+		http.Error(w, "Invalid status value", http.StatusBadRequest)
+		return
+	}
+
+	limit := 100
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tickets, err := h.dbStorage.ListTickets(ctx, status, limit)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("List tickets. Something went wrong with database.")
+		return
+	}
+
+	if tickets == nil {
+		tickets = []ticket.Ticket{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tickets); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (h *Handler) loadTicket(w http.ResponseWriter, r *http.Request) (ticket.Ticket, bool) {
+	ticketID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ticket id", http.StatusBadRequest)
+		return ticket.Ticket{}, false
+	}
+
+	t, err := h.dbStorage.GetTicket(context.Background(), ticketID)
+	if err != nil {
+		if errors.Is(err, postgres.ErrTicketNotFound) {
+			http.Error(w, "Ticket not found", http.StatusNotFound)
+			return ticket.Ticket{}, false
+		}
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Get ticket. Something went wrong with database.")
+		return ticket.Ticket{}, false
+	}
+
+	return t, true
+}
+
+func (h *Handler) GetTicket(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.loadTicket(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+type updateTicketRequest struct {
+	Status *string `json:"status"`
+	Notes  *string `json:"notes"`
+}
+
+func (h *Handler) UpdateTicket(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	ticketID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid ticket id", http.StatusBadRequest)
+		return
+	}
+
+	var req updateTicketRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Status != nil && !ticket.ValidStatus(*req.Status) {
+		http.Error(w, "Invalid status value", http.StatusBadRequest)
+		return
+	}
+
+	err = h.dbStorage.UpdateTicket(ctx, ticketID, req.Status, req.Notes)
+	if err != nil {
+		if errors.Is(err, postgres.ErrTicketNotFound) {
+			http.Error(w, "Ticket not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("Update ticket. Something went wrong with database.")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) GetTicketAttachment(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.loadTicket(w, r)
+	if !ok {
+		return
+	}
+
+	attachmentID, err := strconv.Atoi(chi.URLParam(r, "attachmentID"))
+	if err != nil {
+		http.Error(w, "Invalid attachment id", http.StatusBadRequest)
+		return
+	}
+
+	for _, a := range t.Attachments {
+		if a.ID != attachmentID {
+			continue
+		}
+
+		file, err := h.files.Open(a.StorageKey)
+		if err != nil {
+			http.Error(w, "Attachment is not available", http.StatusInternalServerError)
+			h.logger.Err(err).Str("key", a.StorageKey).Msg("Failed to open stored attachment")
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", a.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if _, err := io.Copy(w, file); err != nil {
+			h.logger.Error().Err(err).Msg("Error writing response")
+		}
+		return
+	}
+
+	http.Error(w, "Attachment not found", http.StatusNotFound)
+}
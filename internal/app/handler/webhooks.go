@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/sendgrid/sendgrid-go/helpers/eventwebhook"
+	"github.com/trunov/virena/internal/app/delivery"
+)
+
+// SendGridEvents receives the signed event webhook. Unknown event types are
+// acknowledged and dropped, otherwise SendGrid would keep retrying them.
+func (h *Handler) SendGridEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := context.Background()
+
+	if h.webhooks == nil {
+		http.Error(w, "Webhook is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 5<<20))
+	if err != nil {
+		http.Error(w, "Error reading request body", http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("SendGrid webhook. Failed to read body.")
+		return
+	}
+
+	signature := r.Header.Get(eventwebhook.VerificationHTTPHeader)
+	timestamp := r.Header.Get(eventwebhook.TimestampHTTPHeader)
+	if err := h.webhooks.Verify(body, signature, timestamp); err != nil {
+		http.Error(w, "Invalid signature", http.StatusForbidden)
+		h.logger.Warn().Err(err).Str("ip", h.clientIP(r)).Msg("SendGrid webhook. Signature verification failed.")
+		return
+	}
+
+	events, err := delivery.ParseSendGrid(body)
+	if err != nil {
+		http.Error(w, "Invalid event payload", http.StatusBadRequest)
+		h.logger.Error().Err(err).Msg("SendGrid webhook. Failed to parse events.")
+		return
+	}
+
+	saved, err := h.dbStorage.SaveEmailEvents(ctx, events)
+	if err != nil {
+		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		h.logger.Err(err).Msg("SendGrid webhook. Something went wrong with database.")
+		return
+	}
+
+	for _, e := range events {
+		if e.Event == delivery.EventDelivered {
+			continue
+		}
+		event := h.logger.Warn().Str("event", e.Event).Str("email", e.Email).Str("reason", e.Reason)
+		if e.OrderID != nil {
+			event = event.Int("order_id", *e.OrderID)
+		}
+		event.Msg("Email was not delivered")
+	}
+
+	h.logger.Info().Int("received", len(events)).Int("saved", saved).Msg("SendGrid webhook events stored")
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,253 @@
+package job
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	StatusQueued  = "queued"
+	StatusRunning = "running"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Job is a CSV tool run in the background. The uploaded request is kept in
+// storage under InputKey and the tool output under ResultKey.
+type Job struct {
+	ID                string     `json:"id"`
+	Tool              string     `json:"tool"`
+	Status            string     `json:"status"`
+	Progress          int        `json:"progress"`
+	Attempts          int        `json:"attempts"`
+	Error             string     `json:"error,omitempty"`
+	Detected          []string   `json:"detected,omitempty"`
+	ContentType       string     `json:"-"`
+	InputKey          string     `json:"-"`
+	ResultKey         string     `json:"-"`
+	ResultContentType string     `json:"-"`
+	ResultFilename    string     `json:"-"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	StartedAt         *time.Time `json:"startedAt,omitempty"`
+	FinishedAt        *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Result is the stored output of a finished job.
+type Result struct {
+	Key         string
+	ContentType string
+	Filename    string
+	// Detected are the X-Detected-CSV values of the run.
+	Detected []string
+}
+
+// NewID returns a random job ID, hard to guess as results are not behind
+// the admin token.
+func NewID() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+type Store interface {
+	// ClaimJob marks the oldest queued job, or a running one whose lease ran
+	// out, as running for the lease duration. Nil when there is nothing to do.
+	ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (*Job, error)
+	// FailStaleJobs fails jobs that were abandoned maxAttempts times.
+	FailStaleJobs(ctx context.Context, maxAttempts int) ([]Job, error)
+	UpdateJobProgress(ctx context.Context, id string, progress int, lease time.Duration) error
+	FinishJob(ctx context.Context, id string, result Result) error
+	FailJob(ctx context.Context, id string, message string) error
+	DeleteExpiredJobs(ctx context.Context, before time.Time) ([]Job, error)
+}
+
+// Files is where job inputs and results are kept.
+type Files interface {
+	Remove(key string) error
+}
+
+// RunFunc runs a job. progress takes a percentage and may be called often.
+type RunFunc func(ctx context.Context, j Job, progress func(int)) (Result, error)
+
+type Options struct {
+	Workers      int
+	PollInterval time.Duration
+	MaxAttempts  int
+	Retention    time.Duration
+}
+
+// lease is how long a job stays with a worker without a heartbeat, a job of
+// a pod that went away is picked up again after it.
+const (
+	lease     = 2 * time.Minute
+	heartbeat = 5 * time.Second
+)
+
+type Worker struct {
+	store  Store
+	files  Files
+	run    RunFunc
+	logger zerolog.Logger
+	opts   Options
+}
+
+func NewWorker(store Store, files Files, run RunFunc, logger zerolog.Logger, opts Options) *Worker {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 2 * time.Second
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = 7 * 24 * time.Hour
+	}
+
+	return &Worker{store: store, files: files, run: run, logger: logger, opts: opts}
+}
+
+// Run starts the worker pool and the cleanup of expired jobs and blocks
+// until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.poll(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		w.cleanup(ctx)
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) poll(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// keep going while there is work, wait for the ticker otherwise
+		for w.next(ctx) {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) next(ctx context.Context) bool {
+	stale, err := w.store.FailStaleJobs(ctx, w.opts.MaxAttempts)
+	if err != nil {
+		w.logger.Error().Err(err).Msg("Jobs. Failed to fail stale jobs.")
+	}
+	for _, j := range stale {
+		w.logger.Error().Str("job_id", j.ID).Msg("Jobs. Job was abandoned too many times.")
+		w.remove(j.InputKey)
+	}
+
+	j, err := w.store.ClaimJob(ctx, lease, w.opts.MaxAttempts)
+	if err != nil {
+		w.logger.Error().Err(err).Msg("Jobs. Failed to claim a job.")
+		return false
+	}
+	if j == nil {
+		return false
+	}
+
+	w.process(ctx, *j)
+	return ctx.Err() == nil
+}
+
+func (w *Worker) process(ctx context.Context, j Job) {
+	var progress atomic.Int64
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			// the update doubles as the heartbeat that keeps the lease
+			if err := w.store.UpdateJobProgress(ctx, j.ID, int(progress.Load()), lease); err != nil {
+				w.logger.Error().Err(err).Str("job_id", j.ID).Msg("Jobs. Failed to update progress.")
+			}
+		}
+	}()
+
+	started := time.Now()
+	result, err := w.run(ctx, j, func(p int) {
+		progress.Store(int64(p))
+	})
+	close(done)
+
+	if err != nil {
+		w.logger.Warn().Err(err).Str("job_id", j.ID).Str("tool", j.Tool).Msg("Jobs. Job failed.")
+		if err := w.store.FailJob(ctx, j.ID, err.Error()); err != nil {
+			w.logger.Error().Err(err).Str("job_id", j.ID).Msg("Jobs. Failed to record job failure.")
+		}
+	} else {
+		w.logger.Info().Str("job_id", j.ID).Str("tool", j.Tool).Dur("took", time.Since(started)).Msg("Jobs. Job done.")
+		if err := w.store.FinishJob(ctx, j.ID, result); err != nil {
+			w.logger.Error().Err(err).Str("job_id", j.ID).Msg("Jobs. Failed to record job result.")
+			w.remove(result.Key)
+			return
+		}
+	}
+
+	w.remove(j.InputKey)
+}
+
+func (w *Worker) cleanup(ctx context.Context) {
+	expired, err := w.store.DeleteExpiredJobs(ctx, time.Now().Add(-w.opts.Retention))
+	if err != nil {
+		w.logger.Error().Err(err).Msg("Jobs. Failed to delete expired jobs.")
+		return
+	}
+
+	for _, j := range expired {
+		w.remove(j.InputKey)
+		w.remove(j.ResultKey)
+	}
+}
+
+func (w *Worker) remove(key string) {
+	if key == "" {
+		return
+	}
+	if err := w.files.Remove(key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		w.logger.Error().Err(err).Str("key", key).Msg("Jobs. Failed to remove stored file.")
+	}
+}
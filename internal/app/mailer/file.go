@@ -0,0 +1,33 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+type fileMailer struct {
+	dir     string
+	counter atomic.Uint64
+}
+
+// NewFile writes every message as an .eml file into dir instead of sending it.
+func NewFile(dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileMailer{dir: dir}, nil
+}
+
+func (f *fileMailer) Send(ctx context.Context, m Message) error {
+	body, err := buildMIME(m)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().Format("20060102-150405.000"), f.counter.Add(1))
+	return os.WriteFile(filepath.Join(f.dir, name), body, 0o644)
+}
@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+
+	"github.com/trunov/virena/internal/app/config"
+)
+
+const (
+	BackendSendGrid = "sendgrid"
+	BackendSMTP     = "smtp"
+	BackendFile     = "file"
+)
+
+type Address struct {
+	Name  string
+	Email string
+}
+
+func (a Address) String() string {
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Content     []byte
+}
+
+// Message is a provider independent email. When TemplateID is set, providers
+// that host templates render TemplateData with it; the others fall back to
+// Text and HTML. Tags are echoed back by providers that report delivery
+// events, so the events can be linked to what was sent.
+type Message struct {
+	From         Address
+	To           []Address
+	CC           []Address
+	ReplyTo      *Address
+	Subject      string
+	Text         string
+	HTML         string
+	TemplateID   string
+	TemplateData map[string]interface{}
+	Attachments  []Attachment
+	Tags         map[string]string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New builds the mailer selected by the MAILER setting.
+func New(cfg config.Config) (Mailer, error) {
+	switch cfg.Mailer {
+	case BackendSendGrid, "":
+		return NewSendGrid(cfg.SendgridAPIKey), nil
+	case BackendSMTP:
+		return NewSMTP(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case BackendFile:
+		return NewFile(cfg.MailDir)
+	default:
+		return nil, fmt.Errorf("unknown mailer backend %q", cfg.Mailer)
+	}
+}
+
+// textFallback is used by backends without hosted templates when a message
+// only carries template data.
+func textFallback(m Message) string {
+	if m.Text != "" || m.TemplateID == "" {
+		return m.Text
+	}
+
+	data, err := json.MarshalIndent(m.TemplateData, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("Template %s", m.TemplateID)
+	}
+	return fmt.Sprintf("Template %s\n\n%s\n", m.TemplateID, data)
+}
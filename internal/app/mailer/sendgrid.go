@@ -0,0 +1,86 @@
+package mailer
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+type sendGridMailer struct {
+	client *sendgrid.Client
+}
+
+func NewSendGrid(apiKey string) Mailer {
+	return &sendGridMailer{client: sendgrid.NewSendClient(apiKey)}
+}
+
+func sendGridEmail(a Address) *mail.Email {
+	return mail.NewEmail(a.Name, a.Email)
+}
+
+func (s *sendGridMailer) Send(ctx context.Context, m Message) error {
+	message := mail.NewV3Mail()
+	message.SetFrom(sendGridEmail(m.From))
+	message.Subject = m.Subject
+
+	if m.ReplyTo != nil {
+		message.SetReplyTo(sendGridEmail(*m.ReplyTo))
+	}
+
+	personalization := mail.NewPersonalization()
+	for _, to := range m.To {
+		personalization.AddTos(sendGridEmail(to))
+	}
+	for _, cc := range m.CC {
+		personalization.AddCCs(sendGridEmail(cc))
+	}
+
+	if m.TemplateID != "" {
+		for key, value := range m.TemplateData {
+			personalization.SetDynamicTemplateData(key, value)
+		}
+		message.SetTemplateID(m.TemplateID)
+	} else {
+		if m.Text != "" {
+			message.AddContent(mail.NewContent("text/plain", m.Text))
+		}
+		if m.HTML != "" {
+			message.AddContent(mail.NewContent("text/html", m.HTML))
+		}
+	}
+
+	message.AddPersonalizations(personalization)
+
+	for key, value := range m.Tags {
+		message.SetCustomArg(key, value)
+	}
+
+	for _, file := range m.Attachments {
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = http.DetectContentType(file.Content)
+		}
+
+		attachment := mail.NewAttachment()
+		attachment.SetContent(base64.StdEncoding.EncodeToString(file.Content))
+		attachment.SetType(contentType)
+		attachment.SetFilename(file.Filename)
+		attachment.SetDisposition("attachment")
+		message.AddAttachment(attachment)
+	}
+
+	response, err := s.client.SendWithContext(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("received non-successful response from SendGrid: %d", response.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+type smtpMailer struct {
+	addr     string
+	username string
+	password string
+}
+
+// NewSMTP sends through a plain SMTP server, e.g. a local MailHog. Credentials
+// are optional; when given, PLAIN auth is used.
+func NewSMTP(addr, username, password string) Mailer {
+	return &smtpMailer{addr: addr, username: username, password: password}
+}
+
+// Send does what smtp.SendMail does on a connection bound to ctx: it is
+// dialed with ctx and closed when ctx is done, its deadline is the one of
+// ctx, so a server that stops answering does not hold the caller.
+func (s *smtpMailer) Send(ctx context.Context, m Message) (err error) {
+	body, err := buildMIME(m)
+	if err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(s.addr)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer func() {
+		// the connection was closed under the client
+		if err != nil && ctx.Err() != nil {
+			err = fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+	}()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(m.From.Email); err != nil {
+		return err
+	}
+	for _, a := range append(append([]Address{}, m.To...), m.CC...) {
+		if err := c.Rcpt(a.Email); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
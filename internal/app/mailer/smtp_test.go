@@ -0,0 +1,141 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSMTPSendStopsWithContext(t *testing.T) {
+	// a server that takes connections and never greets
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		var conns []net.Conn
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				for _, c := range conns {
+					c.Close()
+				}
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	m := Message{
+		From:    Address{Email: "shop@example.com"},
+		To:      []Address{{Email: "customer@example.com"}},
+		Subject: "Order",
+		Text:    "Thank you",
+	}
+
+	tests := []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+	}{
+		{"deadline", func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 100*time.Millisecond)
+		}},
+		{"cancel", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			return ctx, cancel
+		}},
+	}
+	for _, tt := range tests {
+		ctx, cancel := tt.ctx()
+		start := time.Now()
+		err := NewSMTP(l.Addr().String(), "", "").Send(ctx, m)
+		cancel()
+
+		if err == nil {
+			t.Errorf("%s: Send() = nil, want an error", tt.name)
+		}
+		if tt.name == "cancel" && !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: Send() = %v, want context.Canceled", tt.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: Send() took %v", tt.name, elapsed)
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// a server that accepts every command and records the envelope
+	envelope := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var got []string
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 test ready")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				text.PrintfLine("250 test")
+			case strings.HasPrefix(line, "MAIL"), strings.HasPrefix(line, "RCPT"):
+				got = append(got, line)
+				text.PrintfLine("250 OK")
+			case line == "DATA":
+				text.PrintfLine("354 go on")
+				if _, err := text.ReadDotLines(); err != nil {
+					return
+				}
+				text.PrintfLine("250 queued")
+			case line == "QUIT":
+				text.PrintfLine("221 bye")
+				envelope <- got
+				return
+			default:
+				text.PrintfLine("502 unknown")
+			}
+		}
+	}()
+
+	m := Message{
+		From:    Address{Email: "shop@example.com"},
+		To:      []Address{{Email: "customer@example.com"}},
+		CC:      []Address{{Email: "sales@example.com"}},
+		Subject: "Order",
+		Text:    "Thank you",
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := NewSMTP(l.Addr().String(), "", "").Send(ctx, m); err != nil {
+		t.Fatalf("Send() = %v", err)
+	}
+
+	want := []string{"MAIL FROM:<shop@example.com>", "RCPT TO:<customer@example.com>", "RCPT TO:<sales@example.com>"}
+	got := <-envelope
+	if len(got) != len(want) {
+		t.Fatalf("envelope = %q, want %q", got, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("envelope[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
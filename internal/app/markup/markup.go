@@ -0,0 +1,201 @@
+// Package markup turns purchase costs into selling prices for the price
+// tool: markup by cost band and brand, a minimum margin and rounding.
+package markup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decides the selling price of a part from its cost. It is given per
+// run of the price tool or saved under a name.
+type Policy struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Percentage is the markup of costs below the first tier.
+	Percentage float64 `json:"percentage"`
+	// Tiers are cost bands, each reaching from its MinCost to the next one.
+	Tiers []Tier `json:"tiers"`
+	// Brands replace Tiers for the parts of a brand, e.g. "MB".
+	Brands map[string][]Tier `json:"brands"`
+	// MinMargin is the least a price is above its cost, in currency.
+	MinMargin float64 `json:"minMargin"`
+	// Rounding is empty for 2 decimals above 10 and 3 below, "cents",
+	// "step:0.05" or "endings:0.49|0.99". Steps and endings round up.
+	Rounding  string    `json:"rounding"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type Tier struct {
+	MinCost    float64 `json:"minCost"`
+	Percentage float64 `json:"percentage"`
+}
+
+var ErrInvalid = errors.New("invalid markup policy")
+
+func invalid(msg string) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, msg)
+}
+
+// Parse reads a policy given per run as JSON.
+func Parse(data string) (Policy, error) {
+	var p Policy
+	if err := json.Unmarshal([]byte(data), &p); err != nil {
+		return Policy{}, invalid(err.Error())
+	}
+	if err := p.check(); err != nil {
+		return Policy{}, err
+	}
+	return p, nil
+}
+
+// Validate checks a policy before it is saved.
+func (p Policy) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return invalid("name is required")
+	}
+	return p.check()
+}
+
+func (p Policy) check() error {
+	if err := checkTiers(p.Tiers); err != nil {
+		return err
+	}
+	for brand, tiers := range p.Brands {
+		if strings.TrimSpace(brand) == "" {
+			return invalid("brand is required")
+		}
+		if len(tiers) == 0 {
+			return invalid("brand " + strconv.Quote(brand) + " has no tiers")
+		}
+		if err := checkTiers(tiers); err != nil {
+			return fmt.Errorf("brand %q: %w", brand, err)
+		}
+	}
+	if p.MinMargin < 0 {
+		return invalid("minimum margin can not be negative")
+	}
+	_, err := parseRounding(p.Rounding)
+	return err
+}
+
+func checkTiers(tiers []Tier) error {
+	seen := make(map[float64]bool, len(tiers))
+	for _, t := range tiers {
+		if t.MinCost < 0 {
+			return invalid("tier cost can not be negative")
+		}
+		if seen[t.MinCost] {
+			return invalid(fmt.Sprintf("two tiers start at %g", t.MinCost))
+		}
+		seen[t.MinCost] = true
+	}
+	return nil
+}
+
+// Markup is the percentage for a cost: the tier of the brand, or of the
+// policy when the brand has none, with the highest MinCost not above it.
+func (p Policy) Markup(cost float64, brand string) float64 {
+	tiers := p.Tiers
+	for name, brandTiers := range p.Brands {
+		if strings.EqualFold(name, strings.TrimSpace(brand)) {
+			tiers = brandTiers
+			break
+		}
+	}
+
+	percentage, from := p.Percentage, math.Inf(-1)
+	for _, t := range tiers {
+		if t.MinCost <= cost && t.MinCost > from {
+			percentage, from = t.Percentage, t.MinCost
+		}
+	}
+	return percentage
+}
+
+// Price is the rounded selling price of a cost. The policy is expected to
+// be checked, an unknown rounding falls back to the default one.
+func (p Policy) Price(cost float64, brand string) string {
+	price := cost * (1 + p.Markup(cost, brand)/100)
+	if price-cost < p.MinMargin {
+		price = cost + p.MinMargin
+	}
+
+	r, _ := parseRounding(p.Rounding)
+	return r.format(price)
+}
+
+type rounding struct {
+	mode     string
+	step     float64
+	decimals int
+	endings  []float64
+}
+
+func parseRounding(value string) (rounding, error) {
+	mode, arg, _ := strings.Cut(strings.TrimSpace(value), ":")
+	switch mode {
+	case "", "cents":
+		return rounding{mode: mode}, nil
+	case "step":
+		step, err := strconv.ParseFloat(arg, 64)
+		if err != nil || step <= 0 {
+			return rounding{}, invalid("rounding step must be a positive number")
+		}
+		// prices keep the decimals of the step, at least 2
+		decimals := 2
+		if _, fraction, ok := strings.Cut(arg, "."); ok && len(fraction) > decimals {
+			decimals = len(fraction)
+		}
+		return rounding{mode: mode, step: step, decimals: decimals}, nil
+	case "endings":
+		var endings []float64
+		for _, e := range strings.Split(arg, "|") {
+			ending, err := strconv.ParseFloat(strings.TrimSpace(e), 64)
+			if err != nil || ending < 0 || ending >= 1 {
+				return rounding{}, invalid("rounding endings must be fractions such as 0.49|0.99")
+			}
+			endings = append(endings, ending)
+		}
+		sort.Float64s(endings)
+		return rounding{mode: mode, endings: endings}, nil
+	default:
+		return rounding{}, invalid("unknown rounding " + strconv.Quote(value))
+	}
+}
+
+// tolerance keeps prices that already are on a step or ending from moving
+// up because of float error.
+const tolerance = 1e-9
+
+func (r rounding) format(price float64) string {
+	switch r.mode {
+	case "cents":
+		return fmt.Sprintf("%.2f", price)
+	case "step":
+		// a zero price would round to -0 and print as "-0.00"
+		steps := math.Max(math.Ceil(price/r.step-tolerance), 0)
+		return strconv.FormatFloat(steps*r.step, 'f', r.decimals, 64)
+	case "endings":
+		whole := math.Floor(price)
+		for _, base := range []float64{whole, whole + 1} {
+			for _, ending := range r.endings {
+				if base+ending >= price-tolerance {
+					return fmt.Sprintf("%.2f", base+ending)
+				}
+			}
+		}
+	}
+
+	if price > 10 {
+		return fmt.Sprintf("%.2f", price)
+	}
+	return fmt.Sprintf("%.3f", price)
+}
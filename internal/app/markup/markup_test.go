@@ -0,0 +1,81 @@
+package markup
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPriceRounding(t *testing.T) {
+	tests := []struct {
+		rounding string
+		cost     float64
+		want     string
+	}{
+		{"", 12.345, "12.35"},
+		{"", 10, "10.000"},
+		{"", 10.001, "10.00"},
+		{"", 9.8765, "9.877"},
+		{"cents", 9.8765, "9.88"},
+
+		{"step:0.05", 10, "10.00"},
+		{"step:0.05", 10.01, "10.05"},
+		{"step:0.05", 10.05, "10.05"},
+		{"step:0.05", 0.1 + 0.2, "0.30"},
+		{"step:1", 10.0000001, "11.00"},
+		{"step:0.005", 1.2341, "1.235"},
+		{"step:5", 0, "0.00"},
+
+		{"endings:0.49|0.99", 10, "10.49"},
+		{"endings:0.49|0.99", 10.49, "10.49"},
+		{"endings:0.49|0.99", 10.5, "10.99"},
+		{"endings:0.49|0.99", 10.99, "10.99"},
+		{"endings:0.49|0.99", 10.995, "11.49"},
+		{"endings:0.99|0.49", 10.3, "10.49"},
+		{"endings:0", 10.01, "11.00"},
+		{"endings:0", 10, "10.00"},
+		{"endings:0.9", 0.95, "1.90"},
+	}
+	for _, tt := range tests {
+		p := Policy{Rounding: tt.rounding}
+		if got := p.Price(tt.cost, ""); got != tt.want {
+			t.Errorf("Policy{Rounding: %q}.Price(%v) = %q, want %q", tt.rounding, tt.cost, got, tt.want)
+		}
+	}
+}
+
+func TestPrice(t *testing.T) {
+	p := Policy{
+		Percentage: 50,
+		Tiers:      []Tier{{MinCost: 100, Percentage: 20}, {MinCost: 10, Percentage: 30}},
+		Brands:     map[string][]Tier{"MB": {{MinCost: 0, Percentage: 10}}},
+		MinMargin:  2,
+		Rounding:   "cents",
+	}
+
+	tests := []struct {
+		cost  float64
+		brand string
+		want  string
+	}{
+		{2, "", "4.00"},
+		{8, "", "12.00"},
+		{10, "", "13.00"},
+		{100, "", "120.00"},
+		{100, " mb ", "110.00"},
+		{10, "MB", "12.00"},
+		{100, "VLV", "120.00"},
+	}
+	for _, tt := range tests {
+		if got := p.Price(tt.cost, tt.brand); got != tt.want {
+			t.Errorf("Price(%v, %q) = %q, want %q", tt.cost, tt.brand, got, tt.want)
+		}
+	}
+}
+
+func TestParseRoundingInvalid(t *testing.T) {
+	for _, rounding := range []string{"step:0", "step:-1", "step:x", "endings:1", "endings:-0.1", "endings:0.49|", "floor"} {
+		if _, err := Parse(`{"rounding": "` + rounding + `"}`); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(rounding %q) error = %v, want ErrInvalid", rounding, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	KindOrderEmail   = "order_email"
+	KindContactEmail = "contact_email"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusDead    = "dead"
+)
+
+// Message is a unit of work that has to be delivered at least once.
+type Message struct {
+	ID            int64           `json:"id"`
+	Kind          string          `json:"kind"`
+	OrderID       *int            `json:"orderId,omitempty"`
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt"`
+	LastError     string          `json:"lastError,omitempty"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	SentAt        *time.Time      `json:"sentAt,omitempty"`
+}
+
+type Store interface {
+	// ClaimOutboxMessages returns due pending messages and hides them from
+	// other workers for the lease duration.
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]Message, error)
+	MarkOutboxSent(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string, dead bool) error
+}
+
+// DeliverFunc delivers a single message. A returned error schedules a retry.
+type DeliverFunc func(ctx context.Context, m Message) error
+
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+}
+
+type Worker struct {
+	store   Store
+	deliver DeliverFunc
+	logger  zerolog.Logger
+	opts    Options
+}
+
+func NewWorker(store Store, deliver DeliverFunc, logger zerolog.Logger, opts Options) *Worker {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 10 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 8
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = time.Minute
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 6 * time.Hour
+	}
+
+	return &Worker{store: store, deliver: deliver, logger: logger, opts: opts}
+}
+
+// Run polls the outbox until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.processBatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) processBatch(ctx context.Context) {
+	// the lease has to outlive a slow delivery, otherwise another pod would
+	// pick the same message up while it is still being sent
+	messages, err := w.store.ClaimOutboxMessages(ctx, w.opts.BatchSize, 5*time.Minute)
+	if err != nil {
+		w.logger.Error().Err(err).Msg("Outbox. Failed to claim messages.")
+		return
+	}
+
+	for _, m := range messages {
+		w.process(ctx, m)
+	}
+}
+
+func (w *Worker) process(ctx context.Context, m Message) {
+	err := w.deliver(ctx, m)
+	if err == nil {
+		if err := w.store.MarkOutboxSent(ctx, m.ID); err != nil {
+			w.logger.Error().Err(err).Int64("outbox_id", m.ID).Msg("Outbox. Failed to mark message as sent.")
+		}
+		return
+	}
+
+	attempts := m.Attempts + 1
+	dead := attempts >= w.opts.MaxAttempts
+	nextAttemptAt := time.Now().Add(Backoff(attempts, w.opts.BaseBackoff, w.opts.MaxBackoff))
+
+	event := w.logger.Warn()
+	if dead {
+		event = w.logger.Error()
+	}
+	event.Err(err).
+		Int64("outbox_id", m.ID).
+		Str("kind", m.Kind).
+		Int("attempts", attempts).
+		Bool("dead", dead).
+		Msg("Outbox. Delivery failed.")
+
+	if err := w.store.MarkOutboxFailed(ctx, m.ID, attempts, nextAttemptAt, err.Error(), dead); err != nil {
+		w.logger.Error().Err(err).Int64("outbox_id", m.ID).Msg("Outbox. Failed to record delivery failure.")
+	}
+}
+
+// Backoff returns the delay before the given attempt: base, 2*base, 4*base
+// and so on, capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	delay := float64(base) * math.Pow(2, float64(attempt-1))
+	if delay > float64(max) {
+		return max
+	}
+	return time.Duration(delay)
+}
+
+// ContactPayload is the payload of a contact email, the submission itself
+// is stored as a ticket.
+type ContactPayload struct {
+	TicketID int `json:"ticketId"`
+}
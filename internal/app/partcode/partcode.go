@@ -0,0 +1,225 @@
+// Package partcode decides when two part codes are the same part. Dealers
+// and brands write codes differently: with or without leading zeros,
+// separators or a brand prefix such as the "A" of Mercedes-Benz codes.
+package partcode
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Rules say how the codes of one brand are written.
+type Rules struct {
+	// Prefixes are dropped from the start of a code, the first one that
+	// matches and leaves something behind.
+	Prefixes []string
+	// Separators are characters that are only formatting, e.g. "-./ ".
+	Separators string
+	// KeepLeadingZeros makes "0123" and "123" different parts.
+	KeepLeadingZeros bool
+}
+
+// DefaultRules are the built-in rules by brand, as brands are written in
+// the catalog.
+var DefaultRules = map[string]Rules{
+	"MB": {Prefixes: []string{"A"}},
+}
+
+// Normalizer applies the rules of a brand, codes of unknown brands and of
+// files without a brand get the default rules.
+type Normalizer struct {
+	def    Rules
+	brands map[string]Rules
+}
+
+// New builds a normalizer from the built-in rules and the given ones, which
+// replace the built-in rules of their brand. The brand "default" sets the
+// rules for all other brands.
+func New(rules map[string]Rules) *Normalizer {
+	n := &Normalizer{brands: make(map[string]Rules)}
+	for brand, r := range DefaultRules {
+		n.brands[brand] = r
+	}
+	for brand, r := range rules {
+		brand = strings.ToUpper(strings.TrimSpace(brand))
+		if brand == "DEFAULT" {
+			n.def = r
+			continue
+		}
+		n.brands[brand] = r
+	}
+	return n
+}
+
+// ParseRules reads rules written as "|" separated options: "prefix=A"
+// (repeatable), "separators=-./" and "keepzeros".
+func ParseRules(value string) (Rules, error) {
+	var r Rules
+	for _, option := range strings.Split(value, "|") {
+		name, arg, _ := strings.Cut(option, "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case "prefix":
+			if arg == "" {
+				return Rules{}, fmt.Errorf("empty prefix")
+			}
+			r.Prefixes = append(r.Prefixes, strings.ToUpper(arg))
+		case "separators":
+			r.Separators = arg
+		case "keepzeros":
+			r.KeepLeadingZeros = true
+		default:
+			return Rules{}, fmt.Errorf("unknown part code option %q", name)
+		}
+	}
+	return r, nil
+}
+
+// ParseBrandRules reads the rules of every brand from PART_CODE_RULES.
+func ParseBrandRules(values map[string]string) (map[string]Rules, error) {
+	rules := make(map[string]Rules, len(values))
+	for brand, value := range values {
+		r, err := ParseRules(value)
+		if err != nil {
+			return nil, fmt.Errorf("part code rules of %s: %w", brand, err)
+		}
+		rules[brand] = r
+	}
+	return rules, nil
+}
+
+func (n *Normalizer) rules(brand string) Rules {
+	if r, ok := n.brands[strings.ToUpper(strings.TrimSpace(brand))]; ok {
+		return r
+	}
+	return n.def
+}
+
+// Brand is the brand as its rules are kept, "" for brands that get the
+// default rules.
+func (n *Normalizer) Brand(brand string) string {
+	brand = strings.ToUpper(strings.TrimSpace(brand))
+	if _, ok := n.brands[brand]; ok {
+		return brand
+	}
+	return ""
+}
+
+// Clean is a code as it should be shown and stored: without surrounding
+// spaces, non-breaking spaces included, and stray quotes.
+func Clean(code string) string {
+	return strings.TrimFunc(code, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '\''
+	})
+}
+
+// Key is the canonical form of a code: two codes of a brand are the same
+// part when their keys are equal. Codes are compared case-insensitively.
+// An empty code has an empty key, which never matches anything.
+func (n *Normalizer) Key(brand, code string) string {
+	return n.rules(brand).key(code)
+}
+
+func (r Rules) key(code string) string {
+	key := r.trimZeros(r.clean(strings.ToUpper(code)))
+
+	for _, prefix := range r.Prefixes {
+		if len(key) > len(prefix) && strings.HasPrefix(key, prefix) {
+			key = r.trimZeros(r.clean(key[len(prefix):]))
+			break
+		}
+	}
+
+	return key
+}
+
+// clean drops the separators and what Clean drops, until neither leaves
+// anything to drop: "- 123" is "123" when "-" is a separator.
+func (r Rules) clean(code string) string {
+	for {
+		key := Clean(code)
+		if r.Separators != "" {
+			key = strings.Map(func(c rune) rune {
+				if strings.ContainsRune(r.Separators, c) {
+					return -1
+				}
+				return c
+			}, key)
+		}
+		if key == code {
+			return key
+		}
+		code = key
+	}
+}
+
+// trimZeros drops leading zeros, a code of zeros only is "0".
+func (r Rules) trimZeros(key string) string {
+	if r.KeepLeadingZeros {
+		return key
+	}
+	for strings.HasPrefix(key, "0") {
+		trimmed := strings.TrimLeft(key, "0")
+		if trimmed == "" {
+			return "0"
+		}
+		key = r.clean(trimmed)
+	}
+	return key
+}
+
+// Candidates are the forms a searched code may be stored under in the
+// catalog, where the brand is not known before the lookup: the code as
+// typed, its cleaned form and the forms left by the rules of every brand,
+// its key by those rules among them.
+func (n *Normalizer) Candidates(code string) []string {
+	code = Clean(code)
+	if code == "" {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	var candidates []string
+	add := func(c string) {
+		if _, ok := seen[c]; ok || c == "" {
+			return
+		}
+		seen[c] = struct{}{}
+		candidates = append(candidates, c)
+	}
+
+	add(code)
+	add(strings.ToUpper(code))
+
+	rules := []Rules{n.def}
+	brands := make([]string, 0, len(n.brands))
+	for brand := range n.brands {
+		brands = append(brands, brand)
+	}
+	sort.Strings(brands)
+	for _, brand := range brands {
+		rules = append(rules, n.brands[brand])
+	}
+
+	for _, r := range rules {
+		add(r.key(code))
+		upper := strings.ToUpper(code)
+		for _, prefix := range r.Prefixes {
+			if len(upper) > len(prefix) && strings.HasPrefix(upper, prefix) {
+				add(code[len(prefix):])
+				break
+			}
+		}
+		if !r.KeepLeadingZeros {
+			// catalogs keep the zeros of some codes and drop them from others
+			add("0" + code)
+			if trimmed := strings.TrimLeft(code, "0"); trimmed != "" {
+				add(trimmed)
+			}
+		}
+	}
+
+	return candidates
+}
@@ -0,0 +1,159 @@
+package partcode
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+// code is a part code as dealers write them: digits, letters in both
+// cases, separators, spaces and quotes.
+type code string
+
+const codeChars = "0000123456789AaBbMmXx-./ '\""
+
+func (code) Generate(rand *rand.Rand, size int) reflect.Value {
+	b := make([]byte, rand.Intn(size+1))
+	for i := range b {
+		b[i] = codeChars[rand.Intn(len(codeChars))]
+	}
+	return reflect.ValueOf(code(b))
+}
+
+// rules are Rules with some of the usual separators, the "A" prefix in
+// half of them.
+type rules struct {
+	Rules
+}
+
+func (rules) Generate(rand *rand.Rand, size int) reflect.Value {
+	var r Rules
+	for _, c := range "-./ " {
+		if rand.Intn(2) == 0 {
+			r.Separators += string(c)
+		}
+	}
+	if rand.Intn(2) == 0 {
+		r.Prefixes = []string{"A"}
+	}
+	r.KeepLeadingZeros = rand.Intn(4) == 0
+	return reflect.ValueOf(rules{r})
+}
+
+func normalizer(r Rules) *Normalizer {
+	return New(map[string]Rules{"default": r, "VLV": {Separators: "-"}})
+}
+
+func check(t *testing.T, f interface{}) {
+	t.Helper()
+	if err := quick.Check(f, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKeyIdempotent(t *testing.T) {
+	// a prefix is dropped once: "AA1" is "A1" and "A1" is "1"
+	check(t, func(r rules, c code) bool {
+		r.Prefixes = nil
+		n := normalizer(r.Rules)
+		key := n.Key("", string(c))
+		return n.Key("", key) == key
+	})
+}
+
+func TestKeyIgnoresCase(t *testing.T) {
+	check(t, func(r rules, c code, brand bool) bool {
+		n := normalizer(r.Rules)
+		b := ""
+		if brand {
+			b = "mb"
+		}
+		return n.Key(b, strings.ToLower(string(c))) == n.Key(strings.ToUpper(b), strings.ToUpper(string(c)))
+	})
+}
+
+func TestKeyIgnoresSeparators(t *testing.T) {
+	check(t, func(r rules, c code, at uint, which uint) bool {
+		if r.Separators == "" {
+			return true
+		}
+		n := normalizer(r.Rules)
+		s := string(c)
+		i := int(at % uint(len(s)+1))
+		separator := r.Separators[which%uint(len(r.Separators))]
+		return n.Key("", s[:i]+string(separator)+s[i:]) == n.Key("", s)
+	})
+}
+
+func TestKeyIgnoresLeadingZeros(t *testing.T) {
+	check(t, func(r rules, c code, zeros uint8) bool {
+		r.KeepLeadingZeros = false
+		n := normalizer(r.Rules)
+		key := n.Key("", string(c))
+		if key == "" {
+			return true
+		}
+		return n.Key("", strings.Repeat("0", int(zeros%4)+1)+string(c)) == key
+	})
+}
+
+func TestKeyEmpty(t *testing.T) {
+	n := New(nil)
+	for _, c := range []string{"", " ", "\u00a0", `""`, "' '"} {
+		if key := n.Key("", c); key != "" {
+			t.Errorf("Key(%q) = %q, want empty", c, key)
+		}
+	}
+	check(t, func(r rules, brand bool) bool {
+		b := ""
+		if brand {
+			b = "MB"
+		}
+		return normalizer(r.Rules).Key(b, "") == ""
+	})
+}
+
+func TestKeyAmongCandidates(t *testing.T) {
+	check(t, func(r rules, c code) bool {
+		n := normalizer(r.Rules)
+		candidates := n.Candidates(string(c))
+		for _, brand := range []string{"", "MB", "VLV", "unknown"} {
+			key := n.Key(brand, string(c))
+			if key != "" && !contains(candidates, key) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestKeyExamples(t *testing.T) {
+	n := New(map[string]Rules{"VLV": {Separators: "- "}})
+	tests := []struct {
+		brand, code, want string
+	}{
+		{"", " 0123 ", "123"},
+		{"", "000", "0"},
+		{"", "ab-12", "AB-12"},
+		{"mb", "A0001234", "1234"},
+		{"MB", "A", "A"},
+		{"VLV", "30 - 680 7", "306807"},
+		{"VLV", "- 0123", "123"},
+	}
+	for _, tt := range tests {
+		if got := n.Key(tt.brand, tt.code); got != tt.want {
+			t.Errorf("Key(%q, %q) = %q, want %q", tt.brand, tt.code, got, tt.want)
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
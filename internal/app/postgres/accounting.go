@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/trunov/virena/internal/app/accounting"
+)
+
+// GetOrdersForExport returns the orders created in [from, to) with their
+// items. Orders that were exported before are skipped unless includeExported is set.
+func (s *dbStorage) GetOrdersForExport(ctx context.Context, from, to time.Time, includeExported bool) ([]accounting.Order, error) {
+	query := `SELECT id, createdDate, paidDate, name, email, phoneNumber, COALESCE(company, ''), COALESCE(vatNumber, ''), country, city, zipCode, address
+		FROM orders WHERE createdDate >= $1 AND createdDate < $2 AND ($3 OR exportedDate IS NULL) ORDER BY createdDate, id`
+
+	rows, err := s.dbpool.Query(ctx, query, from, to, includeExported)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []accounting.Order
+	index := make(map[int]int)
+	var ids []int
+
+	for rows.Next() {
+		var o accounting.Order
+		err := rows.Scan(&o.ID, &o.CreatedDate, &o.PaidDate, &o.Name, &o.Email, &o.PhoneNumber, &o.Company, &o.VATNumber, &o.Country, &o.City, &o.ZipCode, &o.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		index[o.ID] = len(orders)
+		ids = append(ids, o.ID)
+		orders = append(orders, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	if len(orders) == 0 {
+		return orders, nil
+	}
+
+	itemRows, err := s.dbpool.Query(ctx, "SELECT orderId, productCode, brand, quantity, price, amount FROM order_items WHERE orderId = ANY($1) ORDER BY id", ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer itemRows.Close()
+
+	for itemRows.Next() {
+		var orderID int
+		var l accounting.Line
+		var price, amount sql.NullFloat64
+		if err := itemRows.Scan(&orderID, &l.PartCode, &l.Brand, &l.Quantity, &price, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		// items of orders placed before prices were stored have none
+		l.Price, l.Amount = price.Float64, amount.Float64
+		l.Unpriced = !price.Valid || !amount.Valid
+
+		i := index[orderID]
+		orders[i].Items = append(orders[i].Items, l)
+	}
+
+	if err = itemRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+func (s *dbStorage) MarkOrdersExported(ctx context.Context, orderIDs []int) error {
+	_, err := s.dbpool.Exec(ctx, "UPDATE orders SET exportedDate = CURRENT_TIMESTAMP WHERE id = ANY($1)", orderIDs)
+	if err != nil {
+		return fmt.Errorf("failed to mark orders as exported: %w", err)
+	}
+	return nil
+}
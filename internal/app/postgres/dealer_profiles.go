@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/profile"
+)
+
+var (
+	ErrProfileNotFound = errors.New("dealer profile not found")
+	ErrProfileExists   = errors.New("dealer profile with this name already exists")
+)
+
+const dealerProfileColumns = `id, name, delimiter, encoding, sheet, price_column, code_column, dealer_column,
+	header_rows, currency, dealer_number, decimal_separator, strip_chars, price_multiplier, created_at, updated_at`
+
+func scanDealerProfile(row pgx.Row) (profile.Profile, error) {
+	var p profile.Profile
+	err := row.Scan(&p.ID, &p.Name, &p.Delimiter, &p.Encoding, &p.Sheet, &p.PriceColumn, &p.CodeColumn, &p.DealerColumn,
+		&p.HeaderRows, &p.Currency, &p.DealerNumber, &p.Cleaning.DecimalSeparator, &p.Cleaning.StripChars, &p.Cleaning.Multiplier,
+		&p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// uniqueViolation tells a duplicate profile name apart from other errors.
+func uniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (s *dbStorage) CreateDealerProfile(ctx context.Context, p profile.Profile) (int, error) {
+	var id int
+	err := s.dbpool.QueryRow(ctx, `INSERT INTO dealer_profiles (name, delimiter, encoding, sheet, price_column, code_column, dealer_column,
+		header_rows, currency, dealer_number, decimal_separator, strip_chars, price_multiplier)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id`,
+		p.Name, p.Delimiter, p.Encoding, p.Sheet, p.PriceColumn, p.CodeColumn, p.DealerColumn,
+		p.HeaderRows, p.Currency, p.DealerNumber, p.Cleaning.DecimalSeparator, p.Cleaning.StripChars, p.Cleaning.Multiplier).Scan(&id)
+	if err != nil {
+		if uniqueViolation(err) {
+			return 0, ErrProfileExists
+		}
+		return 0, fmt.Errorf("failed to insert dealer profile: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *dbStorage) ListDealerProfiles(ctx context.Context) ([]profile.Profile, error) {
+	rows, err := s.dbpool.Query(ctx, "SELECT "+dealerProfileColumns+" FROM dealer_profiles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var profiles []profile.Profile
+	for rows.Next() {
+		p, err := scanDealerProfile(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		profiles = append(profiles, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return profiles, nil
+}
+
+func (s *dbStorage) GetDealerProfile(ctx context.Context, id int) (profile.Profile, error) {
+	p, err := scanDealerProfile(s.dbpool.QueryRow(ctx, "SELECT "+dealerProfileColumns+" FROM dealer_profiles WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return profile.Profile{}, ErrProfileNotFound
+		}
+		return profile.Profile{}, err
+	}
+	return p, nil
+}
+
+func (s *dbStorage) GetDealerProfileByName(ctx context.Context, name string) (profile.Profile, error) {
+	p, err := scanDealerProfile(s.dbpool.QueryRow(ctx, "SELECT "+dealerProfileColumns+" FROM dealer_profiles WHERE name = $1", name))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return profile.Profile{}, ErrProfileNotFound
+		}
+		return profile.Profile{}, err
+	}
+	return p, nil
+}
+
+func (s *dbStorage) UpdateDealerProfile(ctx context.Context, p profile.Profile) error {
+	tag, err := s.dbpool.Exec(ctx, `UPDATE dealer_profiles SET name = $2, delimiter = $3, encoding = $4, sheet = $5,
+		price_column = $6, code_column = $7, dealer_column = $8, header_rows = $9, currency = $10, dealer_number = $11,
+		decimal_separator = $12, strip_chars = $13, price_multiplier = $14, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		p.ID, p.Name, p.Delimiter, p.Encoding, p.Sheet, p.PriceColumn, p.CodeColumn, p.DealerColumn,
+		p.HeaderRows, p.Currency, p.DealerNumber, p.Cleaning.DecimalSeparator, p.Cleaning.StripChars, p.Cleaning.Multiplier)
+	if err != nil {
+		if uniqueViolation(err) {
+			return ErrProfileExists
+		}
+		return fmt.Errorf("failed to update dealer profile: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
+
+func (s *dbStorage) DeleteDealerProfile(ctx context.Context, id int) error {
+	tag, err := s.dbpool.Exec(ctx, "DELETE FROM dealer_profiles WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dealer profile: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrProfileNotFound
+	}
+
+	return nil
+}
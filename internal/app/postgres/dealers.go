@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/dealer"
+)
+
+var (
+	ErrDealerNotFound   = errors.New("dealer not found")
+	ErrDealerExists     = errors.New("dealer with this name already exists")
+	ErrSnapshotNotFound = errors.New("price list version not found")
+)
+
+const dealerColumns = `d.id, d.name, d.number, d.profile_id, d.created_at,
+	COALESCE((SELECT MAX(version) FROM dealer_price_snapshots WHERE dealer_id = d.id), 0)`
+
+func scanDealer(row pgx.Row) (dealer.Dealer, error) {
+	var d dealer.Dealer
+	err := row.Scan(&d.ID, &d.Name, &d.Number, &d.ProfileID, &d.CreatedAt, &d.LatestVersion)
+	return d, err
+}
+
+func (s *dbStorage) CreateDealer(ctx context.Context, d dealer.Dealer) (int, error) {
+	var id int
+	err := s.dbpool.QueryRow(ctx, "INSERT INTO dealers (name, number, profile_id) VALUES ($1, $2, $3) RETURNING id",
+		d.Name, d.Number, d.ProfileID).Scan(&id)
+	if err != nil {
+		if uniqueViolation(err) {
+			return 0, ErrDealerExists
+		}
+		return 0, fmt.Errorf("failed to insert dealer: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *dbStorage) ListDealers(ctx context.Context) ([]dealer.Dealer, error) {
+	rows, err := s.dbpool.Query(ctx, "SELECT "+dealerColumns+" FROM dealers d ORDER BY d.name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var dealers []dealer.Dealer
+	for rows.Next() {
+		d, err := scanDealer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		dealers = append(dealers, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return dealers, nil
+}
+
+func (s *dbStorage) GetDealer(ctx context.Context, id int) (dealer.Dealer, error) {
+	d, err := scanDealer(s.dbpool.QueryRow(ctx, "SELECT "+dealerColumns+" FROM dealers d WHERE d.id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return dealer.Dealer{}, ErrDealerNotFound
+		}
+		return dealer.Dealer{}, err
+	}
+	return d, nil
+}
+
+// SaveDealerPrices stores an uploaded price list as the next version of the
+// dealer's snapshots.
+func (s *dbStorage) SaveDealerPrices(ctx context.Context, dealerID int, filename string, prices []dealer.Price) (dealer.Snapshot, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return dealer.Snapshot{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the lock keeps two uploads of one dealer from taking the same version
+	var exists int
+	err = tx.QueryRow(ctx, "SELECT id FROM dealers WHERE id = $1 FOR UPDATE", dealerID).Scan(&exists)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return dealer.Snapshot{}, ErrDealerNotFound
+		}
+		return dealer.Snapshot{}, err
+	}
+
+	snapshot := dealer.Snapshot{DealerID: dealerID, Filename: filename, Rows: len(prices)}
+	err = tx.QueryRow(ctx, `INSERT INTO dealer_price_snapshots (dealer_id, version, filename, row_count)
+		VALUES ($1, (SELECT COALESCE(MAX(version), 0) + 1 FROM dealer_price_snapshots WHERE dealer_id = $1), $2, $3)
+		RETURNING id, version, created_at`,
+		dealerID, filename, len(prices)).Scan(&snapshot.ID, &snapshot.Version, &snapshot.CreatedAt)
+	if err != nil {
+		return dealer.Snapshot{}, fmt.Errorf("failed to insert price list version: %w", err)
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"dealer_prices"}, []string{"snapshot_id", "position", "code", "price", "dealer"},
+		pgx.CopyFromSlice(len(prices), func(i int) ([]interface{}, error) {
+			return []interface{}{snapshot.ID, i, prices[i].Code, prices[i].Price, prices[i].Dealer}, nil
+		}))
+	if err != nil {
+		return dealer.Snapshot{}, fmt.Errorf("failed to copy dealer prices: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return dealer.Snapshot{}, err
+	}
+
+	return snapshot, nil
+}
+
+func (s *dbStorage) ListDealerSnapshots(ctx context.Context, dealerID int) ([]dealer.Snapshot, error) {
+	rows, err := s.dbpool.Query(ctx, `SELECT id, dealer_id, version, filename, row_count, created_at
+		FROM dealer_price_snapshots WHERE dealer_id = $1 ORDER BY version DESC`, dealerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []dealer.Snapshot
+	for rows.Next() {
+		var sn dealer.Snapshot
+		if err := rows.Scan(&sn.ID, &sn.DealerID, &sn.Version, &sn.Filename, &sn.Rows, &sn.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		snapshots = append(snapshots, sn)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return snapshots, nil
+}
+
+// GetDealerPrices returns a version of the dealer's price list in upload
+// order, the latest one when version is 0.
+func (s *dbStorage) GetDealerPrices(ctx context.Context, dealerID, version int) (dealer.Snapshot, []dealer.Price, error) {
+	var sn dealer.Snapshot
+	err := s.dbpool.QueryRow(ctx, `SELECT id, dealer_id, version, filename, row_count, created_at
+		FROM dealer_price_snapshots WHERE dealer_id = $1 AND ($2 = 0 OR version = $2)
+		ORDER BY version DESC LIMIT 1`, dealerID, version).
+		Scan(&sn.ID, &sn.DealerID, &sn.Version, &sn.Filename, &sn.Rows, &sn.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return dealer.Snapshot{}, nil, ErrSnapshotNotFound
+		}
+		return dealer.Snapshot{}, nil, err
+	}
+
+	rows, err := s.dbpool.Query(ctx, "SELECT code, price, dealer FROM dealer_prices WHERE snapshot_id = $1 ORDER BY position", sn.ID)
+	if err != nil {
+		return dealer.Snapshot{}, nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	prices := make([]dealer.Price, 0, sn.Rows)
+	for rows.Next() {
+		var p dealer.Price
+		if err := rows.Scan(&p.Code, &p.Price, &p.Dealer); err != nil {
+			return dealer.Snapshot{}, nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		prices = append(prices, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return dealer.Snapshot{}, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return sn, prices, nil
+}
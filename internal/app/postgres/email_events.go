@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/trunov/virena/internal/app/delivery"
+	"github.com/trunov/virena/internal/app/outbox"
+)
+
+// SaveEmailEvents stores webhook events and returns how many were new.
+// SendGrid delivers at least once, duplicates are skipped by sg_event_id.
+// Events without an order id inherit it from their outbox message.
+func (s *dbStorage) SaveEmailEvents(ctx context.Context, events []delivery.Event) (int, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO email_events (outbox_id, order_id, event, email, reason, sg_event_id, sg_message_id, occurred_at)
+		VALUES ($1, COALESCE($2, (SELECT order_id FROM outbox WHERE id = $1)), $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (sg_event_id) DO NOTHING`
+
+	saved := 0
+	for _, e := range events {
+		tag, err := tx.Exec(ctx, query, e.OutboxID, e.OrderID, e.Event, e.Email, e.Reason, e.SGEventID, e.SGMessageID, e.OccurredAt)
+		if err != nil {
+			return 0, fmt.Errorf("failed to execute query: %w", err)
+		}
+		saved += int(tag.RowsAffected())
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return saved, nil
+}
+
+// GetOrderEmailDelivery returns the outbox status of the order email and the
+// webhook events reported for it. The state follows the customer's address,
+// the staff copies of the email share the order id.
+func (s *dbStorage) GetOrderEmailDelivery(ctx context.Context, orderID int) (delivery.State, error) {
+	var outboxStatus, recipient sql.NullString
+	err := s.dbpool.QueryRow(ctx, `SELECT (SELECT status FROM outbox WHERE order_id = $1 AND kind = $2 ORDER BY created_at DESC LIMIT 1),
+		(SELECT email FROM orders WHERE id = $1)`,
+		orderID, outbox.KindOrderEmail).Scan(&outboxStatus, &recipient)
+	if err != nil {
+		return delivery.State{}, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	query := `SELECT id, outbox_id, order_id, event, email, reason, sg_event_id, sg_message_id, occurred_at
+		FROM email_events WHERE order_id = $1 ORDER BY occurred_at, id`
+
+	rows, err := s.dbpool.Query(ctx, query, orderID)
+	if err != nil {
+		return delivery.State{}, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var events []delivery.Event
+	for rows.Next() {
+		var e delivery.Event
+		var outboxID sql.NullInt64
+		var eventOrderID sql.NullInt32
+
+		err := rows.Scan(&e.ID, &outboxID, &eventOrderID, &e.Event, &e.Email, &e.Reason, &e.SGEventID, &e.SGMessageID, &e.OccurredAt)
+		if err != nil {
+			return delivery.State{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if outboxID.Valid {
+			e.OutboxID = &outboxID.Int64
+		}
+		if eventOrderID.Valid {
+			id := int(eventOrderID.Int32)
+			e.OrderID = &id
+		}
+
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return delivery.State{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return delivery.NewState(outboxStatus.String, recipient.String, events), nil
+}
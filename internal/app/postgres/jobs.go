@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/job"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+const jobColumns = `id, tool, status, progress, attempts, error, detected, content_type, input_key,
+	result_key, result_content_type, result_filename, created_at, started_at, finished_at`
+
+func scanJob(row pgx.Row) (job.Job, error) {
+	var j job.Job
+	err := row.Scan(&j.ID, &j.Tool, &j.Status, &j.Progress, &j.Attempts, &j.Error, &j.Detected, &j.ContentType, &j.InputKey,
+		&j.ResultKey, &j.ResultContentType, &j.ResultFilename, &j.CreatedAt, &j.StartedAt, &j.FinishedAt)
+	return j, err
+}
+
+func scanJobs(rows pgx.Rows) ([]job.Job, error) {
+	defer rows.Close()
+
+	var jobs []job.Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return jobs, nil
+}
+
+func (s *dbStorage) CreateJob(ctx context.Context, j job.Job) error {
+	_, err := s.dbpool.Exec(ctx, "INSERT INTO jobs (id, tool, status, content_type, input_key) VALUES ($1, $2, $3, $4, $5)",
+		j.ID, j.Tool, job.StatusQueued, j.ContentType, j.InputKey)
+	if err != nil {
+		return fmt.Errorf("failed to insert job: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStorage) GetJob(ctx context.Context, id string) (job.Job, error) {
+	j, err := scanJob(s.dbpool.QueryRow(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return job.Job{}, ErrJobNotFound
+		}
+		return job.Job{}, err
+	}
+	return j, nil
+}
+
+func (s *dbStorage) ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (*job.Job, error) {
+	query := `UPDATE jobs SET status = 'running', attempts = attempts + 1, progress = 0,
+			started_at = CURRENT_TIMESTAMP, lease_until = CURRENT_TIMESTAMP + $1::interval
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE (status = 'queued' OR (status = 'running' AND lease_until < CURRENT_TIMESTAMP))
+				AND attempts < $2
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	j, err := scanJob(s.dbpool.QueryRow(ctx, query, lease, maxAttempts))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to claim job: %w", err)
+	}
+
+	return &j, nil
+}
+
+func (s *dbStorage) FailStaleJobs(ctx context.Context, maxAttempts int) ([]job.Job, error) {
+	query := `UPDATE jobs SET status = 'failed', error = 'the job was interrupted too many times', finished_at = CURRENT_TIMESTAMP
+		WHERE status = 'running' AND lease_until < CURRENT_TIMESTAMP AND attempts >= $1
+		RETURNING ` + jobColumns
+
+	rows, err := s.dbpool.Query(ctx, query, maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return scanJobs(rows)
+}
+
+func (s *dbStorage) UpdateJobProgress(ctx context.Context, id string, progress int, lease time.Duration) error {
+	_, err := s.dbpool.Exec(ctx, "UPDATE jobs SET progress = $2, lease_until = CURRENT_TIMESTAMP + $3::interval WHERE id = $1 AND status = 'running'",
+		id, progress, lease)
+	if err != nil {
+		return fmt.Errorf("failed to update job progress: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStorage) FinishJob(ctx context.Context, id string, result job.Result) error {
+	detected := result.Detected
+	if detected == nil {
+		detected = []string{}
+	}
+
+	_, err := s.dbpool.Exec(ctx, `UPDATE jobs SET status = 'done', progress = 100, result_key = $2, result_content_type = $3,
+		result_filename = $4, detected = $5, lease_until = NULL, finished_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		id, result.Key, result.ContentType, result.Filename, detected)
+	if err != nil {
+		return fmt.Errorf("failed to finish job: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStorage) FailJob(ctx context.Context, id string, message string) error {
+	_, err := s.dbpool.Exec(ctx, "UPDATE jobs SET status = 'failed', error = $2, lease_until = NULL, finished_at = CURRENT_TIMESTAMP WHERE id = $1",
+		id, message)
+	if err != nil {
+		return fmt.Errorf("failed to fail job: %w", err)
+	}
+	return nil
+}
+
+func (s *dbStorage) DeleteExpiredJobs(ctx context.Context, before time.Time) ([]job.Job, error) {
+	rows, err := s.dbpool.Query(ctx, "DELETE FROM jobs WHERE status IN ('done', 'failed') AND finished_at < $1 RETURNING "+jobColumns, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return scanJobs(rows)
+}
@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/markup"
+)
+
+var (
+	ErrPolicyNotFound = errors.New("markup policy not found")
+	ErrPolicyExists   = errors.New("markup policy with this name already exists")
+)
+
+const markupPolicyColumns = "id, name, percentage, tiers, brands, min_margin, rounding, created_at, updated_at"
+
+func scanMarkupPolicy(row pgx.Row) (markup.Policy, error) {
+	var p markup.Policy
+	var tiers, brands []byte
+	err := row.Scan(&p.ID, &p.Name, &p.Percentage, &tiers, &brands, &p.MinMargin, &p.Rounding, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return p, err
+	}
+
+	if err := json.Unmarshal(tiers, &p.Tiers); err != nil {
+		return p, fmt.Errorf("failed to decode tiers: %w", err)
+	}
+	if err := json.Unmarshal(brands, &p.Brands); err != nil {
+		return p, fmt.Errorf("failed to decode brand tiers: %w", err)
+	}
+	return p, nil
+}
+
+// marshalTiers keeps empty tiers as [] and {} like the column defaults.
+func marshalTiers(p markup.Policy) (tiers, brands []byte, err error) {
+	if p.Tiers == nil {
+		p.Tiers = []markup.Tier{}
+	}
+	if p.Brands == nil {
+		p.Brands = map[string][]markup.Tier{}
+	}
+
+	if tiers, err = json.Marshal(p.Tiers); err != nil {
+		return nil, nil, err
+	}
+	if brands, err = json.Marshal(p.Brands); err != nil {
+		return nil, nil, err
+	}
+	return tiers, brands, nil
+}
+
+func (s *dbStorage) CreateMarkupPolicy(ctx context.Context, p markup.Policy) (int, error) {
+	tiers, brands, err := marshalTiers(p)
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = s.dbpool.QueryRow(ctx, `INSERT INTO markup_policies (name, percentage, tiers, brands, min_margin, rounding)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		p.Name, p.Percentage, tiers, brands, p.MinMargin, p.Rounding).Scan(&id)
+	if err != nil {
+		if uniqueViolation(err) {
+			return 0, ErrPolicyExists
+		}
+		return 0, fmt.Errorf("failed to insert markup policy: %w", err)
+	}
+
+	return id, nil
+}
+
+func (s *dbStorage) ListMarkupPolicies(ctx context.Context) ([]markup.Policy, error) {
+	rows, err := s.dbpool.Query(ctx, "SELECT "+markupPolicyColumns+" FROM markup_policies ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []markup.Policy
+	for rows.Next() {
+		p, err := scanMarkupPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		policies = append(policies, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return policies, nil
+}
+
+func (s *dbStorage) GetMarkupPolicy(ctx context.Context, id int) (markup.Policy, error) {
+	p, err := scanMarkupPolicy(s.dbpool.QueryRow(ctx, "SELECT "+markupPolicyColumns+" FROM markup_policies WHERE id = $1", id))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return markup.Policy{}, ErrPolicyNotFound
+		}
+		return markup.Policy{}, err
+	}
+	return p, nil
+}
+
+func (s *dbStorage) GetMarkupPolicyByName(ctx context.Context, name string) (markup.Policy, error) {
+	p, err := scanMarkupPolicy(s.dbpool.QueryRow(ctx, "SELECT "+markupPolicyColumns+" FROM markup_policies WHERE name = $1", name))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return markup.Policy{}, ErrPolicyNotFound
+		}
+		return markup.Policy{}, err
+	}
+	return p, nil
+}
+
+func (s *dbStorage) UpdateMarkupPolicy(ctx context.Context, p markup.Policy) error {
+	tiers, brands, err := marshalTiers(p)
+	if err != nil {
+		return err
+	}
+
+	tag, err := s.dbpool.Exec(ctx, `UPDATE markup_policies SET name = $2, percentage = $3, tiers = $4, brands = $5,
+		min_margin = $6, rounding = $7, updated_at = CURRENT_TIMESTAMP WHERE id = $1`,
+		p.ID, p.Name, p.Percentage, tiers, brands, p.MinMargin, p.Rounding)
+	if err != nil {
+		if uniqueViolation(err) {
+			return ErrPolicyExists
+		}
+		return fmt.Errorf("failed to update markup policy: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrPolicyNotFound
+	}
+
+	return nil
+}
+
+func (s *dbStorage) DeleteMarkupPolicy(ctx context.Context, id int) error {
+	tag, err := s.dbpool.Exec(ctx, "DELETE FROM markup_policies WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete markup policy: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrPolicyNotFound
+	}
+
+	return nil
+}
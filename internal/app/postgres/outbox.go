@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/outbox"
+)
+
+const outboxColumns = "id, kind, order_id, payload, status, attempts, next_attempt_at, COALESCE(last_error, ''), created_at, sent_at"
+
+func scanOutboxMessages(rows pgx.Rows) ([]outbox.Message, error) {
+	defer rows.Close()
+
+	var messages []outbox.Message
+	for rows.Next() {
+		var m outbox.Message
+		var orderID sql.NullInt32
+
+		err := rows.Scan(&m.ID, &m.Kind, &orderID, &m.Payload, &m.Status, &m.Attempts, &m.NextAttemptAt, &m.LastError, &m.CreatedAt, &m.SentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if orderID.Valid {
+			id := int(orderID.Int32)
+			m.OrderID = &id
+		}
+
+		messages = append(messages, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return messages, nil
+}
+
+func (s *dbStorage) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error) {
+	query := `UPDATE outbox SET next_attempt_at = CURRENT_TIMESTAMP + $2::interval
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	rows, err := s.dbpool.Query(ctx, query, limit, lease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return scanOutboxMessages(rows)
+}
+
+func (s *dbStorage) MarkOutboxSent(ctx context.Context, id int64) error {
+	_, err := s.dbpool.Exec(ctx, "UPDATE outbox SET status = 'sent', attempts = attempts + 1, sent_at = CURRENT_TIMESTAMP, last_error = NULL WHERE id = $1", id)
+	return err
+}
+
+func (s *dbStorage) MarkOutboxFailed(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string, dead bool) error {
+	status := outbox.StatusPending
+	if dead {
+		status = outbox.StatusDead
+	}
+
+	_, err := s.dbpool.Exec(ctx, "UPDATE outbox SET status = $2, attempts = $3, next_attempt_at = $4, last_error = $5 WHERE id = $1",
+		id, status, attempts, nextAttemptAt, lastError)
+	return err
+}
+
+func (s *dbStorage) ListOutboxMessages(ctx context.Context, status string, limit int) ([]outbox.Message, error) {
+	query := "SELECT " + outboxColumns + " FROM outbox WHERE status = $1 ORDER BY created_at DESC LIMIT $2"
+
+	rows, err := s.dbpool.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+
+	return scanOutboxMessages(rows)
+}
+
+// RetryOutboxMessage puts a dead message back in the queue with a fresh
+// attempt budget. It reports false when there is no dead message with that id.
+func (s *dbStorage) RetryOutboxMessage(ctx context.Context, id int64) (bool, error) {
+	tag, err := s.dbpool.Exec(ctx, "UPDATE outbox SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'dead'", id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
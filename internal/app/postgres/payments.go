@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/trunov/virena/internal/app/bank"
+)
+
+func (s *dbStorage) GetOpenOrders(ctx context.Context) ([]bank.OpenOrder, error) {
+	query := `SELECT o.id, o.name, COALESCE(o.company, ''), COALESCE(o.total, 0),
+		COALESCE((SELECT SUM(p.amount) FROM payments p WHERE p.order_id = o.id), 0)
+		FROM orders o WHERE o.paidDate IS NULL`
+
+	rows, err := s.dbpool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []bank.OpenOrder
+	for rows.Next() {
+		var o bank.OpenOrder
+		if err := rows.Scan(&o.ID, &o.Name, &o.Company, &o.Total, &o.Paid); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		orders = append(orders, o)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return orders, nil
+}
+
+func (s *dbStorage) GetMatchedPaymentRefs(ctx context.Context, refs []string) (map[string]struct{}, error) {
+	query := "SELECT entry_ref FROM payments WHERE entry_ref = ANY($1) AND order_id IS NOT NULL"
+
+	rows, err := s.dbpool.Query(ctx, query, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	matched := make(map[string]struct{})
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		matched[ref] = struct{}{}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return matched, nil
+}
+
+// SavePayments stores imported payments and marks matched orders as paid
+// once their payments cover the total. Payments that were imported before
+// are only updated when they had no order yet. It returns the number of
+// payments that were stored or updated.
+func (s *dbStorage) SavePayments(ctx context.Context, payments []bank.Payment) (int, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var saved int
+	for _, p := range payments {
+		var orderID sql.NullInt64
+		var matchedBy sql.NullString
+		if p.OrderID != 0 {
+			orderID = sql.NullInt64{Int64: int64(p.OrderID), Valid: true}
+			matchedBy = sql.NullString{String: p.MatchedBy, Valid: true}
+		}
+
+		tag, err := tx.Exec(ctx, `INSERT INTO payments (entry_ref, booking_date, amount, currency, payer_name, reference, description, source, order_id, matched_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (entry_ref) DO UPDATE SET order_id = EXCLUDED.order_id, matched_by = EXCLUDED.matched_by
+			WHERE payments.order_id IS NULL AND EXCLUDED.order_id IS NOT NULL`,
+			p.EntryRef, p.BookingDate, p.Amount, p.Currency, p.PayerName, p.Reference, p.Description, p.Source, orderID, matchedBy)
+		if err != nil {
+			return 0, fmt.Errorf("failed to save payment %s: %w", p.EntryRef, err)
+		}
+
+		if tag.RowsAffected() == 0 {
+			continue
+		}
+		saved++
+
+		if p.OrderID != 0 {
+			paidDate := p.BookingDate
+			if paidDate.IsZero() {
+				paidDate = time.Now()
+			}
+
+			_, err = tx.Exec(ctx, `UPDATE orders SET paidDate = $1 WHERE id = $2 AND paidDate IS NULL AND total > 0
+				AND (SELECT SUM(amount) FROM payments WHERE order_id = $2) >= total - 0.005`, paidDate, p.OrderID)
+			if err != nil {
+				return 0, fmt.Errorf("failed to mark order %d as paid: %w", p.OrderID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return saved, nil
+}
+
+// GetUnmatchedPayments lists the payments a person has to look at: the ones
+// without an order and the partial payments of orders that are not paid.
+func (s *dbStorage) GetUnmatchedPayments(ctx context.Context) ([]bank.Payment, error) {
+	query := `SELECT entry_ref, booking_date, amount, currency, COALESCE(payer_name, ''), COALESCE(reference, ''), COALESCE(description, ''), source,
+		COALESCE(order_id, 0), COALESCE(matched_by, '')
+		FROM payments WHERE order_id IS NULL OR order_id IN (SELECT id FROM orders WHERE paidDate IS NULL)
+		ORDER BY booking_date, id`
+
+	rows, err := s.dbpool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []bank.Payment
+	for rows.Next() {
+		var p bank.Payment
+		if err := rows.Scan(&p.EntryRef, &p.BookingDate, &p.Amount, &p.Currency, &p.PayerName, &p.Reference, &p.Description, &p.Source, &p.OrderID, &p.MatchedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		p.Partial = p.OrderID != 0
+		payments = append(payments, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return payments, nil
+}
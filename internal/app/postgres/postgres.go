@@ -3,11 +3,20 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/trunov/virena/internal/app/accounting"
+	"github.com/trunov/virena/internal/app/bank"
+	"github.com/trunov/virena/internal/app/dealer"
+	"github.com/trunov/virena/internal/app/delivery"
+	"github.com/trunov/virena/internal/app/job"
+	"github.com/trunov/virena/internal/app/markup"
+	"github.com/trunov/virena/internal/app/outbox"
+	"github.com/trunov/virena/internal/app/profile"
+	"github.com/trunov/virena/internal/app/ticket"
 	"github.com/trunov/virena/internal/app/util"
 	"golang.org/x/sync/errgroup"
 
@@ -25,6 +34,7 @@ type PersonalInformation struct {
 	City        string `json:"city"`
 	ZipCode     string `json:"zipCode"`
 	Address     string `json:"address"`
+	Language    string `json:"language"`
 }
 
 type Product struct {
@@ -41,14 +51,75 @@ type Order struct {
 	Cart                []Product           `json:"cart"`
 }
 
+// Subtotal is the sum of the cart line amounts without VAT.
+func (o Order) Subtotal() float64 {
+	var summ float64
+	for _, product := range o.Cart {
+		summ += product.Amount
+	}
+	return summ
+}
+
+// Total is the amount the customer has to pay, VAT included.
+func (o Order) Total() float64 {
+	return o.Subtotal() * (1 + util.VATRate)
+}
+
 type DBStorager interface {
 	Ping(ctx context.Context) error
-	GetProductResults(ctx context.Context, productID string) ([]util.GetProductResponse, error)
+	GetProductResults(ctx context.Context, codes []string) ([]util.GetProductResponse, error)
+	SaveProducts(ctx context.Context, products []util.GetProductResponse) error
 	SaveOrder(ctx context.Context, order Order, orderID int) (time.Time, error)
 	GetAllBrandsPercentage(ctx context.Context) (util.BrandPercentageMap, error)
 	CheckOrderIDExists(ctx context.Context, orderID int) (bool, error)
+	GetOpenOrders(ctx context.Context) ([]bank.OpenOrder, error)
+	GetMatchedPaymentRefs(ctx context.Context, refs []string) (map[string]struct{}, error)
+	SavePayments(ctx context.Context, payments []bank.Payment) (int, error)
+	GetUnmatchedPayments(ctx context.Context) ([]bank.Payment, error)
+	GetOrdersForExport(ctx context.Context, from, to time.Time, includeExported bool) ([]accounting.Order, error)
+	MarkOrdersExported(ctx context.Context, orderIDs []int) error
+	GetOrder(ctx context.Context, orderID int) (Order, time.Time, error)
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]outbox.Message, error)
+	MarkOutboxSent(ctx context.Context, id int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, attempts int, nextAttemptAt time.Time, lastError string, dead bool) error
+	ListOutboxMessages(ctx context.Context, status string, limit int) ([]outbox.Message, error)
+	RetryOutboxMessage(ctx context.Context, id int64) (bool, error)
+	CreateTicket(ctx context.Context, t ticket.Ticket) (int, error)
+	ListTickets(ctx context.Context, status string, limit int) ([]ticket.Ticket, error)
+	GetTicket(ctx context.Context, ticketID int) (ticket.Ticket, error)
+	UpdateTicket(ctx context.Context, ticketID int, status, notes *string) error
+	SaveEmailEvents(ctx context.Context, events []delivery.Event) (int, error)
+	GetOrderEmailDelivery(ctx context.Context, orderID int) (delivery.State, error)
+	CreateDealerProfile(ctx context.Context, p profile.Profile) (int, error)
+	ListDealerProfiles(ctx context.Context) ([]profile.Profile, error)
+	GetDealerProfile(ctx context.Context, id int) (profile.Profile, error)
+	GetDealerProfileByName(ctx context.Context, name string) (profile.Profile, error)
+	UpdateDealerProfile(ctx context.Context, p profile.Profile) error
+	DeleteDealerProfile(ctx context.Context, id int) error
+	CreateJob(ctx context.Context, j job.Job) error
+	GetJob(ctx context.Context, id string) (job.Job, error)
+	ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (*job.Job, error)
+	FailStaleJobs(ctx context.Context, maxAttempts int) ([]job.Job, error)
+	UpdateJobProgress(ctx context.Context, id string, progress int, lease time.Duration) error
+	FinishJob(ctx context.Context, id string, result job.Result) error
+	FailJob(ctx context.Context, id string, message string) error
+	DeleteExpiredJobs(ctx context.Context, before time.Time) ([]job.Job, error)
+	CreateDealer(ctx context.Context, d dealer.Dealer) (int, error)
+	ListDealers(ctx context.Context) ([]dealer.Dealer, error)
+	GetDealer(ctx context.Context, id int) (dealer.Dealer, error)
+	SaveDealerPrices(ctx context.Context, dealerID int, filename string, prices []dealer.Price) (dealer.Snapshot, error)
+	ListDealerSnapshots(ctx context.Context, dealerID int) ([]dealer.Snapshot, error)
+	GetDealerPrices(ctx context.Context, dealerID, version int) (dealer.Snapshot, []dealer.Price, error)
+	CreateMarkupPolicy(ctx context.Context, p markup.Policy) (int, error)
+	ListMarkupPolicies(ctx context.Context) ([]markup.Policy, error)
+	GetMarkupPolicy(ctx context.Context, id int) (markup.Policy, error)
+	GetMarkupPolicyByName(ctx context.Context, name string) (markup.Policy, error)
+	UpdateMarkupPolicy(ctx context.Context, p markup.Policy) error
+	DeleteMarkupPolicy(ctx context.Context, id int) error
 }
 
+var ErrOrderNotFound = errors.New("order not found")
+
 type dbStorage struct {
 	dbpool *pgxpool.Pool
 }
@@ -96,8 +167,15 @@ func (s *dbStorage) GetAllBrandsPercentage(ctx context.Context) (util.BrandPerce
 	return brandPercentageMap, nil
 }
 
-func (s *dbStorage) GetProductResults(ctx context.Context, productID string) ([]util.GetProductResponse, error) {
+// GetProductResults looks a code up in every catalog table. codes are the
+// forms the code may be stored under, most likely first; a table gives its
+// best match only.
+func (s *dbStorage) GetProductResults(ctx context.Context, codes []string) ([]util.GetProductResponse, error) {
 	var products []util.GetProductResponse
+	if len(codes) == 0 {
+		return products, nil
+	}
+
 	var mutex sync.Mutex // Mutex to prevent race condition when appending to the slice
 
 	tables := []string{"products", "jaguar_products", "ford_products", "volvo_products", "toyota_products", "nissan_products", "mazda_products"}
@@ -108,20 +186,15 @@ func (s *dbStorage) GetProductResults(ctx context.Context, productID string) ([]
 		tableName := tableName
 
 		g.Go(func() error {
-			query := fmt.Sprintf("SELECT code, price, description, note, weight, brand FROM %s WHERE code = $1", tableName)
-
-			// if search was done with first letter 'A' we are removing it
-			searchID := productID
-			if strings.ToLower(string(productID[0])) == "a" {
-				searchID = productID[1:]
-			}
+			query := fmt.Sprintf(`SELECT code, price, description, note, weight, brand FROM %s
+				WHERE code = ANY($1::text[]) ORDER BY array_position($1::text[], code::text) LIMIT 1`, tableName)
 
 			var product util.GetProductResponse
 			var description sql.NullString
 			var note sql.NullString
 			var weight sql.NullFloat64
 
-			err := s.dbpool.QueryRow(ctx, query, searchID).Scan(
+			err := s.dbpool.QueryRow(ctx, query, codes).Scan(
 				&product.Code,
 				&product.Price,
 				&description,
@@ -173,22 +246,30 @@ func (s *dbStorage) SaveOrder(ctx context.Context, order Order, orderID int) (ti
 
 	// Insert the order
 	var createdDate time.Time
-	err = tx.QueryRow(ctx, "INSERT INTO orders (id, name, email, phoneNumber, company, vatNumber, country, city, zipCode, address) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING createdDate",
-		orderID, order.PersonalInformation.Name, order.PersonalInformation.Email, order.PersonalInformation.PhoneNumber, order.PersonalInformation.Company, order.PersonalInformation.VATNumber, order.PersonalInformation.Country, order.PersonalInformation.City, order.PersonalInformation.ZipCode, order.PersonalInformation.Address).Scan(&createdDate)
+	err = tx.QueryRow(ctx, "INSERT INTO orders (id, name, email, phoneNumber, company, vatNumber, country, city, zipCode, address, total, language) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING createdDate",
+		orderID, order.PersonalInformation.Name, order.PersonalInformation.Email, order.PersonalInformation.PhoneNumber, order.PersonalInformation.Company, order.PersonalInformation.VATNumber, order.PersonalInformation.Country, order.PersonalInformation.City, order.PersonalInformation.ZipCode, order.PersonalInformation.Address, order.Total(), order.PersonalInformation.Language).Scan(&createdDate)
 	if err != nil {
 		tx.Rollback(ctx)
 		return time.Time{}, err
 	}
 
 	for _, product := range order.Cart {
-		_, err = tx.Exec(ctx, "INSERT INTO order_items (orderId, productCode, brand, quantity) VALUES ($1, $2, $3, $4)",
-			orderID, product.PartCode, product.Brand, product.Quantity)
+		_, err = tx.Exec(ctx, "INSERT INTO order_items (orderId, productCode, brand, quantity, price, amount, description) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+			orderID, product.PartCode, product.Brand, product.Quantity, product.Price, product.Amount, product.Description)
 		if err != nil {
 			tx.Rollback(ctx)
 			return time.Time{}, err
 		}
 	}
 
+	// the invoice email is queued in the same transaction, so an order is
+	// never stored without its email and vice versa
+	_, err = tx.Exec(ctx, "INSERT INTO outbox (kind, order_id) VALUES ($1, $2)", outbox.KindOrderEmail, orderID)
+	if err != nil {
+		tx.Rollback(ctx)
+		return time.Time{}, err
+	}
+
 	// Commit the transaction
 	err = tx.Commit(ctx)
 	if err != nil {
@@ -199,6 +280,63 @@ func (s *dbStorage) SaveOrder(ctx context.Context, order Order, orderID int) (ti
 
 }
 
+func (s *dbStorage) GetOrder(ctx context.Context, orderID int) (Order, time.Time, error) {
+	var order Order
+	var createdDate time.Time
+	var company, vatNumber, language sql.NullString
+
+	err := s.dbpool.QueryRow(ctx, "SELECT name, email, phoneNumber, company, vatNumber, country, city, zipCode, address, language, createdDate FROM orders WHERE id = $1", orderID).Scan(
+		&order.PersonalInformation.Name,
+		&order.PersonalInformation.Email,
+		&order.PersonalInformation.PhoneNumber,
+		&company,
+		&vatNumber,
+		&order.PersonalInformation.Country,
+		&order.PersonalInformation.City,
+		&order.PersonalInformation.ZipCode,
+		&order.PersonalInformation.Address,
+		&language,
+		&createdDate,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return Order{}, time.Time{}, ErrOrderNotFound
+		}
+		return Order{}, time.Time{}, err
+	}
+
+	order.PersonalInformation.Company = company.String
+	order.PersonalInformation.VATNumber = vatNumber.String
+	order.PersonalInformation.Language = language.String
+
+	rows, err := s.dbpool.Query(ctx, "SELECT productCode, brand, quantity, COALESCE(price, 0), COALESCE(amount, 0), description FROM order_items WHERE orderId = $1 ORDER BY id", orderID)
+	if err != nil {
+		return Order{}, time.Time{}, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product Product
+		var description sql.NullString
+
+		if err := rows.Scan(&product.PartCode, &product.Brand, &product.Quantity, &product.Price, &product.Amount, &description); err != nil {
+			return Order{}, time.Time{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		if description.Valid {
+			product.Description = &description.String
+		}
+
+		order.Cart = append(order.Cart, product)
+	}
+
+	if err = rows.Err(); err != nil {
+		return Order{}, time.Time{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return order, createdDate, nil
+}
+
 func (s *dbStorage) CheckOrderIDExists(ctx context.Context, orderID int) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM orders WHERE id=$1)`
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/util"
+)
+
+// SaveProducts inserts the products into the catalog, or updates them when
+// their code is stored already. Values a product does not have, an empty
+// brand included, keep what is stored.
+func (s *dbStorage) SaveProducts(ctx context.Context, products []util.GetProductResponse) error {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, p := range products {
+		batch.Queue(`INSERT INTO products (code, price, description, note, weight, brand)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (code) DO UPDATE SET
+				price = EXCLUDED.price,
+				description = COALESCE(EXCLUDED.description, products.description),
+				note = COALESCE(EXCLUDED.note, products.note),
+				weight = COALESCE(EXCLUDED.weight, products.weight),
+				brand = COALESCE(NULLIF(EXCLUDED.brand, ''), products.brand)`,
+			p.Code, p.Price, p.Description, p.Note, p.Weight, p.Brand)
+	}
+
+	results := tx.SendBatch(ctx, batch)
+	for range products {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			return fmt.Errorf("failed to execute query: %w", err)
+		}
+	}
+	if err := results.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
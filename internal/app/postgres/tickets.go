@@ -0,0 +1,129 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/trunov/virena/internal/app/outbox"
+	"github.com/trunov/virena/internal/app/ticket"
+)
+
+var ErrTicketNotFound = errors.New("ticket not found")
+
+// CreateTicket stores a contact submission with its attachments and queues
+// the staff notification in the same transaction.
+func (s *dbStorage) CreateTicket(ctx context.Context, t ticket.Ticket) (int, error) {
+	tx, err := s.dbpool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var ticketID int
+	err = tx.QueryRow(ctx, "INSERT INTO tickets (name, email, subject, message, status) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		t.Name, t.Email, t.Subject, t.Message, ticket.StatusOpen).Scan(&ticketID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert ticket: %w", err)
+	}
+
+	for _, a := range t.Attachments {
+		_, err = tx.Exec(ctx, "INSERT INTO ticket_attachments (ticket_id, filename, content_type, size, storage_key) VALUES ($1, $2, $3, $4, $5)",
+			ticketID, a.Filename, a.ContentType, a.Size, a.StorageKey)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert ticket attachment: %w", err)
+		}
+	}
+
+	payload, err := json.Marshal(outbox.ContactPayload{TicketID: ticketID})
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, "INSERT INTO outbox (kind, payload) VALUES ($1, $2)", outbox.KindContactEmail, payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue contact email: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	return ticketID, nil
+}
+
+func (s *dbStorage) ListTickets(ctx context.Context, status string, limit int) ([]ticket.Ticket, error) {
+	query := `SELECT id, name, email, subject, message, status, notes, created_at, updated_at
+		FROM tickets WHERE ($1 = '' OR status = $1) ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := s.dbpool.Query(ctx, query, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var tickets []ticket.Ticket
+	for rows.Next() {
+		var t ticket.Ticket
+		if err := rows.Scan(&t.ID, &t.Name, &t.Email, &t.Subject, &t.Message, &t.Status, &t.Notes, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		tickets = append(tickets, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return tickets, nil
+}
+
+func (s *dbStorage) GetTicket(ctx context.Context, ticketID int) (ticket.Ticket, error) {
+	var t ticket.Ticket
+	err := s.dbpool.QueryRow(ctx, "SELECT id, name, email, subject, message, status, notes, created_at, updated_at FROM tickets WHERE id = $1", ticketID).
+		Scan(&t.ID, &t.Name, &t.Email, &t.Subject, &t.Message, &t.Status, &t.Notes, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ticket.Ticket{}, ErrTicketNotFound
+		}
+		return ticket.Ticket{}, err
+	}
+
+	rows, err := s.dbpool.Query(ctx, "SELECT id, filename, content_type, size, storage_key FROM ticket_attachments WHERE ticket_id = $1 ORDER BY id", ticketID)
+	if err != nil {
+		return ticket.Ticket{}, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a ticket.Attachment
+		if err := rows.Scan(&a.ID, &a.Filename, &a.ContentType, &a.Size, &a.StorageKey); err != nil {
+			return ticket.Ticket{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		t.Attachments = append(t.Attachments, a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ticket.Ticket{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return t, nil
+}
+
+// UpdateTicket changes the status and/or the staff notes of a ticket. Nil
+// values are left as they are.
+func (s *dbStorage) UpdateTicket(ctx context.Context, ticketID int, status, notes *string) error {
+	tag, err := s.dbpool.Exec(ctx, "UPDATE tickets SET status = COALESCE($2, status), notes = COALESCE($3, notes), updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		ticketID, status, notes)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrTicketNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// Profile is how the price list of one dealer is read, saved so the CSV
+// tools can be run with a profile name instead of the raw form fields.
+type Profile struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Delimiter is a single character or "tab", empty means sniffed.
+	Delimiter string `json:"delimiter"`
+	Encoding  string `json:"encoding"`
+	Sheet     string `json:"sheet"`
+	// Columns are 1-based positions or header names.
+	PriceColumn  string `json:"priceColumn"`
+	CodeColumn   string `json:"codeColumn"`
+	DealerColumn string `json:"dealerColumn"`
+	// HeaderRows are title rows above the header that are skipped.
+	// Currency is what the list is priced in, empty for the shop currency.
+	HeaderRows   int           `json:"headerRows"`
+	Currency     string        `json:"currency"`
+	DealerNumber string        `json:"dealerNumber"`
+	Cleaning     PriceCleaning `json:"cleaning"`
+	CreatedAt    time.Time     `json:"createdAt"`
+	UpdatedAt    time.Time     `json:"updatedAt"`
+}
+
+// PriceCleaning turns the prices of a dealer into plain numbers before they
+// are parsed.
+type PriceCleaning struct {
+	// DecimalSeparator is "," or ".", the other one is taken as a thousands
+	// separator. Empty leaves the guessing to the price parser.
+	DecimalSeparator string `json:"decimalSeparator"`
+	// StripChars are removed from prices, currency signs for example.
+	StripChars string `json:"stripChars"`
+	// Multiplier is applied to every price, e.g. to remove VAT; 0 means 1.
+	Multiplier float64 `json:"multiplier"`
+}
+
+var ErrInvalid = errors.New("invalid profile")
+
+func invalid(msg string) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, msg)
+}
+
+// Validate checks a profile before it is saved.
+func (p Profile) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return invalid("name is required")
+	}
+	if p.Delimiter != "" && p.Delimiter != "tab" && len([]rune(p.Delimiter)) != 1 {
+		return invalid("delimiter must be a single character or \"tab\"")
+	}
+	if !table.ValidEncoding(p.Encoding) {
+		return invalid("unsupported encoding " + strconv.Quote(p.Encoding))
+	}
+	if strings.TrimSpace(p.PriceColumn) == "" || strings.TrimSpace(p.CodeColumn) == "" {
+		return invalid("price and code columns are required")
+	}
+	if p.HeaderRows < 0 {
+		return invalid("header rows can not be negative")
+	}
+	if p.Currency != "" && len(p.Currency) != 3 {
+		return invalid("currency must be a 3 letter code")
+	}
+	switch p.Cleaning.DecimalSeparator {
+	case "", ",", ".":
+	default:
+		return invalid("decimal separator must be \",\" or \".\"")
+	}
+	if p.Cleaning.Multiplier < 0 {
+		return invalid("multiplier can not be negative")
+	}
+	return nil
+}
+
+// CheckCurrency refuses a list priced in another currency than the shop's:
+// the tools compare and mark up prices as they are, without conversion.
+func (p Profile) CheckCurrency(currency string) error {
+	if p.Currency == "" || currency == "" || strings.EqualFold(p.Currency, currency) {
+		return nil
+	}
+	return fmt.Errorf("dealer profile %q is priced in %s, the tools work in %s", p.Name, strings.ToUpper(p.Currency), currency)
+}
+
+// Clean rewrites a price by the rules. Values that are still not a number
+// are returned cleaned but otherwise as they are.
+func (c PriceCleaning) Clean(price string) string {
+	price = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\u00a0' || strings.ContainsRune(c.StripChars, r) {
+			return -1
+		}
+		return r
+	}, price)
+
+	switch c.DecimalSeparator {
+	case ",":
+		price = strings.ReplaceAll(price, ".", "")
+		price = strings.ReplaceAll(price, ",", ".")
+	case ".":
+		price = strings.ReplaceAll(price, ",", "")
+	}
+
+	if c.Multiplier == 0 || c.Multiplier == 1 {
+		return price
+	}
+
+	value, err := strconv.ParseFloat(strings.Replace(price, ",", ".", 1), 64)
+	if err != nil {
+		return price
+	}
+	return strconv.FormatFloat(value*c.Multiplier, 'f', -1, 64)
+}
+
+// Reader cleans the price column of every row after the header.
+func (c PriceCleaning) Reader(r table.Reader, priceIndex int) table.Reader {
+	return &cleaningReader{Reader: r, cleaning: c, priceIndex: priceIndex}
+}
+
+type cleaningReader struct {
+	table.Reader
+	cleaning   PriceCleaning
+	priceIndex int
+	header     bool
+}
+
+func (r *cleaningReader) Read() ([]string, error) {
+	record, err := r.Reader.Read()
+	if err != nil {
+		return record, err
+	}
+
+	if !r.header {
+		r.header = true
+		return record, nil
+	}
+
+	if r.priceIndex < len(record) {
+		record[r.priceIndex] = r.cleaning.Clean(record[r.priceIndex])
+	}
+	return record, nil
+}
@@ -0,0 +1,207 @@
+// Package report collects what the CSV tools found wrong with the files
+// they read, so nothing is dropped or priced as "N/A" without a reason.
+package report
+
+import (
+	"sort"
+	"strings"
+)
+
+// MaxRows caps every list of rows in a report, the counts stay complete.
+const MaxRows = 1000
+
+// Row is a row of an input file with what was wrong with it.
+type Row struct {
+	Line   int    `json:"line"`
+	Raw    string `json:"raw"`
+	Reason string `json:"reason"`
+}
+
+// Duplicate is a code listed more than once with different prices.
+type Duplicate struct {
+	Code   string   `json:"code"`
+	Lines  []int    `json:"lines"`
+	Prices []string `json:"prices"`
+}
+
+// File is the report of one input file.
+type File struct {
+	Field              string      `json:"field"`
+	RowsRead           int         `json:"rowsRead"`
+	Malformed          int         `json:"malformed"`
+	MalformedRows      []Row       `json:"malformedRows"`
+	InvalidPrices      int         `json:"invalidPrices"`
+	InvalidPriceRows   []Row       `json:"invalidPriceRows"`
+	ConflictingCodes   int         `json:"conflictingCodes"`
+	ConflictingEntries []Duplicate `json:"conflictingEntries"`
+
+	delimiter string
+	codes     map[string]*entry
+}
+
+type entry struct {
+	code      string
+	price     float64
+	lines     []int
+	prices    []string
+	conflicts bool
+}
+
+// Report covers a run of a tool: its input files and, for tools that look
+// codes up, the rows that were matched or not.
+type Report struct {
+	Files         []*File `json:"files"`
+	Matched       int     `json:"matched"`
+	Unmatched     int     `json:"unmatched"`
+	UnmatchedRows []Row   `json:"unmatchedRows"`
+	// Stats are counts only some tools keep, such as the duplicate keys of
+	// a join.
+	Stats map[string]int `json:"stats,omitempty"`
+
+	delimiter string
+}
+
+func New() *Report {
+	return &Report{Files: []*File{}, UnmatchedRows: []Row{}}
+}
+
+// File adds the report of an input file. Raw rows are joined with the
+// delimiter of the file, a comma when zero. A nil report gives a nil file,
+// whose methods do nothing.
+func (r *Report) File(field string, delimiter rune) *File {
+	if r == nil {
+		return nil
+	}
+
+	f := &File{
+		Field:              field,
+		MalformedRows:      []Row{},
+		InvalidPriceRows:   []Row{},
+		ConflictingEntries: []Duplicate{},
+		delimiter:          ",",
+		codes:              make(map[string]*entry),
+	}
+	if delimiter != 0 {
+		f.delimiter = string(delimiter)
+	}
+	if len(r.Files) == 0 {
+		r.delimiter = f.delimiter
+	}
+
+	r.Files = append(r.Files, f)
+	return f
+}
+
+// Read counts a data row of the file.
+func (f *File) Read() {
+	if f != nil {
+		f.RowsRead++
+	}
+}
+
+// AddMalformed records a row that could not be used at all.
+func (f *File) AddMalformed(line int, record []string, reason string) {
+	if f == nil {
+		return
+	}
+	f.Malformed++
+	if len(f.MalformedRows) < MaxRows {
+		f.MalformedRows = append(f.MalformedRows, Row{Line: line, Raw: strings.Join(record, f.delimiter), Reason: reason})
+	}
+}
+
+// AddInvalidPrice records a row whose price is zero or not a number.
+func (f *File) AddInvalidPrice(line int, record []string, reason string) {
+	if f == nil {
+		return
+	}
+	f.InvalidPrices++
+	if len(f.InvalidPriceRows) < MaxRows {
+		f.InvalidPriceRows = append(f.InvalidPriceRows, Row{Line: line, Raw: strings.Join(record, f.delimiter), Reason: reason})
+	}
+}
+
+// AddCode notes the price of a code by its part code key, to find codes
+// listed again with a different price.
+func (f *File) AddCode(key, code string, line int, price float64, raw string) {
+	if f == nil || key == "" {
+		return
+	}
+
+	e, ok := f.codes[key]
+	if !ok {
+		f.codes[key] = &entry{code: code, price: price, lines: []int{line}, prices: []string{raw}}
+		return
+	}
+
+	e.lines = append(e.lines, line)
+	e.prices = append(e.prices, raw)
+	if price != e.price {
+		e.conflicts = true
+	}
+}
+
+func (f *File) finish() {
+	if f == nil {
+		return
+	}
+
+	var conflicting []*entry
+	for _, e := range f.codes {
+		if e.conflicts {
+			conflicting = append(conflicting, e)
+		}
+	}
+	sort.Slice(conflicting, func(i, j int) bool {
+		return conflicting[i].lines[0] < conflicting[j].lines[0]
+	})
+
+	f.ConflictingCodes = len(conflicting)
+	f.ConflictingEntries = f.ConflictingEntries[:0]
+	for _, e := range conflicting {
+		if len(f.ConflictingEntries) == MaxRows {
+			break
+		}
+		f.ConflictingEntries = append(f.ConflictingEntries, Duplicate{Code: e.code, Lines: e.lines, Prices: e.prices})
+	}
+}
+
+// AddMatched counts a row that was found in the lookup file.
+func (r *Report) AddMatched() {
+	if r != nil {
+		r.Matched++
+	}
+}
+
+// AddUnmatched records a row that was not found or got no result.
+func (r *Report) AddUnmatched(line int, record []string, reason string) {
+	if r == nil {
+		return
+	}
+	r.Unmatched++
+	if len(r.UnmatchedRows) < MaxRows {
+		r.UnmatchedRows = append(r.UnmatchedRows, Row{Line: line, Raw: strings.Join(record, r.delimiter), Reason: reason})
+	}
+}
+
+// Count sets a stat of the tool.
+func (r *Report) Count(name string, n int) {
+	if r == nil {
+		return
+	}
+	if r.Stats == nil {
+		r.Stats = make(map[string]int)
+	}
+	r.Stats[name] = n
+}
+
+// Finish works out the conflicting duplicates, call it once all files
+// were read.
+func (r *Report) Finish() {
+	if r == nil {
+		return
+	}
+	for _, f := range r.Files {
+		f.finish()
+	}
+}
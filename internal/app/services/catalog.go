@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+	"github.com/trunov/virena/internal/app/util"
+)
+
+// Sizes of the products table columns.
+const (
+	maxCodeLength  = 20
+	maxTextLength  = 255
+	maxBrandLength = 3
+)
+
+// CatalogColumns are the 0-based columns of a catalog file. Code and Price
+// are required, the others are -1 when the file has none.
+type CatalogColumns struct {
+	Code        int
+	Price       int
+	Description int
+	Note        int
+	Weight      int
+	Brand       int
+}
+
+// ReadCatalog streams the products of a catalog file to add, one row at a
+// time. The first row is the header. Rows without a code or a price, and
+// rows that do not fit the products table, are noted in rep and skipped.
+func (s *fileServiceImpl) ReadCatalog(ctx context.Context, reader table.Reader, c CatalogColumns, rep *report.File, add func(util.GetProductResponse) error) error {
+	_, _ = reader.Read() // Skip header
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return err
+		}
+
+		code, price, ok := s.priceRow(reader.Line(), record, err, c.Price, c.Code, false, rep)
+		if !ok || price == 0 {
+			continue
+		}
+
+		if utf8.RuneCountInString(code) > maxCodeLength {
+			rep.AddMalformed(reader.Line(), record, "code longer than 20 characters")
+			continue
+		}
+
+		product := util.GetProductResponse{Code: code, Price: price}
+		product.Description = optionalText(record, c.Description)
+		product.Note = optionalText(record, c.Note)
+		if (product.Description != nil && utf8.RuneCountInString(*product.Description) > maxTextLength) ||
+			(product.Note != nil && utf8.RuneCountInString(*product.Note) > maxTextLength) {
+			rep.AddMalformed(reader.Line(), record, "text longer than 255 characters")
+			continue
+		}
+
+		if weight := optionalText(record, c.Weight); weight != nil {
+			value, err := ParsePrice(*weight)
+			if err != nil {
+				rep.AddMalformed(reader.Line(), record, "weight is not a number")
+				continue
+			}
+			product.Weight = &value
+		}
+
+		if brand := optionalText(record, c.Brand); brand != nil {
+			product.Brand = strings.ToUpper(*brand)
+			if utf8.RuneCountInString(product.Brand) > maxBrandLength {
+				rep.AddMalformed(reader.Line(), record, "brand longer than 3 characters")
+				continue
+			}
+		}
+
+		if err := add(product); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// optionalText is the trimmed value of a column, nil when the column is
+// not given or empty.
+func optionalText(record []string, index int) *string {
+	if index < 0 || index >= len(record) {
+		return nil
+	}
+	value := strings.TrimSpace(record[index])
+	if value == "" {
+		return nil
+	}
+	return &value
+}
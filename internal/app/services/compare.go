@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// DealerPrices is the price list of one dealer in an N-way comparison.
+// Rows with their own dealer number (Dealer) keep it, the others get Number.
+// Read gives every price of the list to add, so a file is streamed instead
+// of being held in memory.
+type DealerPrices struct {
+	Number string
+	Read   func(add func(Dealer) error) error
+}
+
+type quote struct {
+	price  float64
+	dealer string
+	list   int
+}
+
+// quotes are the quotes of one code, listed in the form it was first seen in.
+type quotes struct {
+	code   string
+	quotes []quote
+}
+
+// ReadPrices reads code and price columns after the header row. Rows that
+// are too short or have no code are skipped and noted in rep, dealerColumn
+// < 0 means none.
+func (s *fileServiceImpl) ReadPrices(ctx context.Context, reader table.Reader, priceIndex, codeIndex, dealerColumn int, rep *report.File) ([]Dealer, error) {
+	var dealers []Dealer
+	err := s.EachPrice(ctx, reader, priceIndex, codeIndex, dealerColumn, rep, func(d Dealer) error {
+		dealers = append(dealers, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dealers, nil
+}
+
+// EachPrice is ReadPrices for a file that is not kept: every price is given
+// to add as it is read.
+func (s *fileServiceImpl) EachPrice(ctx context.Context, reader table.Reader, priceIndex, codeIndex, dealerColumn int, rep *report.File, add func(Dealer) error) error {
+	_, _ = reader.Read() // Skip header
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return err
+		}
+
+		code, price, ok := s.priceRow(reader.Line(), record, err, priceIndex, codeIndex, true, rep)
+		if !ok {
+			continue
+		}
+
+		dealer := Dealer{
+			Code:  code,
+			Price: price,
+		}
+		if dealerColumn >= 0 && dealerColumn < len(record) {
+			dealer.Dealer = strings.TrimSpace(record[dealerColumn])
+		}
+
+		if err := add(dealer); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CompareDealers compares any number of price lists in one pass. For every
+// code it writes the best, second best and worst price with their dealers,
+// the spread between best and worst and how many dealers quoted it to out.
+// Only the quotes by code are held in memory. Codes quoted by a single
+// dealer are noted in rep by their line in the result.
+//
+// Codes match by their part code keys. Zero prices (unparseable
+// ones included) are not quotes. With offsetPercentage a dealer earlier in
+// the list stays the best one unless a later dealer is cheaper by more than
+// that many percent, as in CompareAndProcessFiles.
+func (s *fileServiceImpl) CompareDealers(ctx context.Context, dealers []DealerPrices, offsetPercentage int, out table.Writer, rep *report.Report) error {
+	// codes are written in the order they were first seen in
+	var keys []string
+	byKey := make(map[string]*quotes)
+
+	for list, prices := range dealers {
+		err := prices.Read(func(d Dealer) error {
+			if d.Price <= 0 {
+				return nil
+			}
+
+			dealerNum := d.Dealer
+			if dealerNum == "" {
+				dealerNum = prices.Number
+			}
+
+			k := s.codes.Key("", d.Code)
+			if k == "" {
+				return nil
+			}
+			c, ok := byKey[k]
+			if !ok {
+				c = &quotes{code: d.Code}
+				byKey[k] = c
+				keys = append(keys, k)
+			}
+
+			// a code listed twice by one dealer counts once, at its lower price
+			q := c.quotes
+			if n := len(q); n > 0 && q[n-1].list == list {
+				if d.Price < q[n-1].price {
+					q[n-1] = quote{price: d.Price, dealer: dealerNum, list: list}
+				}
+				return nil
+			}
+
+			c.quotes = append(q, quote{price: d.Price, dealer: dealerNum, list: list})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := out.Write([]string{"Code", "Best Price", "Dealer Number", "Second Price", "Second Dealer Number", "Worst Price", "Worst Dealer Number", "Spread", "Dealers"}); err != nil {
+		return err
+	}
+
+	for i, k := range keys {
+		c := byKey[k]
+		q := c.quotes
+		sort.SliceStable(q, func(i, j int) bool {
+			return q[i].price < q[j].price
+		})
+
+		best := 0
+		if offsetPercentage > 0 {
+			for i, c := range q {
+				if c.list < q[best].list && (c.price-q[0].price)/c.price*100 <= float64(offsetPercentage) {
+					best = i
+				}
+			}
+		}
+
+		row := []string{c.code, fmt.Sprintf("%.2f", q[best].price), q[best].dealer}
+		if len(q) == 1 {
+			row = append(row, "N/A", "N/A", "N/A", "N/A", "N/A", "1")
+			rep.AddUnmatched(i+2, row, "quoted by one dealer only")
+		} else {
+			rest := make([]quote, 0, len(q)-1)
+			rest = append(rest, q[:best]...)
+			rest = append(rest, q[best+1:]...)
+			second, worst := rest[0], rest[len(rest)-1]
+
+			spread := (worst.price - q[best].price) / q[best].price * 100
+
+			row = append(row,
+				fmt.Sprintf("%.2f", second.price), second.dealer,
+				fmt.Sprintf("%.2f", worst.price), worst.dealer,
+				fmt.Sprintf("%.2f%%", spread),
+				strconv.Itoa(len(q)),
+			)
+			rep.AddMatched()
+		}
+
+		if err := out.Write(row); err != nil {
+			return err
+		}
+		// the quotes of a written code are not needed any more
+		delete(byKey, k)
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// rows is a table.Writer that keeps the written rows.
+type rows [][]string
+
+func (r *rows) Write(record []string) error {
+	*r = append(*r, record)
+	return nil
+}
+
+func (r *rows) Flush() error { return nil }
+
+func prices(number string, list ...Dealer) DealerPrices {
+	return DealerPrices{Number: number, Read: func(add func(Dealer) error) error {
+		for _, d := range list {
+			if err := add(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+func TestCompareDealers(t *testing.T) {
+	dealers := []DealerPrices{
+		prices("1", Dealer{Code: "A1", Price: 10}, Dealer{Code: "B2", Price: 5}, Dealer{Code: "A1", Price: 9}),
+		prices("2", Dealer{Code: "a1", Price: 12}, Dealer{Code: "C3", Price: 0}, Dealer{Code: "D4", Price: 7, Dealer: "22"}),
+		prices("3", Dealer{Code: "A1", Price: 8.5}),
+	}
+
+	tests := []struct {
+		name   string
+		offset int
+		want   [][]string
+	}{
+		{"cheapest wins", 0, [][]string{
+			{"A1", "8.50", "3", "9.00", "1", "12.00", "2", "41.18%", "3"},
+			{"B2", "5.00", "1", "N/A", "N/A", "N/A", "N/A", "N/A", "1"},
+			{"D4", "7.00", "22", "N/A", "N/A", "N/A", "N/A", "N/A", "1"},
+		}},
+		{"earlier dealer within offset", 10, [][]string{
+			{"A1", "9.00", "1", "8.50", "3", "12.00", "2", "33.33%", "3"},
+			{"B2", "5.00", "1", "N/A", "N/A", "N/A", "N/A", "N/A", "1"},
+			{"D4", "7.00", "22", "N/A", "N/A", "N/A", "N/A", "N/A", "1"},
+		}},
+	}
+	for _, tt := range tests {
+		var out rows
+		s := NewFileService(nil)
+		if err := s.CompareDealers(context.Background(), dealers, tt.offset, &out, nil); err != nil {
+			t.Fatal(err)
+		}
+		if len(out) == 0 || out[0][0] != "Code" {
+			t.Fatalf("%s: no header row in %v", tt.name, out)
+		}
+		if got := [][]string(out[1:]); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CompareDealers() =\n%v\nwant\n%v", tt.name, got, tt.want)
+		}
+	}
+}
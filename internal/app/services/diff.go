@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Kinds of a PriceChange.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// PriceChange is a code that came, went or changed its price between two
+// price lists. Prices missing on one side are nil.
+type PriceChange struct {
+	Code     string   `json:"code"`
+	Change   string   `json:"change"`
+	OldPrice *float64 `json:"oldPrice"`
+	NewPrice *float64 `json:"newPrice"`
+	// Delta is the change of the price in percent.
+	Delta *float64 `json:"delta"`
+}
+
+// PriceDiff counts every change, Changes only lists those that pass the
+// threshold.
+type PriceDiff struct {
+	Added     int           `json:"added"`
+	Removed   int           `json:"removed"`
+	Changed   int           `json:"changed"`
+	Unchanged int           `json:"unchanged"`
+	Changes   []PriceChange `json:"changes"`
+}
+
+// DiffPrices compares two versions of a price list by part code keys. Zero
+// prices are not quotes and a code listed twice counts at its lower price,
+// as in CompareDealers. Price changes smaller than threshold percent are
+// left out of Changes, added and removed codes are always listed: added and
+// changed ones in the order of the current list, then the removed ones.
+func (s *fileServiceImpl) DiffPrices(ctx context.Context, previous, current []Dealer, threshold float64) (PriceDiff, error) {
+	diff := PriceDiff{Changes: []PriceChange{}}
+
+	oldKeys, oldPrices := s.lowestPrices(previous)
+	newKeys, newPrices := s.lowestPrices(current)
+
+	for _, key := range newKeys {
+		current := newPrices[key]
+		old, ok := oldPrices[key]
+		if !ok {
+			diff.Added++
+			diff.Changes = append(diff.Changes, PriceChange{Code: current.Code, Change: ChangeAdded, NewPrice: &current.Price})
+			continue
+		}
+
+		if old.Price == current.Price {
+			diff.Unchanged++
+			continue
+		}
+
+		diff.Changed++
+		delta := (current.Price - old.Price) / old.Price * 100
+		if math.Abs(delta) < threshold {
+			continue
+		}
+		diff.Changes = append(diff.Changes, PriceChange{
+			Code:     current.Code,
+			Change:   ChangeChanged,
+			OldPrice: &old.Price,
+			NewPrice: &current.Price,
+			Delta:    &delta,
+		})
+	}
+
+	for _, key := range oldKeys {
+		if _, ok := newPrices[key]; ok {
+			continue
+		}
+		old := oldPrices[key]
+		diff.Removed++
+		diff.Changes = append(diff.Changes, PriceChange{Code: old.Code, Change: ChangeRemoved, OldPrice: &old.Price})
+	}
+
+	return diff, nil
+}
+
+// lowestPrices keys a price list by part code, keeping the first form of a
+// code and its lowest price.
+func (s *fileServiceImpl) lowestPrices(prices []Dealer) ([]string, map[string]Dealer) {
+	var keys []string
+	byKey := make(map[string]Dealer, len(prices))
+
+	for _, d := range prices {
+		key := s.codes.Key("", d.Code)
+		if key == "" || d.Price <= 0 {
+			continue
+		}
+
+		existing, ok := byKey[key]
+		switch {
+		case !ok:
+			keys = append(keys, key)
+			byKey[key] = d
+		case d.Price < existing.Price:
+			existing.Price = d.Price
+			byKey[key] = existing
+		}
+	}
+
+	return keys, byKey
+}
+
+// Rows lays the changes out as a table with a header row.
+func (d PriceDiff) Rows() [][]string {
+	rows := [][]string{{"Code", "Change", "Old Price", "New Price", "Delta"}}
+	for _, c := range d.Changes {
+		rows = append(rows, []string{c.Code, c.Change, formatPrice(c.OldPrice), formatPrice(c.NewPrice), formatDelta(c.Delta)})
+	}
+	return rows
+}
+
+func formatPrice(price *float64) string {
+	if price == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f", *price)
+}
+
+func formatDelta(delta *float64) string {
+	if delta == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.2f%%", *delta)
+}
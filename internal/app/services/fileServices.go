@@ -1,15 +1,15 @@
 package services
 
 import (
-	"bytes"
 	"context"
-	"encoding/csv"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"strconv"
 	"strings"
 
+	"github.com/trunov/virena/internal/app/partcode"
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
 	"github.com/trunov/virena/internal/app/util"
 )
 
@@ -23,219 +23,292 @@ type Dealer struct {
 }
 
 type FileService interface {
-	ReadFile(ctx context.Context, file multipart.File, delimiter rune, priceIndex, codeIndex, dealerColumn int) ([]Dealer, error)
-	ReadFileToMap(ctx context.Context, file multipart.File, delimiter rune, priceIndex, codeIndex int) (map[string]Dealer, error)
-	CompareAndProcessFiles(ctx context.Context, dealerOne []Dealer, dealerTwo map[string]Dealer, dealerColumn, secondDealerNumber, offsetPercentage int, firstDealerNumber string) ([][]string, error)
+	ReadFileToMap(ctx context.Context, reader table.Reader, priceIndex, codeIndex int, rep *report.File) (map[string]Dealer, error)
+	CompareAndProcessFiles(ctx context.Context, dealerOne table.Reader, dealerTwo map[string]Dealer, c DealerComparison, out table.Writer, dealerOneRep *report.File, rep *report.Report) error
+	ReadPrices(ctx context.Context, reader table.Reader, priceIndex, codeIndex, dealerColumn int, rep *report.File) ([]Dealer, error)
+	EachPrice(ctx context.Context, reader table.Reader, priceIndex, codeIndex, dealerColumn int, rep *report.File, add func(Dealer) error) error
+	CompareDealers(ctx context.Context, dealers []DealerPrices, offsetPercentage int, out table.Writer, rep *report.Report) error
+	UpdatePrices(ctx context.Context, prices, products table.Reader, u PriceUpdate, out table.Writer, priceRep, productRep *report.File, rep *report.Report) error
+	DiffPrices(ctx context.Context, previous, current []Dealer, threshold float64) (PriceDiff, error)
+	Join(ctx context.Context, source, target table.Reader, opts JoinOptions, out table.Writer, sourceRep, targetRep *report.File, rep *report.Report) error
+	ReadCatalog(ctx context.Context, reader table.Reader, c CatalogColumns, rep *report.File, add func(util.GetProductResponse) error) error
 }
 
-type fileServiceImpl struct{}
-
-func NewFileService() FileService {
-	return &fileServiceImpl{}
+type fileServiceImpl struct {
+	codes *partcode.Normalizer
 }
 
-func (s *fileServiceImpl) ReadFile(ctx context.Context, file multipart.File, delimiter rune, priceIndex, codeIndex, dealerColumn int) ([]Dealer, error) {
-	content, err := io.ReadAll(file)
-	if err != nil {
-		return nil, err
+// NewFileService matches part codes of the files with codes, the default
+// rules when nil. The files carry no brand, so codes are keyed by the
+// default rules; only the price tool knows the brand of its products.
+func NewFileService(codes *partcode.Normalizer) FileService {
+	if codes == nil {
+		codes = partcode.New(nil)
 	}
+	return &fileServiceImpl{codes: codes}
+}
 
-	processedContent := bytes.ReplaceAll(content, []byte(`"`), []byte(``))
+func (s *fileServiceImpl) ReadFileToMap(ctx context.Context, reader table.Reader, priceIndex, codeIndex int, rep *report.File) (map[string]Dealer, error) {
+	dealersMap := make(map[string]Dealer)
 
-	reader := csv.NewReader(bytes.NewReader(processedContent))
-	reader.Comma = delimiter
-	var dealers []Dealer
 	_, _ = reader.Read() // Skip header
 
-	var counter int
-
 	for {
 		record, err := reader.Read()
 		if err == io.EOF {
 			break
 		}
-		if err != nil {
-			counter++
+		if err != nil && !table.IsRowError(err) {
+			return nil, err
+		}
+
+		code, price, ok := s.priceRow(reader.Line(), record, err, priceIndex, codeIndex, true, rep)
+		if !ok {
 			continue
 		}
 
-		if len(record) >= 6 {
-			dealers = append(dealers, Dealer{
-				Code:              record[codeIndex],
-				Price:             parsePrice(record[priceIndex]),
-				Dealer:            record[dealerColumn],
-				WorstPrice:        record[len(record)-3],
-				WorstDealerNumber: record[len(record)-2],
-				PriceRatio:        record[len(record)-1],
-			})
-		} else if dealerColumn > 0 && dealerColumn < len(record) {
-			dealers = append(dealers, Dealer{
-				Code:   record[codeIndex],
-				Price:  parsePrice(record[priceIndex]),
-				Dealer: record[dealerColumn],
-			})
-		} else {
-			dealers = append(dealers, Dealer{
-				Code:  record[codeIndex],
-				Price: parsePrice(record[priceIndex]),
-			})
+		dealersMap[code] = Dealer{
+			Code:  code,
+			Price: price,
 		}
 	}
 
-	return dealers, nil
+	return dealersMap, nil
 }
 
-func (s *fileServiceImpl) ReadFileToMap(ctx context.Context, file multipart.File, delimiter rune, priceIndex, codeIndex int) (map[string]Dealer, error) {
-	reader := csv.NewReader(file)
-	reader.Comma = delimiter
-	dealersMap := make(map[string]Dealer)
+// priceRow takes the code and price of a data row. Rows without them are
+// noted in rep as malformed and skipped, prices that are zero or no number
+// are noted and read as 0. Blank rows are skipped without a note. With
+// keep, the code is noted in rep to find codes listed again with another
+// price; only files held in memory anyway do so, streamed files would hold
+// every code in the report.
+func (s *fileServiceImpl) priceRow(line int, record []string, readErr error, priceIndex, codeIndex int, keep bool, rep *report.File) (string, float64, bool) {
+	if readErr == nil && blank(record) {
+		return "", 0, false
+	}
 
-	_, _ = reader.Read() // Skip header
+	rep.Read()
+	switch {
+	case readErr != nil:
+		rep.AddMalformed(line, record, readErr.Error())
+		return "", 0, false
+	case len(record) <= priceIndex || len(record) <= codeIndex:
+		rep.AddMalformed(line, record, "missing price or code column")
+		return "", 0, false
+	}
 
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
+	code := partcode.Clean(record[codeIndex])
+	if code == "" {
+		rep.AddMalformed(line, record, "no part code")
+		return "", 0, false
+	}
 
-		dealer := Dealer{
-			Code:  record[codeIndex],
-			Price: parsePrice(record[priceIndex]),
-		}
+	price, err := ParsePrice(record[priceIndex])
+	switch {
+	case err != nil:
+		rep.AddInvalidPrice(line, record, "price is not a number")
+	case price == 0:
+		rep.AddInvalidPrice(line, record, "zero price")
+	case keep:
+		rep.AddCode(s.codes.Key("", code), code, line, price, record[priceIndex])
+	}
 
-		dealersMap[dealer.Code] = dealer
+	return code, price, true
+}
+
+func blank(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
 	}
+	return true
+}
 
-	return dealersMap, nil
+// DealerComparison describes a run of the dealer tool. The indexes are
+// 0-based into dealer one, DealerColumn is -1 when not given.
+type DealerComparison struct {
+	PriceIndex         int
+	CodeIndex          int
+	DealerColumn       int
+	FirstDealerNumber  string
+	SecondDealerNumber int
+	OffsetPercentage   int
 }
 
-func (s *fileServiceImpl) CompareAndProcessFiles(ctx context.Context, dealerOne []Dealer, dealerTwoMap map[string]Dealer, dealerColumn, secondDealerNumber, offsetPercentage int, firstDealerNumber string) ([][]string, error) {
-	results := [][]string{{"Code", "Best Price", "Dealer Number", "Second Price", "Second Dealer Number", "Price Ratio"}}
+// CompareAndProcessFiles compares the prices of dealer one with dealer two.
+// Only dealer two is held in memory, the rows of dealer one are streamed to
+// out with the better price first, then come the codes only dealer two has.
+// Rows of an earlier comparison keep their worst price columns. Rows that
+// cannot be used are noted in dealerOneRep, codes of one dealer only in rep
+// by their line in the result.
+func (s *fileServiceImpl) CompareAndProcessFiles(ctx context.Context, dealerOne table.Reader, dealerTwoMap map[string]Dealer, c DealerComparison, out table.Writer, dealerOneRep *report.File, rep *report.Report) error {
+	line := 1
+	write := func(row []string) error {
+		line++
+		if row[3] == "N/A" {
+			rep.AddUnmatched(line, row, "code of one dealer only")
+		} else {
+			rep.AddMatched()
+		}
+		return out.Write(row)
+	}
+
+	if err := out.Write([]string{"Code", "Best Price", "Dealer Number", "Second Price", "Second Dealer Number", "Price Ratio"}); err != nil {
+		return err
+	}
 
+	// codes of both dealers are matched by their part code keys
 	processedCodes := make(map[string]struct{})
+	dealerTwoByKey := make(map[string]Dealer, len(dealerTwoMap))
+	for code, d2 := range dealerTwoMap {
+		dealerTwoByKey[s.codes.Key("", code)] = d2
+	}
 
-	for _, d1 := range dealerOne {
-		code := d1.Code
-		bestPrice := d1.Price
-		var dealerNum string
+	_, _ = dealerOne.Read() // Skip header
 
-		var worstPrice float64
-		var worstDealerNum string
+	for {
+		record, err := dealerOne.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return err
+		}
 
-		if dealerColumn > 0 {
-			dealerNum = d1.Dealer
-		} else {
-			dealerNum = firstDealerNumber
+		code, price, ok := s.priceRow(dealerOne.Line(), record, err, c.PriceIndex, c.CodeIndex, false, dealerOneRep)
+		if !ok {
+			continue
 		}
 
-		d2, found := dealerTwoMap[code]
-		// since some files might have empty codes
-		if !found && len(code) > 1 {
-			if code[0] == '0' {
-				unprefixedCode := code[1:]
-				d2, found = dealerTwoMap[unprefixedCode]
-			} else {
-				prefixedCode := "0" + code
-				d2, found = dealerTwoMap[prefixedCode]
-			}
+		d1 := Dealer{Code: code, Price: price}
+		if c.DealerColumn >= 0 && c.DealerColumn < len(record) {
+			d1.Dealer = record[c.DealerColumn]
+		}
+		// rows of an earlier comparison end in its worst price columns
+		if len(record) >= 6 {
+			d1.WorstPrice = record[len(record)-3]
+			d1.WorstDealerNumber = record[len(record)-2]
+			d1.PriceRatio = record[len(record)-1]
 		}
 
+		key := s.codes.Key("", code)
+		// since some files might have empty codes
+		d2, found := dealerTwoByKey[key]
+		if key == "" {
+			found = false
+		}
 		if found {
-			if d2.Price < bestPrice {
-				if offsetPercentage > 0 {
-					priceDifference := ((bestPrice - d2.Price) / bestPrice) * 100
-
-					if priceDifference <= float64(offsetPercentage) {
-						worstPrice = bestPrice
-					} else {
-						worstPrice = bestPrice
-						bestPrice = d2.Price
-
-						dealerNum = util.GetDealerNum(secondDealerNumber)
-					}
-				} else {
-					worstPrice = bestPrice
-					bestPrice = d2.Price
-
-					// either 1 or if dealer number from csv
-					worstDealerNum = dealerNum
-
-					dealerNum = util.GetDealerNum(secondDealerNumber)
-				}
-			} else {
-				var worstDealerPriceFromFile float64
-				worstPriceIsInDealer := d1.WorstPrice != "" && d1.WorstPrice != "N/A"
-				if worstPriceIsInDealer {
-					worstDealerPriceFromFile = parsePrice(d1.WorstPrice)
-					// case when error occurs
-					if worstDealerPriceFromFile == 0 {
-						worstDealerPriceFromFile = d1.Price
-					}
-				}
-
-				// Update worst price only if it's better (lower) than the current worst price
-				if d2.Price > worstDealerPriceFromFile && worstPriceIsInDealer {
-					worstDealerNum = d1.WorstDealerNumber
-					worstPrice = worstDealerPriceFromFile
-				} else {
-					// If the new price is worse than the best price but better than the current worst, update
-					worstDealerNum = util.GetDealerNum(secondDealerNumber)
-					worstPrice = d2.Price
-				}
-			}
-
-			processedCodes[code] = struct{}{}
-
-			priceRatio := ((worstPrice - bestPrice) / bestPrice) * 100
-			pr := fmt.Sprintf("%.2f%%", priceRatio)
-			wp := fmt.Sprintf("%.2f", worstPrice)
+			processedCodes[key] = struct{}{}
+		}
 
-			results = append(results, []string{code, fmt.Sprintf("%.2f", bestPrice), dealerNum, wp, worstDealerNum, pr})
-		} else {
-			// Code not found in dealerTwoMap, append with N/A values
-			if d1.WorstPrice == "" {
-				results = append(results, []string{code, fmt.Sprintf("%.2f", bestPrice), dealerNum, "N/A", "N/A", "N/A"})
-			} else {
-				results = append(results, []string{code, fmt.Sprintf("%.2f", bestPrice), dealerNum, d1.WorstPrice, d1.WorstDealerNumber, d1.PriceRatio})
-			}
+		if err := write(s.compareDealers(d1, d2, found, c)); err != nil {
+			return err
 		}
 	}
 
 	for code, d2 := range dealerTwoMap {
-		if _, found := processedCodes[code]; found {
+		if _, found := processedCodes[s.codes.Key("", code)]; found {
 			continue
 		}
 
-		var found bool
-		if len(code) > 1 {
-			if code[0] == '0' {
-				unprefixedCode := code[1:]
-				_, found = processedCodes[unprefixedCode]
-			} else {
-				prefixedCode := "0" + code
-				_, found = processedCodes[prefixedCode]
-			}
+		dealerNum := "2"
+		if c.SecondDealerNumber > 0 {
+			dealerNum = strconv.Itoa(c.SecondDealerNumber)
 		}
 
-		if found {
-			continue
+		if err := write([]string{code, fmt.Sprintf("%.2f", d2.Price), dealerNum, "N/A", "N/A", "N/A"}); err != nil {
+			return err
 		}
+	}
 
-		dealerNum := "2"
-		if secondDealerNumber > 0 {
-			dealerNum = strconv.Itoa(secondDealerNumber)
+	return out.Flush()
+}
+
+// compareDealers is the result row of a dealer one price, found tells
+// whether dealer two has the code.
+func (s *fileServiceImpl) compareDealers(d1, d2 Dealer, found bool, c DealerComparison) []string {
+	code := d1.Code
+	bestPrice := d1.Price
+	var dealerNum string
+
+	var worstPrice float64
+	var worstDealerNum string
+
+	if c.DealerColumn >= 0 {
+		dealerNum = d1.Dealer
+	} else {
+		dealerNum = c.FirstDealerNumber
+	}
+
+	if !found {
+		// Code not found in dealerTwoMap, append with N/A values
+		if d1.WorstPrice == "" {
+			return []string{code, fmt.Sprintf("%.2f", bestPrice), dealerNum, "N/A", "N/A", "N/A"}
+		}
+		return []string{code, fmt.Sprintf("%.2f", bestPrice), dealerNum, d1.WorstPrice, d1.WorstDealerNumber, d1.PriceRatio}
+	}
+
+	if d2.Price < bestPrice {
+		if c.OffsetPercentage > 0 {
+			priceDifference := ((bestPrice - d2.Price) / bestPrice) * 100
+
+			if priceDifference <= float64(c.OffsetPercentage) {
+				worstPrice = bestPrice
+			} else {
+				worstPrice = bestPrice
+				bestPrice = d2.Price
+
+				dealerNum = util.GetDealerNum(c.SecondDealerNumber)
+			}
+		} else {
+			worstPrice = bestPrice
+			bestPrice = d2.Price
+
+			// either 1 or if dealer number from csv
+			worstDealerNum = dealerNum
+
+			dealerNum = util.GetDealerNum(c.SecondDealerNumber)
+		}
+	} else {
+		var worstDealerPriceFromFile float64
+		worstPriceIsInDealer := d1.WorstPrice != "" && d1.WorstPrice != "N/A"
+		if worstPriceIsInDealer {
+			worstDealerPriceFromFile = parsePrice(d1.WorstPrice)
+			// case when error occurs
+			if worstDealerPriceFromFile == 0 {
+				worstDealerPriceFromFile = d1.Price
+			}
 		}
 
-		results = append(results, []string{code, fmt.Sprintf("%.2f", d2.Price), dealerNum, "N/A", "N/A", "N/A"})
+		// Update worst price only if it's better (lower) than the current worst price
+		if d2.Price > worstDealerPriceFromFile && worstPriceIsInDealer {
+			worstDealerNum = d1.WorstDealerNumber
+			worstPrice = worstDealerPriceFromFile
+		} else {
+			// If the new price is worse than the best price but better than the current worst, update
+			worstDealerNum = util.GetDealerNum(c.SecondDealerNumber)
+			worstPrice = d2.Price
+		}
 	}
 
-	return results, nil
+	priceRatio := ((worstPrice - bestPrice) / bestPrice) * 100
+	pr := fmt.Sprintf("%.2f%%", priceRatio)
+	wp := fmt.Sprintf("%.2f", worstPrice)
+
+	return []string{code, fmt.Sprintf("%.2f", bestPrice), dealerNum, wp, worstDealerNum, pr}
 }
 
 func parsePrice(priceStr string) float64 {
+	price, err := ParsePrice(priceStr)
+	if err != nil {
+		return 0
+	}
+	return price
+}
+
+// ParsePrice reads prices such as "1 234,50" and "1,234.50".
+func ParsePrice(priceStr string) (float64, error) {
 	priceStr = strings.Replace(priceStr, "\u00A0", "", -1) // Remove non-breaking spaces
 	priceStr = strings.TrimSpace(priceStr)                 // Trim any leading or trailing whitespace
 	priceStr = strings.Replace(priceStr, " ", "", -1)      // Remove regular spaces
@@ -246,9 +319,5 @@ func parsePrice(priceStr string) float64 {
 	}
 
 	priceStr = strings.Replace(priceStr, ",", ".", -1) // Convert comma to dot for parsing
-	price, err := strconv.ParseFloat(priceStr, 64)
-	if err != nil {
-		return 0
-	}
-	return price
+	return strconv.ParseFloat(priceStr, 64)
 }
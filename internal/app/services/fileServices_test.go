@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// writeDealerFile generates a dealer price list of rows codes, the even
+// numbers from 0.
+func writeDealerFile(b *testing.B, rows int) string {
+	b.Helper()
+
+	path := filepath.Join(b.TempDir(), "dealer.csv")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "price;code")
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(w, "%d,%02d;%08d\n", 1+i%500, i%100, i*2)
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkCompareAndProcessFiles streams dealer one from disk. The live
+// heap after a run should not grow with the rows of dealer one, only
+// dealer two is held in memory.
+func BenchmarkCompareAndProcessFiles(b *testing.B) {
+	// dealer two has every other code of the first 20000 rows
+	dealerTwo := make(map[string]Dealer)
+	for i := 0; i < 10000; i++ {
+		code := fmt.Sprintf("%08d", i*4)
+		dealerTwo[code] = Dealer{Code: code, Price: float64(1 + i%400)}
+	}
+
+	for _, rows := range []int{10000, 100000, 1000000} {
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			path := writeDealerFile(b, rows)
+			s := NewFileService(nil)
+			c := DealerComparison{PriceIndex: 0, CodeIndex: 1, DealerColumn: -1, FirstDealerNumber: "1", SecondDealerNumber: 2}
+
+			var live uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				dealerOne, err := table.Open(f, path, table.Options{Delimiter: ';'})
+				if err != nil {
+					b.Fatal(err)
+				}
+				out, err := table.NewWriter(io.Discard, table.WriterOptions{Delimiter: ';'})
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				rep := report.New()
+				if err := s.CompareAndProcessFiles(context.Background(), dealerOne, dealerTwo, c, out, rep.File("dealerOne", ';'), rep); err != nil {
+					b.Fatal(err)
+				}
+				f.Close()
+
+				var m runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > live {
+					live = m.HeapAlloc
+				}
+				runtime.KeepAlive(rep)
+			}
+			b.ReportMetric(float64(live), "live-B")
+		})
+	}
+}
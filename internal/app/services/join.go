@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// Join types: left keeps every target row, inner only the matched ones and
+// anti only those without a match.
+const (
+	JoinLeft  = "left"
+	JoinInner = "inner"
+	JoinAnti  = "anti"
+)
+
+// Policies for a key found on several source rows.
+const (
+	DuplicatesFirst = "first"
+	DuplicatesLast  = "last"
+	DuplicatesError = "error"
+	// DuplicatesAll gives a target row once per source row of its key.
+	DuplicatesAll = "all"
+)
+
+// JoinOptions describe a join of source columns onto the rows of a target
+// file. Keys match by their part code keys. Headers rename the Columns in
+// the output, empty names keep the source header.
+type JoinOptions struct {
+	SourceKey  int
+	TargetKey  int
+	Columns    []int
+	Headers    []string
+	Type       string
+	Duplicates string
+}
+
+// DuplicateKeyError stops a join with the DuplicatesError policy.
+type DuplicateKeyError struct {
+	Key   string
+	Lines []int
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("key %q is on source lines %d and %d", e.Key, e.Lines[0], e.Lines[1])
+}
+
+// ValidJoin checks the join type and duplicate policy, empty ones are left
+// joins keeping the first source row.
+func ValidJoin(joinType, duplicates string) error {
+	switch joinType {
+	case "", JoinLeft, JoinInner, JoinAnti:
+	default:
+		return fmt.Errorf("unknown join type %q, use left, inner or anti", joinType)
+	}
+	switch duplicates {
+	case "", DuplicatesFirst, DuplicatesLast, DuplicatesError, DuplicatesAll:
+	default:
+		return fmt.Errorf("unknown duplicates policy %q, use first, last, error or all", duplicates)
+	}
+	return nil
+}
+
+type joinRow struct {
+	line   int
+	values []string
+}
+
+// Join reads the source into memory by key and streams the target rows to
+// out. The first row of both files is their header. Rows are noted in the
+// file reports, matches and target rows without one in rep, by their line
+// in the target file. The "duplicateKeys" and "outputRows" stats are kept.
+func (s *fileServiceImpl) Join(ctx context.Context, source, target table.Reader, opts JoinOptions, out table.Writer, sourceRep, targetRep *report.File, rep *report.Report) error {
+	header, err := source.Read()
+	if err != nil && err != io.EOF && !table.IsRowError(err) {
+		return err
+	}
+
+	added := make([]string, len(opts.Columns))
+	for i, index := range opts.Columns {
+		if i < len(opts.Headers) && opts.Headers[i] != "" {
+			added[i] = opts.Headers[i]
+		} else if index < len(header) {
+			added[i] = header[index]
+		}
+	}
+
+	rows := make(map[string][]joinRow)
+	duplicated := make(map[string]bool)
+	for {
+		record, err := source.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return err
+		}
+		if err == nil && blank(record) {
+			continue
+		}
+
+		sourceRep.Read()
+		switch {
+		case err != nil:
+			sourceRep.AddMalformed(source.Line(), record, err.Error())
+			continue
+		case len(record) <= opts.SourceKey:
+			sourceRep.AddMalformed(source.Line(), record, "missing key column")
+			continue
+		}
+
+		key := s.codes.Key("", record[opts.SourceKey])
+		if key == "" {
+			sourceRep.AddMalformed(source.Line(), record, "no key")
+			continue
+		}
+
+		row := joinRow{line: source.Line(), values: make([]string, len(opts.Columns))}
+		for i, index := range opts.Columns {
+			if index < len(record) {
+				row.values[i] = record[index]
+			}
+		}
+
+		existing, ok := rows[key]
+		if !ok {
+			rows[key] = []joinRow{row}
+			continue
+		}
+
+		duplicated[key] = true
+		switch opts.Duplicates {
+		case DuplicatesError:
+			return &DuplicateKeyError{Key: strings.TrimSpace(record[opts.SourceKey]), Lines: []int{existing[0].line, row.line}}
+		case DuplicatesLast:
+			rows[key] = []joinRow{row}
+		case DuplicatesAll:
+			rows[key] = append(existing, row)
+		}
+	}
+	rep.Count("duplicateKeys", len(duplicated))
+
+	var width, outputRows int
+	write := func(record []string, values []string) error {
+		// short rows are padded so the added columns line up
+		row := make([]string, max(width, len(record)), max(width, len(record))+len(values))
+		copy(row, record)
+		return out.Write(append(row, values...))
+	}
+
+	for header := true; ; header = false {
+		record, err := target.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return err
+		}
+
+		if header {
+			width = len(record)
+			if opts.Type == JoinAnti {
+				err = write(record, nil)
+			} else {
+				err = write(record, added)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err == nil && blank(record) {
+			continue
+		}
+
+		targetRep.Read()
+		if err != nil {
+			targetRep.AddMalformed(target.Line(), record, err.Error())
+			continue
+		}
+
+		var matches []joinRow
+		if len(record) > opts.TargetKey {
+			matches = rows[s.codes.Key("", record[opts.TargetKey])]
+		}
+
+		if len(matches) > 0 {
+			rep.AddMatched()
+		} else if len(record) > opts.TargetKey {
+			rep.AddUnmatched(target.Line(), record, "key not in the source file")
+		} else {
+			rep.AddUnmatched(target.Line(), record, "missing key column")
+		}
+
+		switch {
+		case opts.Type == JoinAnti:
+			if len(matches) == 0 {
+				outputRows++
+				err = write(record, nil)
+			}
+		case len(matches) == 0:
+			if opts.Type != JoinInner {
+				outputRows++
+				err = write(record, make([]string, len(opts.Columns)))
+			}
+		default:
+			for _, match := range matches {
+				outputRows++
+				if err = write(record, match.values); err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	rep.Count("outputRows", outputRows)
+
+	return out.Flush()
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+func openCSV(t *testing.T, content string) table.Reader {
+	t.Helper()
+	r, err := table.Open(strings.NewReader(content), "file.csv", table.Options{Delimiter: ';'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+const (
+	joinSource = "code;weight;note\n" +
+		"A1;1.5;first\n" +
+		"b-2;2;\n" +
+		"a1;1.6;second\n" +
+		"\n" +
+		"C3;3;\n"
+	joinTarget = "code;price\n" +
+		"A1;10\n" +
+		"X9;5\n" +
+		"B-2;7\n" +
+		"\n" +
+		"A1;11\n"
+)
+
+func TestJoin(t *testing.T) {
+	tests := []struct {
+		name       string
+		joinType   string
+		duplicates string
+		want       [][]string
+	}{
+		{"left keeps the first", "", "", [][]string{
+			{"code", "price", "kg"},
+			{"A1", "10", "1.5"},
+			{"X9", "5", ""},
+			{"B-2", "7", "2"},
+			{"A1", "11", "1.5"},
+		}},
+		{"inner keeps the first", JoinInner, DuplicatesFirst, [][]string{
+			{"code", "price", "kg"},
+			{"A1", "10", "1.5"},
+			{"B-2", "7", "2"},
+			{"A1", "11", "1.5"},
+		}},
+		{"inner keeps the last", JoinInner, DuplicatesLast, [][]string{
+			{"code", "price", "kg"},
+			{"A1", "10", "1.6"},
+			{"B-2", "7", "2"},
+			{"A1", "11", "1.6"},
+		}},
+		{"inner repeats rows for all", JoinInner, DuplicatesAll, [][]string{
+			{"code", "price", "kg"},
+			{"A1", "10", "1.5"},
+			{"A1", "10", "1.6"},
+			{"B-2", "7", "2"},
+			{"A1", "11", "1.5"},
+			{"A1", "11", "1.6"},
+		}},
+		{"anti", JoinAnti, DuplicatesAll, [][]string{
+			{"code", "price"},
+			{"X9", "5"},
+		}},
+	}
+	for _, tt := range tests {
+		var out rows
+		rep := report.New()
+		opts := JoinOptions{SourceKey: 0, TargetKey: 0, Columns: []int{1}, Headers: []string{"kg"}, Type: tt.joinType, Duplicates: tt.duplicates}
+		s := NewFileService(nil)
+		err := s.Join(context.Background(), openCSV(t, joinSource), openCSV(t, joinTarget), opts, &out, rep.File("source", ';'), rep.File("target", ';'), rep)
+		if err != nil {
+			t.Errorf("%s: Join() error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual([][]string(out), tt.want) {
+			t.Errorf("%s: Join() =\n%v\nwant\n%v", tt.name, out, tt.want)
+		}
+		if rep.Matched != 3 {
+			t.Errorf("%s: %d matched rows, want 3", tt.name, rep.Matched)
+		}
+	}
+}
+
+func TestJoinDuplicatesError(t *testing.T) {
+	var out rows
+	opts := JoinOptions{Columns: []int{1}, Type: JoinInner, Duplicates: DuplicatesError}
+	err := NewFileService(nil).Join(context.Background(), openCSV(t, joinSource), openCSV(t, joinTarget), opts, &out, nil, nil, nil)
+
+	var duplicate *DuplicateKeyError
+	if !errors.As(err, &duplicate) {
+		t.Fatalf("Join() error = %v, want a DuplicateKeyError", err)
+	}
+	if duplicate.Key != "a1" || !reflect.DeepEqual(duplicate.Lines, []int{2, 4}) {
+		t.Errorf("DuplicateKeyError = %q on lines %v, want \"a1\" on lines [2 4]", duplicate.Key, duplicate.Lines)
+	}
+	if len(out) != 0 {
+		t.Errorf("Join() wrote %v before the error", out)
+	}
+}
+
+func TestJoinPadsShortRows(t *testing.T) {
+	var out rows
+	target := "code;price;brand\nA1;10\n"
+	opts := JoinOptions{Columns: []int{1, 2}}
+	if err := NewFileService(nil).Join(context.Background(), openCSV(t, joinSource), openCSV(t, target), opts, &out, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][]string{{"code", "price", "brand", "weight", "note"}, {"A1", "10", "", "1.5", "first"}}
+	if !reflect.DeepEqual([][]string(out), want) {
+		t.Errorf("Join() = %v, want %v", out, want)
+	}
+}
+
+func TestValidJoin(t *testing.T) {
+	tests := []struct {
+		joinType, duplicates string
+		valid                bool
+	}{
+		{"", "", true},
+		{JoinLeft, DuplicatesFirst, true},
+		{JoinAnti, DuplicatesAll, true},
+		{"outer", "", false},
+		{JoinInner, "sum", false},
+	}
+	for _, tt := range tests {
+		if err := ValidJoin(tt.joinType, tt.duplicates); (err == nil) != tt.valid {
+			t.Errorf("ValidJoin(%q, %q) = %v, want valid %v", tt.joinType, tt.duplicates, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/trunov/virena/internal/app/markup"
+	"github.com/trunov/virena/internal/app/partcode"
+	"github.com/trunov/virena/internal/app/report"
+	"github.com/trunov/virena/internal/app/table"
+)
+
+// PriceUpdate describes a run of the price tool. The indexes are 0-based,
+// DealerColumn and BrandColumn are -1 when not given.
+type PriceUpdate struct {
+	PriceIndex   int
+	CodeIndex    int
+	DealerColumn int
+	// WithAdditionalData copies the worst price columns of an earlier
+	// dealer comparison, the last three of the price file.
+	WithAdditionalData bool
+
+	ProductCodeIndex int
+	// BrandColumn picks the markup tiers and part code rules of a product.
+	BrandColumn int
+	Markup      markup.Policy
+}
+
+type priceInfo struct {
+	// Code as written in the price file and its line there.
+	Code              string
+	Line              int
+	Price             string
+	Dealer            string
+	WorstPrice        string
+	WorstDealerNumber string
+	PriceRatio        string
+}
+
+// UpdatePrices prices the products by the price file. Only the price file
+// is held in memory, the products are streamed to out with the new price
+// after the column at PriceIndex, then the dealer and worst price columns
+// when asked for. Unmatched products are noted in rep by their line in the
+// result.
+func (s *fileServiceImpl) UpdatePrices(ctx context.Context, prices, products table.Reader, u PriceUpdate, out table.Writer, priceRep, productRep *report.File, rep *report.Report) error {
+	pricesMap, err := s.readPriceInfo(prices, u, priceRep)
+	if err != nil {
+		return err
+	}
+	index := &priceIndex{codes: s.codes, byKey: pricesMap, brands: make(map[string]map[string]string)}
+
+	for line := 1; ; {
+		record, err := products.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return err
+		}
+
+		if line == 1 {
+			record = insertAfter(record, u.PriceIndex, "new price")
+			if u.DealerColumn >= 0 {
+				record = append(record, "dealer")
+			}
+			if u.WithAdditionalData {
+				record = append(record, "Worst Price", "Worst Dealer Number", "Price Ratio")
+			}
+
+			if err := out.Write(record); err != nil {
+				return err
+			}
+			line++
+			continue
+		}
+
+		productRep.Read()
+		if err != nil {
+			productRep.AddMalformed(products.Line(), record, err.Error())
+			continue
+		}
+
+		var brand string
+		if u.BrandColumn >= 0 && u.BrandColumn < len(record) {
+			brand = record[u.BrandColumn]
+		}
+
+		// rows without a code get no price instead of failing the file
+		var productCode string
+		if u.ProductCodeIndex < len(record) {
+			productCode = record[u.ProductCodeIndex]
+		}
+
+		productCode, info, ok := index.lookup(brand, productCode)
+
+		var newPriceStr string
+		if ok {
+			cleanedPrice := strings.ReplaceAll(info.Price, " ", "")
+			cleanedPrice = strings.ReplaceAll(cleanedPrice, ",", ".")
+
+			if price, err := strconv.ParseFloat(cleanedPrice, 64); err == nil {
+				newPriceStr = u.Markup.Price(price, brand)
+				rep.AddMatched()
+			} else {
+				newPriceStr = "N/A"
+				rep.AddUnmatched(line, record, "price is not a number")
+			}
+		} else {
+			newPriceStr = "N/A"
+			if productCode == "" {
+				rep.AddUnmatched(line, record, "no part code")
+			} else {
+				rep.AddUnmatched(line, record, "code not in the price file")
+			}
+		}
+
+		record = insertAfter(record, u.PriceIndex, newPriceStr)
+		if u.DealerColumn >= 0 {
+			record = append(record, info.Dealer)
+		}
+		if u.WithAdditionalData {
+			record = append(record, info.WorstPrice, info.WorstDealerNumber, info.PriceRatio)
+		}
+
+		if err := out.Write(record); err != nil {
+			return err
+		}
+		line++
+	}
+
+	return out.Flush()
+}
+
+// readPriceInfo keys the rows of the price file by part code, a code listed
+// again replaces the earlier row.
+func (s *fileServiceImpl) readPriceInfo(prices table.Reader, u PriceUpdate, rep *report.File) (map[string]priceInfo, error) {
+	pricesMap := make(map[string]priceInfo)
+
+	for header := true; ; header = false {
+		record, err := prices.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil && !table.IsRowError(err) {
+			return nil, err
+		}
+		if header || (err == nil && blank(record)) {
+			continue
+		}
+
+		rep.Read()
+		if err != nil {
+			rep.AddMalformed(prices.Line(), record, err.Error())
+			continue
+		}
+
+		recordLength := len(record)
+		if recordLength <= u.CodeIndex || recordLength <= u.PriceIndex {
+			rep.AddMalformed(prices.Line(), record, "missing price or code column")
+			continue
+		}
+
+		partCode := s.codes.Key("", record[u.CodeIndex])
+		if partCode == "" {
+			rep.AddMalformed(prices.Line(), record, "no part code")
+			continue
+		}
+		partPrice := record[u.PriceIndex]
+
+		switch price, err := ParsePrice(partPrice); {
+		case err != nil:
+			rep.AddInvalidPrice(prices.Line(), record, "price is not a number")
+		case price == 0:
+			rep.AddInvalidPrice(prices.Line(), record, "zero price")
+		default:
+			rep.AddCode(partCode, record[u.CodeIndex], prices.Line(), price, partPrice)
+		}
+
+		info := priceInfo{Code: record[u.CodeIndex], Line: prices.Line(), Price: partPrice}
+		if u.DealerColumn >= 0 && recordLength > u.DealerColumn {
+			info.Dealer = record[u.DealerColumn]
+		}
+		if u.WithAdditionalData && recordLength >= 3 {
+			info.WorstPrice = record[recordLength-3]
+			info.WorstDealerNumber = record[recordLength-2]
+			info.PriceRatio = record[recordLength-1]
+		}
+
+		pricesMap[partCode] = info
+	}
+
+	return pricesMap, nil
+}
+
+// priceIndex finds the price file row of a product code. The rows are keyed
+// by the default rules, products of a brand with rules of its own are looked
+// up by that brand's keys, indexed the first time the brand is seen.
+type priceIndex struct {
+	codes  *partcode.Normalizer
+	byKey  map[string]priceInfo
+	brands map[string]map[string]string
+}
+
+// lookup returns the key of the code by the rules of its brand and the row
+// found under it.
+func (p *priceIndex) lookup(brand, code string) (string, priceInfo, bool) {
+	brand = p.codes.Brand(brand)
+	key := p.codes.Key(brand, code)
+	if brand == "" {
+		info, ok := p.byKey[key]
+		return key, info, ok
+	}
+
+	keys, ok := p.brands[brand]
+	if !ok {
+		keys = make(map[string]string, len(p.byKey))
+		for k, info := range p.byKey {
+			// as by the default rules, a code listed again replaces the
+			// earlier row
+			brandKey := p.codes.Key(brand, info.Code)
+			if prev, dup := keys[brandKey]; dup && p.byKey[prev].Line > info.Line {
+				continue
+			}
+			keys[brandKey] = k
+		}
+		p.brands[brand] = keys
+	}
+
+	k, ok := keys[key]
+	if !ok {
+		return key, priceInfo{}, false
+	}
+	return key, p.byKey[k], true
+}
+
+// insertAfter puts value after the column at index, rows too short for it
+// are padded first.
+func insertAfter(record []string, index int, value string) []string {
+	for len(record) <= index {
+		record = append(record, "")
+	}
+	if index == len(record)-1 {
+		return append(record, value)
+	}
+	return append(record[:index+1], append([]string{value}, record[index+1:]...)...)
+}
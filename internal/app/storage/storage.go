@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Local keeps files in a directory on the pod's volume. Files are addressed
+// by keys generated on save, callers never choose paths themselves.
+type Local struct {
+	dir string
+}
+
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+// Save copies r into a new file under the given prefix, e.g. "tickets", and
+// returns its key and size.
+func (l *Local) Save(prefix string, r io.Reader) (string, int64, error) {
+	key, f, err := l.Create(prefix)
+	if err != nil {
+		return "", 0, err
+	}
+
+	size, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		l.Remove(key)
+		return "", 0, err
+	}
+
+	return key, size, nil
+}
+
+// Create opens a new file under the given prefix for writing, for output
+// that is written bit by bit. The caller closes it, and removes the key when
+// writing failed.
+func (l *Local) Create(prefix string) (string, *os.File, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, err
+	}
+
+	key := path.Join(prefix, time.Now().Format("2006/01"), hex.EncodeToString(random))
+
+	fullPath, err := l.path(key)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return "", nil, err
+	}
+
+	f, err := os.OpenFile(fullPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return key, f, nil
+}
+
+func (l *Local) Open(key string) (*os.File, error) {
+	fullPath, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(fullPath)
+}
+
+func (l *Local) Remove(key string) error {
+	fullPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fullPath)
+}
+
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean(key)
+	if clean == "." || path.IsAbs(clean) || strings.HasPrefix(clean, "..") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,64 @@
+package table
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+func newCSVReader(r io.Reader, delimiter rune) *csv.Reader {
+	reader := csv.NewReader(r)
+	if delimiter != 0 {
+		reader.Comma = delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	return reader
+}
+
+// IsRowError reports whether err only spoils the row read last, such as a
+// stray quote in a CSV line; reading can go on with the next row.
+func IsRowError(err error) bool {
+	var parseErr *csv.ParseError
+	return errors.As(err, &parseErr)
+}
+
+type csvReader struct {
+	*csv.Reader
+	line int
+}
+
+func (r *csvReader) Read() ([]string, error) {
+	record, err := r.Reader.Read()
+
+	var parseErr *csv.ParseError
+	switch {
+	case err == nil:
+		r.line, _ = r.FieldPos(0)
+	case errors.As(err, &parseErr):
+		r.line = parseErr.StartLine
+	}
+
+	return record, err
+}
+
+func (r *csvReader) Line() int {
+	return r.line
+}
+
+type csvWriter struct {
+	*csv.Writer
+}
+
+func newCSVWriter(w io.Writer, delimiter rune) *csvWriter {
+	writer := csv.NewWriter(w)
+	if delimiter != 0 {
+		writer.Comma = delimiter
+	}
+	return &csvWriter{Writer: writer}
+}
+
+func (w *csvWriter) Flush() error {
+	w.Writer.Flush()
+	return w.Writer.Error()
+}
@@ -0,0 +1,171 @@
+package table
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	EncodingAuto        = "auto"
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1257 = "windows-1257"
+	EncodingWindows1252 = "windows-1252"
+)
+
+var encodings = map[string]encoding.Encoding{
+	EncodingUTF8:        unicode.UTF8,
+	EncodingUTF16LE:     unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	EncodingUTF16BE:     unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	EncodingWindows1257: charmap.Windows1257,
+	EncodingWindows1252: charmap.Windows1252,
+	"iso-8859-1":        charmap.ISO8859_1,
+	"iso-8859-13":       charmap.ISO8859_13,
+	"iso-8859-15":       charmap.ISO8859_15,
+}
+
+var encodingAliases = map[string]string{
+	"utf8":   EncodingUTF8,
+	"utf-16": EncodingUTF16LE,
+	"cp1257": EncodingWindows1257,
+	"cp1252": EncodingWindows1252,
+	"latin1": "iso-8859-1",
+	"baltic": EncodingWindows1257,
+}
+
+// ValidEncoding reports whether name is auto or an encoding we can decode.
+func ValidEncoding(name string) bool {
+	name = normalizeEncoding(name)
+	_, ok := encodings[name]
+	return ok || name == EncodingAuto
+}
+
+func normalizeEncoding(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return EncodingAuto
+	}
+	if alias, ok := encodingAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+const encodingSampleSize = 64 << 10
+
+// DetectEncoding guesses the encoding of a sample from the start of a file.
+// A BOM wins, then valid UTF-8, then UTF-16 by its zero bytes. Legacy files
+// are told apart by the bytes of Baltic letters that differ between
+// Windows-1257 and Windows-1252; legacy is used when nothing decides.
+func DetectEncoding(sample []byte, truncated bool, legacy string) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE
+	}
+
+	if enc := detectUTF16(sample); enc != "" {
+		return enc
+	}
+
+	valid := sample
+	// the sample may end in the middle of a character
+	for i := 0; truncated && i < utf8.UTFMax-1 && len(valid) > 0 && !utf8.Valid(valid); i++ {
+		valid = valid[:len(valid)-1]
+	}
+	if utf8.Valid(valid) {
+		return EncodingUTF8
+	}
+
+	var baltic, western int
+	for _, b := range sample {
+		switch b {
+		// š ž Š Ž and ą č ę ė į ų ū ļ ņ ķ ģ in Windows-1257
+		case 0xF0, 0xFE, 0xD0, 0xDE, 0xE0, 0xE8, 0xE6, 0xEB, 0xE1, 0xF8, 0xFB, 0xEF, 0xF2, 0xED, 0xEC:
+			baltic++
+		// š ž Š Ž in Windows-1252
+		case 0x9A, 0x9E, 0x8A, 0x8E:
+			western++
+		}
+	}
+
+	legacy = normalizeEncoding(legacy)
+	if _, ok := encodings[legacy]; !ok || legacy == EncodingUTF8 {
+		legacy = EncodingWindows1257
+	}
+
+	switch {
+	case western > baltic:
+		return EncodingWindows1252
+	case baltic > western:
+		return EncodingWindows1257
+	default:
+		return legacy
+	}
+}
+
+// detectUTF16 spots BOM-less UTF-16 by ASCII text having a zero byte in
+// every other position.
+func detectUTF16(sample []byte) string {
+	if len(sample) < 4 {
+		return ""
+	}
+
+	var even, odd int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+
+	half := len(sample) / 2
+	switch {
+	case odd > half*3/10 && even < half/10:
+		return EncodingUTF16LE
+	case even > half*3/10 && odd < half/10:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+// decode returns a UTF-8 reader of file. The name is auto or empty to detect
+// the encoding; a BOM always overrides it and is dropped.
+func decode(file io.ReadSeeker, name, legacy string) (io.Reader, string, error) {
+	name = normalizeEncoding(name)
+
+	if name == EncodingAuto {
+		sample := make([]byte, encodingSampleSize)
+		n, err := io.ReadFull(file, sample)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return nil, "", err
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, "", err
+		}
+		name = DetectEncoding(sample[:n], n == len(sample), legacy)
+	}
+
+	enc, ok := encodings[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported encoding %q", name)
+	}
+
+	return transform.NewReader(file, unicode.BOMOverride(enc.NewDecoder())), name, nil
+}
@@ -0,0 +1,100 @@
+package table
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func encode(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	b, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	const text = "kood;hind;kirjeldus\nA1;10,50;Õlifilter\nB2;7,00;Šarniir\n"
+	le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	be := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+
+	tests := []struct {
+		name   string
+		sample []byte
+		legacy string
+		want   string
+	}{
+		{"utf-8", []byte(text), "", EncodingUTF8},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, text...), "", EncodingUTF8},
+		{"utf-16le bom", append([]byte{0xFF, 0xFE}, encode(t, le, text)...), "", EncodingUTF16LE},
+		{"utf-16be bom", append([]byte{0xFE, 0xFF}, encode(t, be, text)...), "", EncodingUTF16BE},
+		{"utf-16le without bom", encode(t, le, text), "", EncodingUTF16LE},
+		{"utf-16be without bom", encode(t, be, text), "", EncodingUTF16BE},
+		{"windows-1257", encode(t, charmap.Windows1257, "kood;nimi\nA1;Žiguli ūks ąčę\n"), "", EncodingWindows1257},
+		{"windows-1252", encode(t, charmap.Windows1252, "kood;nimi\nA1;Šarniir\nB2;Žalusii\n"), "", EncodingWindows1252},
+		{"legacy fallback", encode(t, charmap.Windows1252, "kood;nimi\nA1;Ölfilter\n"), "iso-8859-15", "iso-8859-15"},
+		{"unknown legacy falls back to 1257", encode(t, charmap.Windows1252, "kood;nimi\nA1;Ölfilter\n"), "ebcdic", EncodingWindows1257},
+	}
+	for _, tt := range tests {
+		if got := DetectEncoding(tt.sample, false, tt.legacy); got != tt.want {
+			t.Errorf("%s: DetectEncoding() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectEncodingTruncated(t *testing.T) {
+	// the sample ends in the middle of "Õ"
+	sample := []byte("kood;nimi\nA1;Õ")
+	sample = sample[:len(sample)-1]
+
+	if got := DetectEncoding(sample, true, ""); got != EncodingUTF8 {
+		t.Errorf("DetectEncoding(truncated) = %q, want %q", got, EncodingUTF8)
+	}
+	if got := DetectEncoding(sample, false, ""); got == EncodingUTF8 {
+		t.Errorf("DetectEncoding(complete) = %q, want a legacy encoding", got)
+	}
+}
+
+func TestOpenDecodes(t *testing.T) {
+	const text = "kood;nimi\nA1;Šarniir\n"
+	tests := []struct {
+		name string
+		file []byte
+		want string
+	}{
+		{"utf-16le", append([]byte{0xFF, 0xFE}, encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), text)...), EncodingUTF16LE},
+		{"windows-1257", encode(t, charmap.Windows1257, text), EncodingWindows1257},
+	}
+	for _, tt := range tests {
+		file := bytes.NewReader(tt.file)
+		info, err := Detect(file, "prices.csv", Options{})
+		if err != nil {
+			t.Fatalf("%s: Detect() error = %v", tt.name, err)
+		}
+		if info.Encoding != tt.want || info.Delimiter != ';' {
+			t.Errorf("%s: Detect() = %s, want encoding %s", tt.name, info, tt.want)
+		}
+
+		reader, err := Open(file, "prices.csv", info.Options(Options{}))
+		if err != nil {
+			t.Fatalf("%s: Open() error = %v", tt.name, err)
+		}
+		var rows []string
+		for {
+			row, err := reader.Read()
+			if err != nil {
+				break
+			}
+			rows = append(rows, strings.Join(row, ";"))
+		}
+		if got := strings.Join(rows, "\n") + "\n"; got != text {
+			t.Errorf("%s: rows = %q, want %q", tt.name, got, text)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package table
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Delimiters we try when sniffing, in order of preference on a tie.
+var Delimiters = []rune{';', ',', '\t', '|'}
+
+const sniffRows = 50
+
+// Info describes how a file is read: what was given and what was detected.
+type Info struct {
+	Format    string
+	Encoding  string
+	Delimiter rune
+	Quoted    bool
+	// HeaderRow is the 0-based row of the header, rows above it are titles
+	// or notes and are skipped.
+	HeaderRow int
+	// Sniffed is set when the delimiter, and the header row unless it was
+	// given, were detected.
+	Sniffed bool
+}
+
+// Options turns the detected settings into options for Open, so the file
+// is not sniffed again on every read.
+func (i Info) Options(opts Options) Options {
+	opts.Format = i.Format
+	opts.SkipRows = i.HeaderRow
+	opts.FixedHeader = true
+	if i.Format == FormatCSV {
+		opts.Encoding = i.Encoding
+		opts.Delimiter = i.Delimiter
+	}
+	return opts
+}
+
+// String is the detected dialect as echoed to the caller, e.g.
+// "format=csv; encoding=utf-8; delimiter=semicolon; quote=double; header-row=1".
+func (i Info) String() string {
+	if i.Format != FormatCSV {
+		return "format=" + i.Format
+	}
+
+	quote := "none"
+	if i.Quoted {
+		quote = "double"
+	}
+
+	return "format=csv; encoding=" + i.Encoding +
+		"; delimiter=" + DelimiterName(i.Delimiter) +
+		"; quote=" + quote +
+		"; header-row=" + strconv.Itoa(i.HeaderRow+1)
+}
+
+// DelimiterName spells out delimiters that would be confusing in a header.
+func DelimiterName(delimiter rune) string {
+	switch delimiter {
+	case ',':
+		return "comma"
+	case ';':
+		return "semicolon"
+	case '\t':
+		return "tab"
+	case '|':
+		return "pipe"
+	default:
+		return string(delimiter)
+	}
+}
+
+// Detect works out the format and, for CSV files, the encoding. The
+// delimiter and quoting are sniffed from a sample when opts.Delimiter is
+// zero, the header row as well unless opts.FixedHeader is set.
+func Detect(file io.ReadSeeker, filename string, opts Options) (Info, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Format: opts.Format, Delimiter: opts.Delimiter, HeaderRow: opts.SkipRows}
+	if info.Format == "" {
+		var err error
+		info.Format, err = DetectFormat(file, filename)
+		if err != nil {
+			return Info{}, err
+		}
+	}
+	if info.Format != FormatCSV {
+		return info, nil
+	}
+
+	r, encoding, err := decode(file, opts.Encoding, opts.LegacyEncoding)
+	if err != nil {
+		return Info{}, err
+	}
+	info.Encoding = encoding
+
+	sample := make([]byte, encodingSampleSize)
+	n, err := io.ReadFull(r, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Info{}, err
+	}
+	sample = sample[:n]
+	if n == encodingSampleSize {
+		// drop the line cut in half by the sample size
+		if i := bytes.LastIndexByte(sample, '\n'); i > 0 {
+			sample = sample[:i+1]
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+
+	if info.Delimiter == 0 {
+		info.Delimiter = SniffDelimiter(sample)
+		if !opts.FixedHeader {
+			info.HeaderRow = headerRow(sampleRows(sample, info.Delimiter))
+		}
+		info.Sniffed = true
+	}
+	info.Quoted = quoted(sample, info.Delimiter)
+
+	return info, nil
+}
+
+// SniffDelimiter picks the delimiter that splits the sample rows into the
+// same number of fields most consistently, preferring more fields.
+func SniffDelimiter(sample []byte) rune {
+	best := Delimiters[0]
+	var bestConsistency float64
+	var bestFields int
+
+	for _, delimiter := range Delimiters {
+		rows := sampleRows(sample, delimiter)
+		if len(rows) == 0 {
+			continue
+		}
+
+		fields, count := modeFields(rows)
+		if fields < 2 {
+			continue
+		}
+
+		consistency := float64(count) / float64(len(rows))
+		if consistency > bestConsistency || (consistency == bestConsistency && fields > bestFields) {
+			best, bestConsistency, bestFields = delimiter, consistency, fields
+		}
+	}
+
+	return best
+}
+
+func sampleRows(sample []byte, delimiter rune) [][]string {
+	reader := newCSVReader(bytes.NewReader(sample), delimiter)
+
+	var rows [][]string
+	for len(rows) < sniffRows {
+		row, err := reader.Read()
+		if err != nil {
+			break
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// modeFields returns the most common field count and how many rows have it.
+func modeFields(rows [][]string) (int, int) {
+	counts := make(map[int]int)
+	var mode, modeCount int
+	for _, row := range rows {
+		counts[len(row)]++
+		if c := counts[len(row)]; c > modeCount || (c == modeCount && len(row) > mode) {
+			mode, modeCount = len(row), c
+		}
+	}
+	return mode, modeCount
+}
+
+// headerRow finds the first row that looks like column names, text only,
+// followed by a row with numbers in it. Title rows above it are skipped;
+// without such a row the first row is taken as usual.
+func headerRow(rows [][]string) int {
+	mode, _ := modeFields(rows)
+	limit := len(rows) - 1
+	if limit > 10 {
+		limit = 10
+	}
+
+	for i := 0; i < limit; i++ {
+		if len(rows[i]) < mode || !textOnly(rows[i]) {
+			continue
+		}
+		if hasNumber(rows[i+1]) {
+			return i
+		}
+	}
+
+	return 0
+}
+
+func textOnly(row []string) bool {
+	var filled int
+	for _, cell := range row {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if isNumber(cell) {
+			return false
+		}
+		filled++
+	}
+	return filled*2 >= len(row)
+}
+
+func hasNumber(row []string) bool {
+	for _, cell := range row {
+		if isNumber(strings.TrimSpace(cell)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, " ", ""), "\u00a0", "")
+	_, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	return s != "" && err == nil
+}
+
+// quoted reports whether fields in the sample are wrapped in double quotes.
+func quoted(sample []byte, delimiter rune) bool {
+	d := string(delimiter)
+	text := string(sample)
+	return strings.HasPrefix(text, `"`) ||
+		strings.Contains(text, d+`"`) ||
+		strings.Contains(text, "\n\"")
+}
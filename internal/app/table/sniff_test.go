@@ -0,0 +1,95 @@
+package table
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{"semicolon", "code;price\nA1;10,50\nB2;7,00\n", ';'},
+		{"comma", "code,price,brand\nA1,10.50,MB\nB2,7.00,VLV\n", ','},
+		{"tab", "code\tprice\nA1\t10,50\nB2\t7,00\n", '\t'},
+		{"pipe", "code|price\nA1|10.50\nB2|7.00\n", '|'},
+		{"decimal commas in semicolon rows", "code;price;qty\nA1;10,50;1\nB2;7,25;2\n", ';'},
+		{"quoted fields with commas", "code;name\nA1;\"Filter, oil\"\nB2;\"Pump, water\"\n", ';'},
+		{"consistent rows win over more fields", "a,b;c\nd;e\nf;g\n", ';'},
+		{"single column", "A1\nB2\n", ';'},
+	}
+	for _, tt := range tests {
+		if got := SniffDelimiter([]byte(tt.sample)); got != tt.want {
+			t.Errorf("%s: SniffDelimiter(%q) = %q, want %q", tt.name, tt.sample, got, tt.want)
+		}
+	}
+}
+
+func TestDetectHeaderRow(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		opts      Options
+		want      int
+		delimiter rune
+	}{
+		{"header first", "code;price\nA1;10,50\nB2;7,00\n", Options{}, 0, ';'},
+		{"title rows", "Price list;\nValid from October;\ncode;price\nA1;10,50\nB2;7,00\n", Options{}, 2, ';'},
+		{"no header", "A1;10,50\nB2;7,00\n", Options{}, 0, ';'},
+		{"numbers in the title", "Price list 2026,,\ncode,name,price\nA1,Filter,10.50\n", Options{}, 1, ','},
+		{"fixed header row", "Price list;\ncode;price\nA1;10,50\n", Options{SkipRows: 0, FixedHeader: true}, 0, ';'},
+		{"given delimiter is not sniffed", "Price list;\ncode;price\nA1;10,50\n", Options{Delimiter: ';'}, 0, ';'},
+	}
+	for _, tt := range tests {
+		info, err := Detect(strings.NewReader(tt.file), "prices.csv", tt.opts)
+		if err != nil {
+			t.Errorf("%s: Detect() error = %v", tt.name, err)
+			continue
+		}
+		if info.HeaderRow != tt.want || info.Delimiter != tt.delimiter {
+			t.Errorf("%s: Detect() = header row %d delimiter %q, want %d %q", tt.name, info.HeaderRow, info.Delimiter, tt.want, tt.delimiter)
+		}
+	}
+}
+
+func TestDetectedRows(t *testing.T) {
+	file := strings.NewReader("Price list;\n\ncode;price\nA1;10,50\n")
+	info, err := Detect(file, "prices.csv", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := Open(file, "prices.csv", info.Options(Options{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := reader.Read()
+	if err != nil || strings.Join(header, "|") != "code|price" {
+		t.Errorf("first row = %q, %v, want the header", header, err)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    rune
+		wantErr bool
+	}{
+		{"", 0, false},
+		{";", ';', false},
+		{"tab", '\t', false},
+		{`\t`, '\t', false},
+		{"§", '§', false},
+		{";;", 0, true},
+		{`"`, 0, true},
+		{"\n", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDelimiter(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseDelimiter(%q) = %q, %v, want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
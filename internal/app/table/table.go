@@ -0,0 +1,203 @@
+package table
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatXLS  = "xls"
+)
+
+var ErrUnknownFormat = errors.New("unknown table format")
+
+// Reader returns one row at a time and io.EOF after the last one. Line is
+// the 1-based line (CSV) or row (workbook) of the row read last, also when
+// it could not be read.
+type Reader interface {
+	Read() ([]string, error)
+	Line() int
+}
+
+// Writer takes rows and writes them out on Flush at the latest.
+type Writer interface {
+	Write(record []string) error
+	Flush() error
+}
+
+type Options struct {
+	// Format is detected from the content and the file name when empty.
+	Format string
+	// Delimiter of CSV files, comma when zero.
+	Delimiter rune
+	// Sheet of a workbook, by name or 1-based number. The first sheet is
+	// used when empty.
+	Sheet string
+	// Encoding of CSV files, detected when empty or "auto". LegacyEncoding
+	// is assumed for non-UTF-8 files the detection cannot decide on.
+	Encoding       string
+	LegacyEncoding string
+	// SkipRows are dropped from the start of the file or sheet, titles
+	// above the header row for example. Detect sniffs the header row of CSV
+	// files in place of SkipRows unless FixedHeader is set.
+	SkipRows    int
+	FixedHeader bool
+}
+
+// WriterOptions describe the output. Text is always UTF-8, BOM prepends the
+// byte order mark Excel needs to recognize it. In workbooks, cells of the
+// numeric columns, picked by header name, are stored as numbers.
+type WriterOptions struct {
+	Format         string
+	Delimiter      rune
+	BOM            bool
+	NumericColumns []string
+}
+
+var (
+	zipMagic = []byte("PK\x03\x04")
+	oleMagic = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+)
+
+// DetectFormat looks at the first bytes of the file and falls back to the
+// extension. Anything that is not a workbook is read as CSV.
+func DetectFormat(file io.ReadSeeker, filename string) (string, error) {
+	head := make([]byte, 8)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return FormatXLSX, nil
+	case bytes.HasPrefix(head, oleMagic):
+		return FormatXLS, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".xlsx":
+		return FormatXLSX, nil
+	case ".xls":
+		return FormatXLS, nil
+	}
+
+	return FormatCSV, nil
+}
+
+// ParseDelimiter reads a delimiter as given by the user: a single character
+// or "tab". Zero means the delimiter is sniffed from the file.
+func ParseDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	delimiter := []rune(value)
+	if len(delimiter) != 1 || delimiter[0] == '"' || delimiter[0] == '\r' || delimiter[0] == '\n' {
+		return 0, fmt.Errorf("invalid delimiter %q", value)
+	}
+	return delimiter[0], nil
+}
+
+// Open reads a CSV file or a sheet of an .xlsx/.xls workbook from the start.
+func Open(file io.ReadSeeker, filename string, opts Options) (Reader, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	format := opts.Format
+	if format == "" {
+		var err error
+		format, err = DetectFormat(file, filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case FormatCSV:
+		r, _, err := decode(file, opts.Encoding, opts.LegacyEncoding)
+		if err != nil {
+			return nil, err
+		}
+		return skipRows(&csvReader{Reader: newCSVReader(r, opts.Delimiter)}, opts.SkipRows)
+	case FormatXLSX:
+		reader, err := openXLSX(file, opts.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		return skipRows(reader, opts.SkipRows)
+	case FormatXLS:
+		reader, err := openXLS(file, opts.Sheet)
+		if err != nil {
+			return nil, err
+		}
+		return skipRows(reader, opts.SkipRows)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+func skipRows(reader Reader, n int) (Reader, error) {
+	for i := 0; i < n; i++ {
+		if _, err := reader.Read(); err != nil {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+// NewWriter writes CSV or .xlsx.
+func NewWriter(w io.Writer, opts WriterOptions) (Writer, error) {
+	switch opts.Format {
+	case FormatCSV, "":
+		if opts.BOM {
+			if _, err := w.Write([]byte{0xEF, 0xBB, 0xBF}); err != nil {
+				return nil, err
+			}
+		}
+		return newCSVWriter(w, opts.Delimiter), nil
+	case FormatXLSX:
+		return newXLSXWriter(w, opts.NumericColumns)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, opts.Format)
+	}
+}
+
+// ContentType and Extension describe the output of NewWriter.
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv"
+}
+
+func Extension(format string) string {
+	if format == FormatXLSX {
+		return ".xlsx"
+	}
+	return ".csv"
+}
+
+// WriteAll writes all records and flushes.
+func WriteAll(w Writer, records [][]string) error {
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,85 @@
+package table
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/extrame/xls"
+)
+
+type xlsReader struct {
+	sheet *xls.WorkSheet
+	row   int
+}
+
+func openXLS(r io.ReadSeeker, sheet string) (reader Reader, err error) {
+	// the xls package panics on files it cannot parse
+	defer func() {
+		if p := recover(); p != nil {
+			reader, err = nil, fmt.Errorf("open xls: %v", p)
+		}
+	}()
+
+	book, err := xls.OpenReader(r, "utf-8")
+	if err != nil {
+		return nil, fmt.Errorf("open xls: %w", err)
+	}
+	if book == nil {
+		return nil, fmt.Errorf("open xls: no workbook found")
+	}
+
+	names := make([]string, book.NumSheets())
+	for i := range names {
+		names[i] = book.GetSheet(i).Name
+	}
+
+	name, err := sheetName(names, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range names {
+		if names[i] == name {
+			return &xlsReader{sheet: book.GetSheet(i)}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("sheet %q not found", sheet)
+}
+
+func (x *xlsReader) Read() ([]string, error) {
+	for x.row <= int(x.sheet.MaxRow) {
+		row := x.sheetRow(x.row)
+		x.row++
+
+		// rows without cells are not stored in the file
+		if row == nil {
+			return []string{}, nil
+		}
+
+		record := make([]string, row.LastCol())
+		for i := range record {
+			record[i] = row.Col(i)
+		}
+		return record, nil
+	}
+
+	return nil, io.EOF
+}
+
+// Line is the row read last: rows are numbered from 0 in the sheet and the
+// counter has moved past it.
+func (x *xlsReader) Line() int {
+	return x.row
+}
+
+// sheetRow returns nil for rows missing from the sheet, WorkSheet.Row panics
+// on them.
+func (x *xlsReader) sheetRow(i int) (row *xls.Row) {
+	defer func() {
+		if recover() != nil {
+			row = nil
+		}
+	}()
+	return x.sheet.Row(i)
+}
@@ -0,0 +1,190 @@
+package table
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type xlsxReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+	row  int
+}
+
+func openXLSX(r io.Reader, sheet string) (Reader, error) {
+	// raw values keep prices as plain numbers instead of "10,50 €"
+	file, err := excelize.OpenReader(r, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, fmt.Errorf("open xlsx: %w", err)
+	}
+
+	name, err := sheetName(file.GetSheetList(), sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	rows, err := file.Rows(name)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("read sheet %q: %w", name, err)
+	}
+
+	return &xlsxReader{file: file, rows: rows}, nil
+}
+
+func (x *xlsxReader) Read() ([]string, error) {
+	if !x.rows.Next() {
+		err := x.rows.Error()
+		x.rows.Close()
+		x.file.Close()
+		if err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	x.row++
+	return x.rows.Columns()
+}
+
+func (x *xlsxReader) Line() int {
+	return x.row
+}
+
+// sheetName picks a sheet by name or 1-based number.
+func sheetName(sheets []string, sheet string) (string, error) {
+	if len(sheets) == 0 {
+		return "", fmt.Errorf("workbook has no sheets")
+	}
+	if sheet == "" {
+		return sheets[0], nil
+	}
+
+	for _, name := range sheets {
+		if strings.EqualFold(name, sheet) {
+			return name, nil
+		}
+	}
+
+	if n, err := strconv.Atoi(sheet); err == nil && n >= 1 && n <= len(sheets) {
+		return sheets[n-1], nil
+	}
+
+	return "", fmt.Errorf("sheet %q not found, sheets: %s", sheet, strings.Join(sheets, ", "))
+}
+
+type xlsxWriter struct {
+	out     io.Writer
+	file    *excelize.File
+	stream  *excelize.StreamWriter
+	numeric map[string]bool
+	columns map[int]bool
+	row     int
+
+	headerStyle  int
+	percentStyle int
+}
+
+func newXLSXWriter(w io.Writer, numericColumns []string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return nil, err
+	}
+
+	headerStyle, err := file.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+		Border: []excelize.Border{
+			{Type: "bottom", Color: "000000", Style: 1},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	percentStyle, err := file.NewStyle(&excelize.Style{NumFmt: 10})
+	if err != nil {
+		return nil, err
+	}
+
+	numeric := make(map[string]bool, len(numericColumns))
+	for _, name := range numericColumns {
+		numeric[strings.ToLower(name)] = true
+	}
+
+	return &xlsxWriter{
+		out:          w,
+		file:         file,
+		stream:       stream,
+		numeric:      numeric,
+		columns:      make(map[int]bool),
+		headerStyle:  headerStyle,
+		percentStyle: percentStyle,
+	}, nil
+}
+
+// Write takes the first row as the header, it is styled and decides which
+// columns hold numbers.
+func (x *xlsxWriter) Write(record []string) error {
+	x.row++
+	cells := make([]interface{}, len(record))
+
+	for i, value := range record {
+		if x.row == 1 {
+			if x.numeric[strings.ToLower(strings.TrimSpace(value))] {
+				x.columns[i] = true
+			}
+			cells[i] = excelize.Cell{StyleID: x.headerStyle, Value: value}
+			continue
+		}
+
+		cells[i] = value
+		if x.columns[i] {
+			if cell, ok := x.number(value); ok {
+				cells[i] = cell
+			}
+		}
+	}
+
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, cells)
+}
+
+// number turns "12.5", "12,5" and "3.4%" into typed cells, anything else
+// such as "N/A" stays text.
+func (x *xlsxWriter) number(value string) (interface{}, bool) {
+	value = strings.TrimSpace(value)
+
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		f, err := strconv.ParseFloat(strings.Replace(percent, ",", ".", 1), 64)
+		if err != nil {
+			return nil, false
+		}
+		return excelize.Cell{StyleID: x.percentStyle, Value: f / 100}, true
+	}
+
+	f, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+func (x *xlsxWriter) Flush() error {
+	defer x.file.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := x.file.WriteTo(x.out)
+	return err
+}
@@ -0,0 +1,40 @@
+package ticket
+
+import "time"
+
+const (
+	StatusOpen     = "open"
+	StatusAnswered = "answered"
+	StatusClosed   = "closed"
+)
+
+func ValidStatus(status string) bool {
+	switch status {
+	case StatusOpen, StatusAnswered, StatusClosed:
+		return true
+	}
+	return false
+}
+
+// Ticket is a contact form submission kept for the support staff.
+type Ticket struct {
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Email       string       `json:"email"`
+	Subject     string       `json:"subject"`
+	Message     string       `json:"message"`
+	Status      string       `json:"status"`
+	Notes       string       `json:"notes"`
+	CreatedAt   time.Time    `json:"createdAt"`
+	UpdatedAt   time.Time    `json:"updatedAt"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a file uploaded with a ticket. StorageKey points into local storage.
+type Attachment struct {
+	ID          int    `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	StorageKey  string `json:"-"`
+}
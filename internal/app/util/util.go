@@ -40,3 +40,22 @@ func GetDealerNum(dealerNumber int) string {
 	}
 	return "2"
 }
+
+// VATRate is the Estonian standard VAT rate applied to order totals.
+const VATRate = 0.2
+
+// ReferenceNumber builds the Estonian payment reference number for an order
+// by appending a 7-3-1 check digit to the order ID.
+func ReferenceNumber(orderID int) string {
+	base := strconv.Itoa(orderID)
+	weights := []int{7, 3, 1}
+
+	var sum int
+	for i := 0; i < len(base); i++ {
+		digit := int(base[len(base)-1-i] - '0')
+		sum += digit * weights[i%3]
+	}
+
+	check := (10 - sum%10) % 10
+	return base + strconv.Itoa(check)
+}
@@ -0,0 +1,22 @@
+package util
+
+import "testing"
+
+func TestReferenceNumber(t *testing.T) {
+	tests := []struct {
+		orderID int
+		want    string
+	}{
+		{1, "13"},
+		{123456, "1234561"},
+		{12345, "123453"},
+		{10000, "100007"},
+		{99999, "999991"},
+		{55555, "555555"},
+	}
+	for _, tt := range tests {
+		if got := ReferenceNumber(tt.orderID); got != tt.want {
+			t.Errorf("ReferenceNumber(%d) = %q, want %q", tt.orderID, got, tt.want)
+		}
+	}
+}
@@ -20,6 +20,12 @@ func main() {
 			Msgf("Failed to read the config.")
 	}
 
+	if err := cfg.Validate(); err != nil {
+		l.Fatal().
+			Err(err).
+			Msgf("Config is not valid.")
+	}
+
 	dbStorage, dbpool, err := repo.CreateRepo(ctx, cfg)
 	if err != nil {
 		l.Fatal().
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders ADD COLUMN total DECIMAL(10, 2);
+ALTER TABLE orders ADD COLUMN paidDate TIMESTAMP;
+
+ALTER TABLE order_items ADD COLUMN price DECIMAL(10, 2);
+ALTER TABLE order_items ADD COLUMN amount DECIMAL(10, 2);
+
+CREATE TABLE payments (
+    id SERIAL PRIMARY KEY,
+    entry_ref VARCHAR(255) NOT NULL UNIQUE,
+    booking_date DATE NOT NULL,
+    amount DECIMAL(12, 2) NOT NULL,
+    currency VARCHAR(3) NOT NULL,
+    payer_name VARCHAR(255),
+    reference VARCHAR(35),
+    description TEXT,
+    source VARCHAR(10) NOT NULL,
+    order_id INTEGER REFERENCES orders(id),
+    matched_by VARCHAR(20),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE payments;
+ALTER TABLE order_items DROP COLUMN amount;
+ALTER TABLE order_items DROP COLUMN price;
+ALTER TABLE orders DROP COLUMN paidDate;
+ALTER TABLE orders DROP COLUMN total;
+-- +goose StatementEnd
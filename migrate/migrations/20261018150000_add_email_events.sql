@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE email_events (
+    id BIGSERIAL PRIMARY KEY,
+    outbox_id BIGINT,
+    order_id INTEGER,
+    event VARCHAR(32) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    sg_event_id VARCHAR(255) NOT NULL UNIQUE,
+    sg_message_id VARCHAR(255) NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX email_events_order_idx ON email_events (order_id, occurred_at);
+CREATE INDEX email_events_outbox_idx ON email_events (outbox_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE email_events;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE dealer_profiles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    delimiter VARCHAR(8) NOT NULL DEFAULT '',
+    encoding VARCHAR(32) NOT NULL DEFAULT '',
+    sheet VARCHAR(255) NOT NULL DEFAULT '',
+    price_column VARCHAR(255) NOT NULL,
+    code_column VARCHAR(255) NOT NULL,
+    dealer_column VARCHAR(255) NOT NULL DEFAULT '',
+    header_rows INTEGER NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    dealer_number VARCHAR(32) NOT NULL DEFAULT '',
+    decimal_separator VARCHAR(1) NOT NULL DEFAULT '',
+    strip_chars VARCHAR(32) NOT NULL DEFAULT '',
+    price_multiplier DOUBLE PRECISION NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE dealer_profiles;
+-- +goose StatementEnd
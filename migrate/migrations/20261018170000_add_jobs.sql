@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE jobs (
+    id VARCHAR(32) PRIMARY KEY,
+    tool VARCHAR(64) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'queued',
+    progress INTEGER NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    detected TEXT[] NOT NULL DEFAULT '{}',
+    content_type VARCHAR(255) NOT NULL,
+    input_key VARCHAR(255) NOT NULL,
+    result_key VARCHAR(255) NOT NULL DEFAULT '',
+    result_content_type VARCHAR(255) NOT NULL DEFAULT '',
+    result_filename VARCHAR(255) NOT NULL DEFAULT '',
+    lease_until TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX jobs_status_idx ON jobs (status, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE jobs;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE dealers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    number VARCHAR(32) NOT NULL DEFAULT '',
+    profile_id INTEGER REFERENCES dealer_profiles(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE dealer_price_snapshots (
+    id SERIAL PRIMARY KEY,
+    dealer_id INTEGER NOT NULL REFERENCES dealers(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    row_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (dealer_id, version)
+);
+
+CREATE TABLE dealer_prices (
+    snapshot_id INTEGER NOT NULL REFERENCES dealer_price_snapshots(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    code VARCHAR(255) NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    dealer VARCHAR(32) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX dealer_prices_snapshot_idx ON dealer_prices (snapshot_id, code);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE dealer_prices;
+DROP TABLE dealer_price_snapshots;
+DROP TABLE dealers;
+-- +goose StatementEnd
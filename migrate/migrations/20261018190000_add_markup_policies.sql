@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE markup_policies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    percentage DOUBLE PRECISION NOT NULL DEFAULT 0,
+    tiers JSONB NOT NULL DEFAULT '[]',
+    brands JSONB NOT NULL DEFAULT '{}',
+    min_margin DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rounding VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE markup_policies;
+-- +goose StatementEnd
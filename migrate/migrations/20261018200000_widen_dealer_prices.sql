@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE dealer_prices ALTER COLUMN code TYPE TEXT, ALTER COLUMN dealer TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE dealer_prices
+    ALTER COLUMN code TYPE VARCHAR(255) USING left(code, 255),
+    ALTER COLUMN dealer TYPE VARCHAR(32) USING left(dealer, 32);
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE tickets ALTER COLUMN name TYPE TEXT, ALTER COLUMN email TYPE TEXT, ALTER COLUMN subject TYPE TEXT;
+ALTER TABLE ticket_attachments ALTER COLUMN filename TYPE TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ticket_attachments ALTER COLUMN filename TYPE VARCHAR(255) USING left(filename, 255);
+ALTER TABLE tickets
+    ALTER COLUMN name TYPE VARCHAR(255) USING left(name, 255),
+    ALTER COLUMN email TYPE VARCHAR(255) USING left(email, 255),
+    ALTER COLUMN subject TYPE VARCHAR(255) USING left(subject, 255);
+-- +goose StatementEnd
@@ -1,23 +1,102 @@
 package main
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/trunov/virena/internal/app/antispam"
 	"github.com/trunov/virena/internal/app/config"
+	"github.com/trunov/virena/internal/app/delivery"
+	"github.com/trunov/virena/internal/app/email"
 	"github.com/trunov/virena/internal/app/handler"
+	"github.com/trunov/virena/internal/app/job"
+	"github.com/trunov/virena/internal/app/mailer"
+	"github.com/trunov/virena/internal/app/outbox"
+	"github.com/trunov/virena/internal/app/partcode"
 	"github.com/trunov/virena/internal/app/postgres"
 	"github.com/trunov/virena/internal/app/services"
+	"github.com/trunov/virena/internal/app/storage"
 	"github.com/trunov/virena/logger"
 )
 
 func StartServer(cfg config.Config, dbStorage postgres.DBStorager) {
 	l := logger.Get()
 
-	s := services.NewFileService()
+	partCodeRules, err := partcode.ParseBrandRules(cfg.PartCodeRules)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msg("Failed to read the part code rules.")
+	}
+	codes := partcode.New(partCodeRules)
 
-	h := handler.NewHandler(dbStorage, s, l, cfg.SendgridAPIKey)
+	s := services.NewFileService(codes)
+
+	m, err := mailer.New(cfg)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msg("Failed to set up the mailer.")
+	}
+
+	files, err := storage.NewLocal(cfg.StorageDir)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msg("Failed to set up the file storage.")
+	}
+
+	emails, err := email.NewSettings(cfg)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msg("Failed to read the email settings.")
+	}
+
+	worker := outbox.NewWorker(dbStorage, email.Deliver(m, emails, dbStorage, files, l), l, outbox.Options{
+		PollInterval: cfg.OutboxPollInterval,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+		BaseBackoff:  cfg.OutboxBaseBackoff,
+		MaxBackoff:   cfg.OutboxMaxBackoff,
+	})
+	go worker.Run(context.Background())
+
+	captcha, err := antispam.NewCaptcha(cfg.CaptchaProvider, cfg.CaptchaVerifyURL, cfg.CaptchaSecret, cfg.CaptchaFakeToken)
+	if err != nil {
+		l.Fatal().
+			Err(err).
+			Msg("Failed to set up the captcha verifier.")
+	}
+
+	guard := antispam.NewGuard(antispam.Options{
+		MinSubmitTime: cfg.ContactMinSubmitTime,
+		MaxFormAge:    cfg.ContactMaxFormAge,
+		IPLimit:       antispam.NewRateLimiter(cfg.ContactRateLimitIP, cfg.ContactRateWindow),
+		EmailLimit:    antispam.NewRateLimiter(cfg.ContactRateLimitEmail, cfg.ContactRateWindow),
+		Captcha:       captcha,
+	})
+
+	var webhooks *delivery.Verifier
+	if cfg.SendgridWebhookPublicKey != "" {
+		webhooks, err = delivery.NewVerifier(cfg.SendgridWebhookPublicKey)
+		if err != nil {
+			l.Fatal().
+				Err(err).
+				Msg("Failed to set up the SendGrid webhook verifier.")
+		}
+	}
+
+	h := handler.NewHandler(dbStorage, s, codes, files, guard, webhooks, emails, l, cfg)
 	r := handler.NewRouter(h)
 
+	jobs := job.NewWorker(dbStorage, files, h.RunJob, l, job.Options{
+		Workers:      cfg.JobWorkers,
+		PollInterval: cfg.JobPollInterval,
+		MaxAttempts:  cfg.JobMaxAttempts,
+		Retention:    cfg.JobRetention,
+	})
+	go jobs.Run(context.Background())
+
 	l.Info().
 		Msgf("Starting the Virena app server on PORT '%s'", cfg.Port)
 